@@ -0,0 +1,78 @@
+package dbengine
+
+// WriteBatch - a group of writes and deletes that are applied to the database atomically, either all of
+// them are applied or none of them is, even if the process crashes in the middle. Operations are applied
+// in the order they are added, so a later operation on the same key wins.
+type WriteBatch struct {
+	records []*MemtableRecord
+}
+
+// WriteOptions - options of a write operation, nil is the same as the zero value
+type WriteOptions struct {
+	// Sync - flush the WAL to the storage device before the write returns, even if the database isn't configured
+	// to (see `ConfigWalStrictMode`)
+	Sync bool
+	// DisableWAL - skip recording the write in the WAL. The write is lost if the process crashes before it's
+	// serialized into an sstable file, which suits writes that can be done again, such as bulk backfills
+	DisableWAL bool
+	// NoSlowdown - fail with `ErrWriteStall` instead of waiting when the write would have to wait for a
+	// memtable to be serialized
+	NoSlowdown bool
+}
+
+// writeOptionsOf - returns the options of a write, the zero value if opts is nil
+func writeOptionsOf(opts *WriteOptions) WriteOptions {
+	if opts == nil {
+		return WriteOptions{}
+	}
+	return *opts
+}
+
+// NewWriteBatch - creates an empty write batch
+func NewWriteBatch() *WriteBatch {
+	return &WriteBatch{
+		records: make([]*MemtableRecord, 0),
+	}
+}
+
+// Put - adds a write of key with value to the batch
+func (b *WriteBatch) Put(key string, value []byte) {
+	b.records = append(b.records, &MemtableRecord{Key: key, Value: value})
+}
+
+// Delete - adds a delete of key to the batch
+func (b *WriteBatch) Delete(key string) {
+	b.records = append(b.records, &MemtableRecord{Key: key, Tombstone: true})
+}
+
+// Len - returns the number of operations in the batch
+func (b *WriteBatch) Len() int {
+	return len(b.records)
+}
+
+// sizeBytes - returns the total size of the keys and values written by the batch
+func (b *WriteBatch) sizeBytes() int {
+	size := 0
+	for _, record := range b.records {
+		size += len(record.Key) + len(record.Value)
+	}
+	return size
+}
+
+// Clear - removes all the operations from the batch so it can be reused
+func (b *WriteBatch) Clear() {
+	b.records = b.records[:0]
+}
+
+// keys - returns the distinct keys written by the batch
+func (b *WriteBatch) keys() []string {
+	seen := make(map[string]bool, len(b.records))
+	keys := make([]string, 0, len(b.records))
+	for _, record := range b.records {
+		if !seen[record.Key] {
+			seen[record.Key] = true
+			keys = append(keys, record.Key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,113 @@
+package dbengine
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func Test_batchShouldApplyOperationsInOrder(t *testing.T) {
+	db, err := NewDatabase(
+		ConfigDBDir(setupTestDBDir(t)),
+		ConfigLogLevel(log.InfoLevel),
+	)
+	if err != nil {
+		t.Fatalf("Failed to initialize database - Error: %s", err.Error())
+	}
+	db.Write("deleted", []byte("value"))
+
+	batch := NewWriteBatch()
+	batch.Put("key", []byte("value-1"))
+	batch.Put("key", []byte("value-2"))
+	batch.Put("other", []byte("other"))
+	batch.Delete("deleted")
+	if err := db.ApplyBatch(batch); err != nil {
+		t.Fatalf("Failed to apply batch - Error: %s", err.Error())
+	}
+
+	if value, _ := db.Get("key"); string(value) != "value-2" {
+		t.Errorf("The last operation on a key should win, got %s", string(value))
+	}
+	if value, _ := db.Get("other"); string(value) != "other" {
+		t.Errorf("Expected other, got %s", string(value))
+	}
+	if value, _ := db.Get("deleted"); value != nil {
+		t.Errorf("Deleted key should not be found, got %s", string(value))
+	}
+	if db.lastSequence() != 5 {
+		t.Errorf("Every operation of the batch should get a sequence number, got last sequence %d", db.lastSequence())
+	}
+}
+
+func Test_batchShouldBeRecoveredAllOrNothing(t *testing.T) {
+	testDBDir := setupTestDBDir(t)
+
+	db, err := NewDatabase(
+		ConfigDBDir(testDBDir),
+		ConfigLogLevel(log.InfoLevel),
+	)
+	if err != nil {
+		t.Fatalf("Failed to initialize database - Error: %s", err.Error())
+	}
+
+	complete := NewWriteBatch()
+	torn := NewWriteBatch()
+	for i := 0; i < 10; i++ {
+		complete.Put(fmt.Sprintf("complete-%d", i), []byte("value"))
+		torn.Put(fmt.Sprintf("torn-%d", i), []byte("value"))
+	}
+	db.ApplyBatch(complete)
+	db.ApplyBatch(torn)
+
+	// simulate a crash in the middle of writing the last batch
+	walFile := db.curMem.Wal().File().Name()
+	info, _ := os.Stat(walFile)
+	if err := os.Truncate(walFile, info.Size()-10); err != nil {
+		t.Fatal(err)
+	}
+
+	recovered, err := NewDatabase(
+		ConfigDBDir(testDBDir),
+		ConfigLogLevel(log.InfoLevel),
+	)
+	if err != nil {
+		t.Fatalf("Failed to open existing database - Error: %s", err.Error())
+	}
+
+	for i := 0; i < 10; i++ {
+		if value, _ := recovered.Get(fmt.Sprintf("complete-%d", i)); string(value) != "value" {
+			t.Errorf("Complete batch should be recovered, got %s for complete-%d", string(value), i)
+		}
+		if value, _ := recovered.Get(fmt.Sprintf("torn-%d", i)); value != nil {
+			t.Errorf("None of the torn batch should be recovered, got %s for torn-%d", string(value), i)
+		}
+	}
+}
+
+func Test_batchShouldNotBeSplitAcrossMemtables(t *testing.T) {
+	db, err := NewDatabase(
+		ConfigDBDir(setupTestDBDir(t)),
+		ConfigMemtableSizeByte(512),
+		ConfigLogLevel(log.InfoLevel),
+	)
+	if err != nil {
+		t.Fatalf("Failed to initialize database - Error: %s", err.Error())
+	}
+
+	batch := NewWriteBatch()
+	for i := 0; i < 100; i++ {
+		batch.Put(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%03d", i)))
+	}
+	db.ApplyBatch(batch)
+	waitForMemtableSerialization(t, db)
+
+	if size := db.curMem.SizeBytes(); size != 0 {
+		t.Errorf("The batch should have been rotated out of the current memtable, got size %d", size)
+	}
+	files := db.manifest.currentVersion().files()
+	if len(files) != 1 || files[0].smallestKey != "key-000" || files[0].largestKey != "key-099" {
+		t.Errorf("The whole batch should be serialized into a single sstable file, got %v", files)
+	}
+}
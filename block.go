@@ -0,0 +1,307 @@
+package dbengine
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/DrakeW/go-db-engine/pb"
+)
+
+// Data block layout (before compression):
+// - <entry_1>...<entry_N><restart_1 (fixed 32-bit)>...<restart_M (fixed 32-bit)><M (fixed 32-bit)>
+//
+// entry:
+// - <shared key size (varint)><unshared key size (varint)><value size (varint)><seq (varint)><type (1 byte)>
+// <unshared key bytes><value>
+// - keys are prefix compressed, an entry only stores the part of its key that isn't shared with the key of the
+// previous entry
+//
+// restart:
+// - every `blockRestartInterval` entries, the key of an entry is stored in full (shared key size is 0). Restarts
+// record the offsets of these entries, so a lookup can binary search over them and only has to decode the
+// entries after the closest one instead of the whole block.
+//
+// All little endian. Versions of the same key are stored from the latest to the earliest.
+
+// blockRestartInterval - number of entries between two restarts
+const blockRestartInterval = 16
+
+// blockBuilder - builds a data block out of records added in key order
+type blockBuilder struct {
+	buf      []byte
+	restarts []uint32
+	counter  int // counter - number of entries added since the last restart
+	firstKey string
+	lastKey  string
+}
+
+func newBlockBuilder() *blockBuilder {
+	return &blockBuilder{restarts: []uint32{0}}
+}
+
+// add - appends the record to the block, records have to be added in increasing key order and versions of the
+// same key from the latest to the earliest
+func (b *blockBuilder) add(record *MemtableRecord) {
+	shared := 0
+	if b.counter < blockRestartInterval {
+		for shared < len(b.lastKey) && shared < len(record.Key) && b.lastKey[shared] == record.Key[shared] {
+			shared++
+		}
+	} else {
+		b.restarts = append(b.restarts, uint32(len(b.buf)))
+		b.counter = 0
+	}
+	if b.empty() {
+		b.firstKey = record.Key
+	}
+
+	recordType := byte(recordTypeOf(record))
+	b.buf = appendUvarint(b.buf, uint64(shared))
+	b.buf = appendUvarint(b.buf, uint64(len(record.Key)-shared))
+	b.buf = appendUvarint(b.buf, uint64(len(record.Value)))
+	b.buf = appendUvarint(b.buf, record.Seq)
+	b.buf = append(b.buf, recordType)
+	b.buf = append(b.buf, record.Key[shared:]...)
+	b.buf = append(b.buf, record.Value...)
+
+	b.lastKey = record.Key
+	b.counter++
+}
+
+// empty - returns true if no record has been added to the block
+func (b *blockBuilder) empty() bool {
+	return len(b.buf) == 0
+}
+
+// size - returns the size of the block built so far
+func (b *blockBuilder) size() int {
+	return len(b.buf) + 4*len(b.restarts) + 4
+}
+
+// finish - returns the block with all the records added, the builder can't be used afterwards
+func (b *blockBuilder) finish() []byte {
+	for _, restart := range b.restarts {
+		b.buf = appendUint32(b.buf, restart)
+	}
+	return appendUint32(b.buf, uint32(len(b.restarts)))
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	var tmp [4]byte
+	binary.LittleEndian.PutUint32(tmp[:], v)
+	return append(buf, tmp[:]...)
+}
+
+// block - a data block read from an sstable file, entries are decoded on demand by its iterators
+type block struct {
+	data           []byte
+	restartsOffset int // restartsOffset - where the restart array starts, the entries take up data before it
+	numRestarts    int
+	verified       bool // verified - true if the block was verified against its checksum when it was read
+}
+
+// newBlock - parses the restart array of a data block, returns the reason if the block is malformed
+func newBlock(data []byte) (*block, string) {
+	if len(data) < 4 {
+		return nil, "block too short"
+	}
+	numRestarts := int(binary.LittleEndian.Uint32(data[len(data)-4:]))
+	if numRestarts == 0 || numRestarts > (len(data)-4)/4 {
+		return nil, "invalid number of restarts"
+	}
+
+	b := &block{data: data, restartsOffset: len(data) - 4 - 4*numRestarts, numRestarts: numRestarts}
+	// every restart points at an entry, only an empty block has its single restart at the restarts
+	for i := 0; i < numRestarts; i++ {
+		point := b.restartPoint(i)
+		if point >= b.restartsOffset && !(i == 0 && point == 0 && b.restartsOffset == 0) {
+			return nil, "restart out of range"
+		}
+	}
+	return b, ""
+}
+
+// restartPoint - returns the offset of the entry at restart i
+func (b *block) restartPoint(i int) int {
+	return int(binary.LittleEndian.Uint32(b.data[b.restartsOffset+4*i:]))
+}
+
+// size - returns the size of the block in bytes
+func (b *block) size() int {
+	return len(b.data)
+}
+
+func (b *block) newIterator() *blockIterator {
+	return &blockIterator{b: b, offset: b.restartsOffset, nextOffset: b.restartsOffset}
+}
+
+// blockIterator - iterates through the records of a block. Only the key of the current entry is decoded, the
+// value is a slice of the block.
+type blockIterator struct {
+	b          *block
+	offset     int // offset - where the current entry starts, `restartsOffset` if the iterator isn't valid
+	nextOffset int // nextOffset - where the entry after the current one starts
+	restartIdx int // restartIdx - index of the last restart at or before the current entry
+	key        []byte
+	value      []byte
+	seq        uint64
+	tombstone  bool
+	err        string // err - reason why the block is malformed, if found
+}
+
+// valid - returns true if the iterator is positioned at an entry
+func (it *blockIterator) valid() bool {
+	return it.err == "" && it.offset < it.b.restartsOffset
+}
+
+// first - moves to the first entry of the block
+func (it *blockIterator) first() {
+	it.seekToRestart(0)
+	it.parseNext()
+}
+
+// last - moves to the last entry of the block
+func (it *blockIterator) last() {
+	it.seekToRestart(it.b.numRestarts - 1)
+	for it.parseNext() && it.nextOffset < it.b.restartsOffset {
+	}
+}
+
+// seek - moves to the latest version of the first key that is greater than or equal to key
+func (it *blockIterator) seek(key string) {
+	// binary search for the last restart whose key is smaller than key, all the versions of key are after it
+	left, right := 0, it.b.numRestarts-1
+	for left < right {
+		mid := (left + right + 1) / 2
+		restartKey, ok := it.restartKey(mid)
+		if !ok {
+			return
+		}
+		if restartKey < key {
+			left = mid
+		} else {
+			right = mid - 1
+		}
+	}
+
+	it.seekToRestart(left)
+	for it.parseNext() && string(it.key) < key {
+	}
+}
+
+// restartKey - returns the key of the entry at restart i, which is stored in full
+func (it *blockIterator) restartKey(i int) (string, bool) {
+	offset := it.b.restartPoint(i)
+	shared, unshared, _, _, n := it.parseHeader(offset)
+	if n == 0 || shared != 0 {
+		it.corrupted(offset)
+		return "", false
+	}
+	return string(it.b.data[offset+n : offset+n+unshared]), true
+}
+
+// next - moves to the next entry
+func (it *blockIterator) next() {
+	it.parseNext()
+}
+
+// prev - moves to the previous entry
+func (it *blockIterator) prev() {
+	current := it.offset
+	for it.restartIdx >= 0 && it.b.restartPoint(it.restartIdx) >= current {
+		it.restartIdx--
+	}
+	if it.restartIdx < 0 {
+		// no entry before the first one
+		it.offset, it.nextOffset = it.b.restartsOffset, it.b.restartsOffset
+		return
+	}
+
+	it.seekToRestart(it.restartIdx)
+	for it.parseNext() && it.nextOffset < current {
+	}
+}
+
+// record - returns the record of the current entry
+func (it *blockIterator) record() *MemtableRecord {
+	return &MemtableRecord{
+		Key:       string(it.key),
+		Value:     it.value,
+		Tombstone: it.tombstone,
+		Seq:       it.seq,
+	}
+}
+
+// seekToRestart - positions the iterator right before the entry at restart i
+func (it *blockIterator) seekToRestart(i int) {
+	it.key = it.key[:0]
+	it.restartIdx = i
+	it.nextOffset = it.b.restartPoint(i)
+}
+
+// parseNext - decodes the entry after the current one and moves to it, returns false if there is none
+func (it *blockIterator) parseNext() bool {
+	it.offset = it.nextOffset
+	if it.offset >= it.b.restartsOffset {
+		it.offset, it.nextOffset = it.b.restartsOffset, it.b.restartsOffset
+		return false
+	}
+
+	shared, unshared, valueSize, seq, n := it.parseHeader(it.offset)
+	if n == 0 {
+		it.corrupted(it.offset)
+		return false
+	}
+	keyStart := it.offset + n
+	it.key = append(it.key[:shared], it.b.data[keyStart:keyStart+unshared]...)
+	it.value = it.b.data[keyStart+unshared : keyStart+unshared+valueSize]
+	it.seq = seq
+	it.tombstone = pb.RecordType(it.b.data[keyStart-1]) == pb.RecordType_TOMBSTONE
+	it.nextOffset = keyStart + unshared + valueSize
+
+	for it.restartIdx+1 < it.b.numRestarts && it.b.restartPoint(it.restartIdx+1) <= it.offset {
+		it.restartIdx++
+	}
+	return true
+}
+
+// parseHeader - decodes the header of the entry at offset, n is the size of the header, 0 if it's malformed. The
+// key of the previous entry has to be decoded already, since the shared part of the key is taken from it
+func (it *blockIterator) parseHeader(offset int) (shared, unshared, valueSize int, seq uint64, n int) {
+	if offset < 0 || offset >= it.b.restartsOffset {
+		return 0, 0, 0, 0, 0
+	}
+	data := it.b.data[offset:it.b.restartsOffset]
+	var values [4]uint64
+	for i := range values {
+		v, size := binary.Uvarint(data[n:])
+		if size <= 0 {
+			return 0, 0, 0, 0, 0
+		}
+		values[i] = v
+		n += size
+	}
+	// the type of the record follows
+	n++
+	if n > len(data) {
+		return 0, 0, 0, 0, 0
+	}
+	// each size is bounded on its own before they're added up or converted, so that none of them can wrap around
+	rest := uint64(len(data) - n)
+	if values[0] > uint64(len(it.key)) || values[1] > rest || values[2] > rest-values[1] {
+		return 0, 0, 0, 0, 0
+	}
+	return int(values[0]), int(values[1]), int(values[2]), values[3], n
+}
+
+// corrupted - marks the block as malformed at offset
+func (it *blockIterator) corrupted(offset int) {
+	it.err = fmt.Sprintf("malformed entry at offset %d of the block", offset)
+	it.offset, it.nextOffset = it.b.restartsOffset, it.b.restartsOffset
+}
@@ -0,0 +1,146 @@
+package dbengine
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+const numBlockCacheShards = 16
+
+// blockCache - a cache of blocks read from sstable files shared by all the readers of the database, bounded by
+// the total size (in bytes) of the blocks cached. The cache is split into shards, each with its own lock and
+// LRU list, so that concurrent readers rarely wait for each other.
+//
+// Besides data blocks, index and filter blocks are cached as well. They can be pinned so they're never evicted,
+// in which case they still count towards the capacity until they're removed.
+type blockCache struct {
+	shards [numBlockCacheShards]*blockCacheShard
+	hits   uint64
+	misses uint64
+}
+
+// blockCacheKey - identifies a block by the number of the sstable file and the offset of the block in the file
+type blockCacheKey struct {
+	fileNumber uint64
+	offset     uint64
+}
+
+type blockCacheShard struct {
+	lock     sync.Mutex
+	capacity int
+	usage    int
+	// lru - unpinned entries, from the most recently used to the least
+	lru     *list.List
+	entries map[blockCacheKey]*blockCacheEntry
+}
+
+type blockCacheEntry struct {
+	key    blockCacheKey
+	value  interface{}
+	charge int
+	pinned bool
+	elem   *list.Element // elem - nil when pinned
+}
+
+// BlockCacheStats - statistics of the block cache
+type BlockCacheStats struct {
+	Hits     uint64
+	Misses   uint64
+	Usage    int // Usage - total size (in bytes) of the blocks cached
+	Capacity int
+}
+
+func newBlockCache(capacity int) *blockCache {
+	c := &blockCache{}
+	for i := range c.shards {
+		c.shards[i] = &blockCacheShard{
+			capacity: (capacity + numBlockCacheShards - 1) / numBlockCacheShards,
+			lru:      list.New(),
+			entries:  make(map[blockCacheKey]*blockCacheEntry),
+		}
+	}
+	return c
+}
+
+func (c *blockCache) shard(key blockCacheKey) *blockCacheShard {
+	h := (key.fileNumber*0x9E3779B97F4A7C15 ^ key.offset) * 0xBF58476D1CE4E5B9
+	return c.shards[h>>60]
+}
+
+// get - returns the block cached under key
+func (c *blockCache) get(key blockCacheKey) (interface{}, bool) {
+	s := c.shard(key)
+	s.lock.Lock()
+	entry, ok := s.entries[key]
+	if ok && entry.elem != nil {
+		s.lru.MoveToFront(entry.elem)
+	}
+	s.lock.Unlock()
+
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+	atomic.AddUint64(&c.hits, 1)
+	return entry.value, true
+}
+
+// insert - caches the block under key, charge is the size of the block in bytes. Least recently used blocks
+// are evicted until the cache is within capacity. A pinned block is never evicted, it stays in the cache until
+// it's removed with the entry returned.
+func (c *blockCache) insert(key blockCacheKey, value interface{}, charge int, pinned bool) *blockCacheEntry {
+	s := c.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if old, ok := s.entries[key]; ok {
+		s.removeLocked(old)
+	}
+	entry := &blockCacheEntry{key: key, value: value, charge: charge, pinned: pinned}
+	if !pinned {
+		entry.elem = s.lru.PushFront(entry)
+	}
+	s.entries[key] = entry
+	s.usage += charge
+
+	for s.usage > s.capacity && s.lru.Len() > 0 {
+		s.removeLocked(s.lru.Back().Value.(*blockCacheEntry))
+	}
+	return entry
+}
+
+// remove - removes the block of the entry, if it's still cached
+func (c *blockCache) remove(entry *blockCacheEntry) {
+	s := c.shard(entry.key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.entries[entry.key] == entry {
+		s.removeLocked(entry)
+	}
+}
+
+// stats - returns the statistics of the cache
+func (c *blockCache) stats() BlockCacheStats {
+	stats := BlockCacheStats{
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+	}
+	for _, s := range c.shards {
+		s.lock.Lock()
+		stats.Usage += s.usage
+		stats.Capacity += s.capacity
+		s.lock.Unlock()
+	}
+	return stats
+}
+
+// removeLocked - removes the entry from the shard, lock must be held
+func (s *blockCacheShard) removeLocked(entry *blockCacheEntry) {
+	if entry.elem != nil {
+		s.lru.Remove(entry.elem)
+	}
+	delete(s.entries, entry.key)
+	s.usage -= entry.charge
+}
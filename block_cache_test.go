@@ -0,0 +1,92 @@
+package dbengine
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+)
+
+func Test_blockCacheShouldEvictLeastRecentlyUsedBlocksOverCapacity(t *testing.T) {
+	// a single shard holds 100 bytes
+	cache := newBlockCache(100 * numBlockCacheShards)
+	key := func(i int) blockCacheKey { return blockCacheKey{fileNumber: 1, offset: uint64(i)} }
+
+	for i := 0; i < 1000; i++ {
+		cache.insert(key(i), i, 10, false)
+		// keep the first block hot
+		cache.get(key(0))
+	}
+
+	stats := cache.stats()
+	if stats.Usage > stats.Capacity {
+		t.Errorf("Cache usage %d should not exceed capacity %d", stats.Usage, stats.Capacity)
+	}
+	if value, ok := cache.get(key(0)); !ok || value.(int) != 0 {
+		t.Error("Recently used block should stay in cache")
+	}
+	if _, ok := cache.get(key(1)); ok {
+		t.Error("Least recently used block should be evicted")
+	}
+	if stats.Hits != 1000 {
+		t.Errorf("Expected 1000 hits, got %d", stats.Hits)
+	}
+}
+
+func Test_blockCacheShouldNeverEvictPinnedBlocks(t *testing.T) {
+	cache := newBlockCache(100 * numBlockCacheShards)
+	pinnedKey := blockCacheKey{fileNumber: 1, offset: 0}
+	pinned := cache.insert(pinnedKey, "index", 50, true)
+
+	for i := 1; i < 1000; i++ {
+		cache.insert(blockCacheKey{fileNumber: 1, offset: uint64(i)}, i, 10, false)
+	}
+	if value, ok := cache.get(pinnedKey); !ok || value.(string) != "index" {
+		t.Error("Pinned block should never be evicted")
+	}
+
+	cache.remove(pinned)
+	if _, ok := cache.get(pinnedKey); ok {
+		t.Error("Pinned block should be gone once removed")
+	}
+	if stats := cache.stats(); stats.Usage > stats.Capacity {
+		t.Errorf("Cache usage %d should not exceed capacity %d", stats.Usage, stats.Capacity)
+	}
+}
+
+func Test_blockCacheShouldBeSafeForConcurrentReaders(t *testing.T) {
+	s, _ := NewBasicSSTableWriter(os.TempDir(), 50, 10, SnappyCompression, 0)
+	s.Dump(getTestMemtable(t, 100))
+
+	// small enough that blocks, index and filter keep getting evicted
+	cache := newBlockCache(2 * 1024)
+	reader, err := newCachedSSTableReader(s.File(), 1, cache, false, true)
+	if err != nil {
+		t.Fatalf("Failed to open sstable - Error: %s", err.Error())
+	}
+	defer reader.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				key := fmt.Sprintf("key-%03d", (i*7+g)%100)
+				record, err := reader.Get(key, maxSequence)
+				if err != nil || record == nil || string(record.Value) != "value-"+key[len("key-"):] {
+					t.Errorf("Failed to read key %s, got %v - %v", key, record, err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	stats := cache.stats()
+	if stats.Hits == 0 || stats.Misses == 0 {
+		t.Errorf("Expected both hits and misses, got %+v", stats)
+	}
+	if stats.Usage > stats.Capacity {
+		t.Errorf("Cache usage %d should not exceed capacity %d", stats.Usage, stats.Capacity)
+	}
+}
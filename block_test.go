@@ -0,0 +1,195 @@
+package dbengine
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// getTestBlock - returns a block of keys key-000 to key-(n-1), every third key has 3 versions
+func getTestBlock(t *testing.T, n int) (*block, []*MemtableRecord) {
+	t.Helper()
+
+	builder := newBlockBuilder()
+	records := make([]*MemtableRecord, 0)
+	for i := 0; i < n; i++ {
+		versions := 1
+		if i%3 == 0 {
+			versions = 3
+		}
+		for v := versions; v > 0; v-- {
+			record := &MemtableRecord{
+				Key:       fmt.Sprintf("key-%03d", i),
+				Value:     []byte(fmt.Sprintf("value-%03d-%d", i, v)),
+				Seq:       uint64(i*10 + v),
+				Tombstone: v == 2,
+			}
+			if record.Tombstone {
+				record.Value = []byte{}
+			}
+			builder.add(record)
+			records = append(records, record)
+		}
+	}
+
+	b, reason := newBlock(builder.finish())
+	if reason != "" {
+		t.Fatalf("Failed to parse block - %s", reason)
+	}
+	return b, records
+}
+
+func Test_blockIteratorShouldMoveInBothDirections(t *testing.T) {
+	b, records := getTestBlock(t, 50)
+	if b.numRestarts < 2 {
+		t.Fatalf("Expected multiple restarts, got %d", b.numRestarts)
+	}
+
+	it := b.newIterator()
+	i := 0
+	for it.first(); it.valid(); it.next() {
+		if record := it.record(); record.Key != records[i].Key || record.Seq != records[i].Seq ||
+			string(record.Value) != string(records[i].Value) || record.Tombstone != records[i].Tombstone {
+			t.Fatalf("Expected %v at position %d, got %v", records[i], i, record)
+		}
+		i++
+	}
+	if i != len(records) {
+		t.Errorf("Expected %d records, got %d", len(records), i)
+	}
+
+	i = len(records) - 1
+	for it.last(); it.valid(); it.prev() {
+		if record := it.record(); record.Key != records[i].Key || record.Seq != records[i].Seq {
+			t.Fatalf("Expected %v at position %d, got %v", records[i], i, record)
+		}
+		i--
+	}
+	if i != -1 || it.err != "" {
+		t.Errorf("Expected to move back to the first record, stopped at %d - %s", i, it.err)
+	}
+}
+
+func Test_blockIteratorSeekShouldFindLatestVersionOfKey(t *testing.T) {
+	b, _ := getTestBlock(t, 50)
+
+	it := b.newIterator()
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		it.seek(key)
+		expectedSeq := uint64(i*10 + 1)
+		if i%3 == 0 {
+			expectedSeq = uint64(i*10 + 3)
+		}
+		if !it.valid() || it.record().Key != key || it.record().Seq != expectedSeq {
+			t.Errorf("Expected latest version of %s, got %v", key, it.record())
+		}
+	}
+
+	if it.seek("key-0105"); !it.valid() || it.record().Key != "key-011" {
+		t.Errorf("Seek should move to the next key, got %v", it.record())
+	}
+	if it.seek("a"); !it.valid() || it.record().Key != "key-000" {
+		t.Errorf("Seek before the first key should move to the first key, got %v", it.record())
+	}
+	if it.seek("key-100"); it.valid() {
+		t.Errorf("Seek past the last key should be invalid, got %v", it.record())
+	}
+}
+
+func Test_blockBuilderShouldPrefixCompressKeys(t *testing.T) {
+	prefix := "tenant-00000000000000000000000000000000/"
+	builder := newBlockBuilder()
+	keysSize := 0
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("%skey-%03d", prefix, i)
+		builder.add(&MemtableRecord{Key: key, Value: []byte("v"), Seq: uint64(i + 1)})
+		keysSize += len(key)
+	}
+
+	// only the keys at restarts are stored in full
+	if size := builder.size(); size >= keysSize/2 {
+		t.Errorf("Expected keys with shared prefix to be compressed, block size %d for %d bytes of keys", size, keysSize)
+	}
+}
+
+func Test_blockShouldRejectMalformedData(t *testing.T) {
+	if _, reason := newBlock([]byte{1, 2}); reason == "" {
+		t.Error("Block too short should be rejected")
+	}
+	if _, reason := newBlock([]byte{0, 0, 0, 0, 9, 0, 0, 0}); reason == "" {
+		t.Error("Block with invalid number of restarts should be rejected")
+	}
+	// no entries, but restarts pointing past the first one
+	if _, reason := newBlock(appendUint32(appendUint32(appendUint32(appendUint32(nil, 0), 5), 0), 3)); reason == "" {
+		t.Error("Block without entries and with a restart out of range should be rejected")
+	}
+	if _, reason := newBlock(newBlockBuilder().finish()); reason != "" {
+		t.Errorf("Empty block should be accepted - %s", reason)
+	}
+
+	builder := newBlockBuilder()
+	builder.add(&MemtableRecord{Key: "key-001", Value: []byte("value-001"), Seq: 1})
+	data := builder.finish()
+	// claim the value is longer than the block
+	data[2] = 100
+	b, reason := newBlock(data)
+	if reason != "" {
+		t.Fatalf("Restarts should still be valid - %s", reason)
+	}
+	it := b.newIterator()
+	if it.first(); it.valid() || it.err == "" {
+		t.Error("Malformed entry should be reported")
+	}
+	if _, _, _, _, n := it.parseHeader(len(data)); n != 0 {
+		t.Error("Entry header past the entries should not be parsed")
+	}
+}
+
+func Test_blockShouldReportEntriesWithOverflowingSizes(t *testing.T) {
+	entries := map[string][]byte{
+		// unshared key size of 2^64-1 and value size of 2 add up to 1
+		"unshared key size": append(appendUvarint(appendUvarint([]byte{0}, ^uint64(0)), 2), 1, 0, 'k', 'v'),
+		// shared key size of 2^63 turns negative as an int
+		"shared key size": append(appendUvarint([]byte{}, 1<<63), 1, 1, 1, 0, 'k', 'v'),
+	}
+	for name, entry := range entries {
+		data := appendUint32(appendUint32(entry, 0), 1)
+		b, reason := newBlock(data)
+		if reason != "" {
+			t.Fatalf("%s - restarts should still be valid - %s", name, reason)
+		}
+
+		it := b.newIterator()
+		if it.first(); it.valid() || it.err == "" {
+			t.Errorf("%s - expected malformed entry to be reported by first", name)
+		}
+		it = b.newIterator()
+		if it.seek("k"); it.valid() || it.err == "" {
+			t.Errorf("%s - expected malformed entry to be reported by seek", name)
+		}
+	}
+}
+
+func Test_blockIteratorShouldNotPanicOnDamagedBlocks(t *testing.T) {
+	b, _ := getTestBlock(t, 50)
+	rand.Seed(1)
+	for i := 0; i < 2000; i++ {
+		data := append([]byte{}, b.data...)
+		// damage a few bytes of the entries, the restarts are checked by newBlock already
+		for j := 0; j < 1+rand.Intn(4); j++ {
+			data[rand.Intn(b.restartsOffset)] = byte(rand.Intn(256))
+		}
+		damaged, reason := newBlock(data)
+		if reason != "" {
+			continue
+		}
+
+		it := damaged.newIterator()
+		for it.first(); it.valid(); it.next() {
+		}
+		for it.last(); it.valid(); it.prev() {
+		}
+		it.seek(fmt.Sprintf("key-%03d", rand.Intn(50)))
+	}
+}
@@ -0,0 +1,91 @@
+package dbengine
+
+import "hash/fnv"
+
+// bloomFilter - a bloom filter over keys, used to tell that a key is definitely not in a sstable file without
+// reading any of its data blocks.
+//
+// Filter layout:
+// - <bit array><number of probes (1 byte)>
+//
+// The number of probes is stored along with the bits so that a filter can be read regardless of the bits per
+// key it's built with.
+type bloomFilter []byte
+
+// bloomFilterBuilder - collects keys and builds a bloom filter with bitsPerKey bits for each key
+type bloomFilterBuilder struct {
+	bitsPerKey int
+	hashes     []uint32
+}
+
+func newBloomFilterBuilder(bitsPerKey int) *bloomFilterBuilder {
+	return &bloomFilterBuilder{
+		bitsPerKey: bitsPerKey,
+		hashes:     make([]uint32, 0),
+	}
+}
+
+// add - adds key to the filter
+func (b *bloomFilterBuilder) add(key string) {
+	b.hashes = append(b.hashes, bloomHash(key))
+}
+
+// build - builds the filter from the keys added so far
+func (b *bloomFilterBuilder) build() bloomFilter {
+	// the false positive rate is the lowest with bitsPerKey * ln(2) probes
+	numProbes := int(float64(b.bitsPerKey) * 0.69)
+	if numProbes < 1 {
+		numProbes = 1
+	}
+	if numProbes > 30 {
+		numProbes = 30
+	}
+
+	// a small number of keys would see a high false positive rate with too few bits
+	numBits := len(b.hashes) * b.bitsPerKey
+	if numBits < 64 {
+		numBits = 64
+	}
+	numBytes := (numBits + 7) / 8
+	numBits = numBytes * 8
+
+	filter := make(bloomFilter, numBytes+1)
+	filter[numBytes] = byte(numProbes)
+	for _, h := range b.hashes {
+		// double hashing, every probe is derived from the same hash rotated
+		delta := h>>17 | h<<15
+		for i := 0; i < numProbes; i++ {
+			pos := h % uint32(numBits)
+			filter[pos/8] |= 1 << (pos % 8)
+			h += delta
+		}
+	}
+	return filter
+}
+
+// mayContain - returns false if key is definitely not in the filter
+func (f bloomFilter) mayContain(key string) bool {
+	if len(f) < 2 {
+		// not a valid filter, assume any key might be there
+		return true
+	}
+
+	numBits := uint32(len(f)-1) * 8
+	numProbes := int(f[len(f)-1])
+	h := bloomHash(key)
+	delta := h>>17 | h<<15
+	for i := 0; i < numProbes; i++ {
+		pos := h % numBits
+		if f[pos/8]&(1<<(pos%8)) == 0 {
+			return false
+		}
+		h += delta
+	}
+	return true
+}
+
+func bloomHash(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
+}
@@ -0,0 +1,48 @@
+package dbengine
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Test_bloomFilterShouldContainEveryKeyAdded(t *testing.T) {
+	for _, numKeys := range []int{1, 10, 100, 10000} {
+		builder := newBloomFilterBuilder(10)
+		for i := 0; i < numKeys; i++ {
+			builder.add(fmt.Sprintf("key-%d", i))
+		}
+		filter := builder.build()
+
+		for i := 0; i < numKeys; i++ {
+			if key := fmt.Sprintf("key-%d", i); !filter.mayContain(key) {
+				t.Errorf("Filter of %d keys should contain key %s", numKeys, key)
+			}
+		}
+	}
+}
+
+func Test_bloomFilterShouldHaveLowFalsePositiveRate(t *testing.T) {
+	builder := newBloomFilterBuilder(10)
+	for i := 0; i < 10000; i++ {
+		builder.add(fmt.Sprintf("key-%d", i))
+	}
+	filter := builder.build()
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if filter.mayContain(fmt.Sprintf("missing-%d", i)) {
+			falsePositives++
+		}
+	}
+	// roughly 1% with 10 bits per key
+	if falsePositives > 300 {
+		t.Errorf("Expected false positive rate around 1%%, got %d out of 10000", falsePositives)
+	}
+}
+
+func Test_emptyBloomFilterShouldContainAnyKey(t *testing.T) {
+	var filter bloomFilter
+	if !filter.mayContain("key") {
+		t.Error("Missing filter should not rule out any key")
+	}
+}
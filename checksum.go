@@ -0,0 +1,78 @@
+package dbengine
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// ErrCorruption - data read from a file doesn't match its checksum or can't be decoded
+var ErrCorruption = errors.New("data corruption")
+
+// CorruptionError - describes where corrupted data is found, it matches `ErrCorruption` with `errors.Is`
+type CorruptionError struct {
+	File   string
+	Offset int64
+	Reason string
+}
+
+func (cErr *CorruptionError) Error() string {
+	return fmt.Sprintf("Corrupted data in file %s at offset %d - Error: %s", cErr.File, cErr.Offset, cErr.Reason)
+}
+
+func (cErr *CorruptionError) Unwrap() error {
+	return ErrCorruption
+}
+
+// checksumSize - size (in bytes) of the checksum appended to a block
+const checksumSize = 4
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// checksum - returns the CRC32C checksum of data
+func checksum(data []byte) uint32 {
+	return crc32.Checksum(data, crc32cTable)
+}
+
+// writeChecksummedData - writes data with a varint size prefix and a checksum suffix:
+// - <data size (varint)><data><checksum of data (fixed 32-bit, little endian)>
+func writeChecksummedData(w io.Writer, data []byte) (int, error) {
+	written, err := WriteDataWithVarintSizePrefix(w, data)
+	if err != nil {
+		return written, err
+	}
+
+	buf := make([]byte, checksumSize)
+	binary.LittleEndian.PutUint32(buf, checksum(data))
+	n, err := w.Write(buf)
+	return written + n, err
+}
+
+// readChecksummedData - parses the data written by `writeChecksummedData` from buf, which holds exactly what
+// was written. Data written before checksums existed has no checksum suffix, so it can't be verified, such data
+// is only accepted if requireChecksum is false. The data isn't verified if verify is false. Returns a description
+// of the problem if the data is corrupted.
+func readChecksummedData(buf []byte, verify, requireChecksum bool) ([]byte, string) {
+	size, n := binary.Uvarint(buf)
+	if n <= 0 || uint64(len(buf)-n) < size {
+		return nil, "invalid size prefix"
+	}
+	data := buf[n : n+int(size)]
+
+	switch rest := buf[n+int(size):]; len(rest) {
+	case 0:
+		if requireChecksum {
+			return nil, "missing checksum"
+		}
+		return data, ""
+	case checksumSize:
+		if verify && binary.LittleEndian.Uint32(rest) != checksum(data) {
+			return nil, "checksum mismatch"
+		}
+		return data, ""
+	default:
+		return nil, "invalid size prefix"
+	}
+}
@@ -0,0 +1,60 @@
+package dbengine
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// corruptFile - flips the bits of the byte at offset in the file
+func corruptFile(tb testing.TB, file string, offset int64) {
+	tb.Helper()
+
+	f, err := os.OpenFile(file, os.O_RDWR, 0)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+
+	b := make([]byte, 1)
+	if _, err := f.ReadAt(b, offset); err != nil {
+		tb.Fatal(err)
+	}
+	b[0] ^= 0xff
+	if _, err := f.WriteAt(b, offset); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func Test_readChecksummedDataShouldVerifyChecksum(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writeChecksummedData(buf, []byte("some data"))
+	written := buf.Bytes()
+
+	if data, reason := readChecksummedData(written, true, true); reason != "" || string(data) != "some data" {
+		t.Errorf("Expected data to be read back, got %q - %s", data, reason)
+	}
+
+	written[3] ^= 0xff
+	if _, reason := readChecksummedData(written, true, true); reason != "checksum mismatch" {
+		t.Errorf("Expected checksum mismatch, got %q", reason)
+	}
+	if _, reason := readChecksummedData(written, false, true); reason != "" {
+		t.Errorf("Data shouldn't be verified, got %q", reason)
+	}
+}
+
+func Test_readChecksummedDataShouldAcceptDataWithoutChecksum(t *testing.T) {
+	buf := &bytes.Buffer{}
+	WriteDataWithVarintSizePrefix(buf, []byte("some data"))
+
+	if data, reason := readChecksummedData(buf.Bytes(), true, false); reason != "" || string(data) != "some data" {
+		t.Errorf("Expected data written without checksum to be read back, got %q - %s", data, reason)
+	}
+	if _, reason := readChecksummedData(buf.Bytes(), true, true); reason != "missing checksum" {
+		t.Errorf("Expected data without checksum to be rejected when a checksum is required, got %q", reason)
+	}
+	if _, reason := readChecksummedData(buf.Bytes()[:5], true, false); reason != "invalid size prefix" {
+		t.Errorf("Expected truncated data to be rejected, got %q", reason)
+	}
+}
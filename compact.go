@@ -1,16 +1,26 @@
 package dbengine
 
 import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/DrakeW/go-db-engine/pb"
 	log "github.com/sirupsen/logrus"
 )
 
 // memtableCompactService - handles compacting memtable into sstable files into disk (a.k.a "minor compaction")
 type memtableCompactService struct {
 	db    *Database
+	lock  sync.Mutex
 	queue []MemTable
 	c     chan MemTable
+	done  chan struct{} // done - closed once the service has stopped
+	// err - the first serialization failure. Memtables can only be serialized in the order they're enqueued, so
+	// none is serialized after a failure, they stay in the queue (and keep serving reads) instead
+	err error
 }
 
 func newMemtableCompactService(db *Database) *memtableCompactService {
@@ -18,67 +28,448 @@ func newMemtableCompactService(db *Database) *memtableCompactService {
 		db:    db,
 		queue: make([]MemTable, 0),
 		c:     make(chan MemTable),
+		done:  make(chan struct{}),
 	}
 }
 
 // enqueue - add the input memtable to the compaction queue for async compaction at a later time
 func (mcs *memtableCompactService) enqueue(mem MemTable) {
-	mcs.c <- mem
+	// the memtable has to be visible in the queue before the service could possibly pick it up
+	mcs.lock.Lock()
 	mcs.queue = append(mcs.queue, mem)
+	mcs.lock.Unlock()
+
+	mcs.c <- mem
 }
 
 // getQueuedTables - get all the memtables that are in the compaction queue but not yet compacted
 // those tables should continue to serve get request before being serialized to disk.
+// Tables are ordered from the earliest enqueued to the latest.
 func (mcs *memtableCompactService) getQueuedTables() []MemTable {
-	return mcs.queue
+	mcs.lock.Lock()
+	defer mcs.lock.Unlock()
+
+	queued := make([]MemTable, len(mcs.queue))
+	copy(queued, mcs.queue)
+	return queued
 }
 
-// start - start the service to handle compaction tasks
+// start - start the service to handle compaction tasks, until stop is called
 func (mcs *memtableCompactService) start() {
-	for {
-		select {
-		case mem := <-mcs.c:
-			if err := mcs.serializeMemtable(mem); err != nil {
-				log.Fatalf("Failed to serialize memtable to sstable - Error: %s", err.Error())
-			}
-			mcs.queue = mcs.queue[1:]
+	defer close(mcs.done)
 
-			// delete the WAL since the wal isn't needed anymore for a memtable that's serialized already
-			if err := mem.Wal().Delete(); err != nil {
-				log.Warnf("Failed to delete WAL file %s after serializing its corresponding memtable - Error: %s", mem.Wal().File().Name(), err.Error())
-			}
-			log.Infof("Deleted WAL file %s", mem.Wal().File().Name())
+	for mem := range mcs.c {
+		if mcs.error() != nil {
+			continue
 		}
+		if err := mcs.serializeMemtable(mem); err != nil {
+			log.Errorf("Failed to serialize memtable to sstable - Error: %s", err.Error())
+			mcs.lock.Lock()
+			mcs.err = err
+			mcs.lock.Unlock()
+			continue
+		}
+		mcs.lock.Lock()
+		mcs.queue = mcs.queue[1:]
+		mcs.lock.Unlock()
+
+		// the shared WAL segments holding only writes serialized already aren't needed anymore
+		if mem.WalRange().Segment != 0 {
+			mcs.db.removeObsoleteWalSegments()
+			continue
+		}
+		// delete the WAL since the wal isn't needed anymore for a memtable that's serialized already
+		if err := mem.Wal().Delete(); err != nil {
+			log.Warnf("Failed to delete WAL file %s after serializing its corresponding memtable - Error: %s", mem.Wal().File().Name(), err.Error())
+		}
+		log.Infof("Deleted WAL file %s", mem.Wal().File().Name())
 	}
 }
 
-// serializeMemtable - serialize the input memtable into a sstable file
+// stop - waits for all the enqueued memtables to be serialized and stops the service, nothing can be enqueued
+// afterwards. Returns the serialization failure, if any
+func (mcs *memtableCompactService) stop() error {
+	close(mcs.c)
+	<-mcs.done
+	return mcs.error()
+}
+
+// error - returns the serialization failure, if any
+func (mcs *memtableCompactService) error() error {
+	mcs.lock.Lock()
+	defer mcs.lock.Unlock()
+
+	return mcs.err
+}
+
+// serializeMemtable - serialize the input memtable into a level 0 sstable file and record it in the manifest
 func (mcs *memtableCompactService) serializeMemtable(mem MemTable) error {
-	writer, err := NewBasicSSTableWriter(mcs.db.sstableDir, mcs.db.setting.SStableDatablockSizeByte)
+	records := mem.GetAll()
+	if len(records) == 0 {
+		return nil
+	}
+
+	setting := mcs.db.setting
+	writer, err := NewBasicSSTableWriter(
+		mcs.db.sstableDir, setting.SStableDatablockSizeByte, setting.BloomFilterBitsPerKey, setting.compressorForLevel(0),
+		setting.SStableIndexPartitionSizeByte,
+	)
 	if err != nil {
 		return err
 	}
 	if err = writer.Dump(mem); err != nil {
 		return err
 	}
+
+	fileInfo, err := os.Stat(writer.File())
+	if err != nil {
+		return err
+	}
+	edit := &pb.VersionEdit{
+		AddedFiles: []*pb.SSTableFileMeta{
+			{
+				// file number gets allocated by the manifest when the file is added
+				Filename:     filepath.Base(writer.File()),
+				Level:        0,
+				SmallestKey:  records[0].Key,
+				LargestKey:   records[len(records)-1].Key,
+				Size:         fileInfo.Size(),
+				CreationTime: time.Now().UnixNano(),
+			},
+		},
+	}
+	for _, record := range records {
+		if record.Seq > edit.LastSequence {
+			edit.LastSequence = record.Seq
+		}
+	}
+	if err = mcs.db.manifest.logAndApply(edit); err != nil {
+		return err
+	}
 	log.Infof("Serialized memtable to sstable at %s", writer.File())
 	return nil
 }
 
+// maxLevels - the max number of levels sstable files can be organized into
+const maxLevels = 7
+
 // sstableCompactService - compacting smaller sstable files into larger file (a.k.a "major compaction")
+//
+// The service checks periodically (every `interval`) with the configured `CompactionStrategy` if any sstable
+// files should be compacted, and keeps running the compactions picked by the strategy until there is none.
 type sstableCompactService struct {
 	db       *Database
+	lock     sync.Mutex // lock - makes sure only one compaction runs at a time
 	interval time.Duration
 	lastRun  time.Time
+	// compactPointers - largest key of the last file compacted at each level, so that compactions rotate
+	// through the key space of a level
+	compactPointers map[int]string
+	stopC           chan struct{} // stopC - closed to stop the service
+	done            chan struct{} // done - closed once the service has stopped
+	// err - failure of the last compaction run, nil if it succeeded
+	err error
+}
+
+// compaction - describes a compaction picked by a `CompactionStrategy`. inputs[0] are files at `level`,
+// inputs[1] are files at `outputLevel` that overlap with them. All the input files are merged into new
+// files at `outputLevel`.
+type compaction struct {
+	level       int
+	inputs      [2][]*SSTableFileMetadata
+	outputLevel int
+	// outputNumber - file number for the output when it goes to level 0. Level 0 files are ordered by number,
+	// the number is allocated before any newer file could be added so the output is ordered before them.
+	outputNumber uint64
+	// dropInputs - the input files are removed without being merged into any output
+	dropInputs bool
 }
 
 func newSSTableCompactService(db *Database) *sstableCompactService {
 	return &sstableCompactService{
-		db:       db,
-		interval: 5 * time.Second,
-		lastRun:  time.Now(),
+		db:              db,
+		interval:        db.setting.CompactionInterval,
+		lastRun:         time.Now(),
+		compactPointers: make(map[int]string),
+		stopC:           make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+}
+
+// start - start the service to check periodically if any sstable files need compaction, until stop is called
+func (scs *sstableCompactService) start() {
+	defer close(scs.done)
+
+	for {
+		timer := time.NewTimer(time.Until(scs.lastRun.Add(scs.interval)))
+		select {
+		case <-scs.stopC:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		err := scs.compact()
+		if err != nil {
+			log.Errorf("Failed to compact sstable files - Error: %s", err.Error())
+		}
+		scs.lock.Lock()
+		scs.err = err
+		scs.lock.Unlock()
+		scs.lastRun = time.Now()
+	}
+}
+
+// stop - stops the service once the compaction running (if any) is done, returns the failure of the last
+// compaction run
+func (scs *sstableCompactService) stop() error {
+	close(scs.stopC)
+	<-scs.done
+
+	scs.lock.Lock()
+	defer scs.lock.Unlock()
+	return scs.err
+}
+
+// compact - keep running compactions until the strategy doesn't pick any
+func (scs *sstableCompactService) compact() error {
+	scs.lock.Lock()
+	defer scs.lock.Unlock()
+
+	for {
+		v, fileNumber := scs.db.manifest.acquireVersionWithFileNumber()
+		c := scs.db.setting.CompactionStrategy.pickCompaction(scs, v)
+		var err error
+		if c != nil {
+			c.outputNumber = fileNumber
+			err = scs.runCompaction(v, c)
+		}
+		scs.db.manifest.releaseVersion(v)
+
+		if c == nil || err != nil {
+			return err
+		}
+	}
+}
+
+// maxBytesForLevel - returns the max total size of files at level (level >= 1)
+func (scs *sstableCompactService) maxBytesForLevel(level int) float64 {
+	maxBytes := float64(scs.db.setting.LevelSizeBaseByte)
+	for l := 1; l < level; l++ {
+		maxBytes *= float64(scs.db.setting.LevelSizeMultiplier)
+	}
+	return maxBytes
+}
+
+// runCompaction - merges the input files into new files at the next level and replaces the input files with
+// them in the manifest
+func (scs *sstableCompactService) runCompaction(v *version, c *compaction) error {
+	outputLevel := c.outputLevel
+	_, largest := keyRange(c.inputs[0])
+
+	if c.dropInputs {
+		edit := &pb.VersionEdit{}
+		for _, meta := range c.inputs[0] {
+			edit.DeletedFiles = append(edit.DeletedFiles, meta.toPb())
+		}
+		if err := scs.db.manifest.logAndApply(edit); err != nil {
+			return err
+		}
+		log.Infof("Dropped %d sstable files at level %d", len(c.inputs[0]), c.level)
+		return nil
 	}
+
+	// a file that doesn't overlap with any file at the next level can simply be moved down
+	if c.level > 0 && outputLevel == c.level+1 && len(c.inputs[0]) == 1 && len(c.inputs[1]) == 0 {
+		meta := c.inputs[0][0]
+		moved := meta.toPb()
+		moved.Level = uint32(outputLevel)
+		edit := &pb.VersionEdit{
+			DeletedFiles: []*pb.SSTableFileMeta{meta.toPb()},
+			AddedFiles:   []*pb.SSTableFileMeta{moved},
+		}
+		if err := scs.db.manifest.logAndApply(edit); err != nil {
+			return err
+		}
+		scs.compactPointers[c.level] = largest
+		log.Infof("Moved sstable file %s from level %d to level %d", meta.filename, c.level, outputLevel)
+		return nil
+	}
+
+	outputs, err := scs.mergeFiles(v, c)
+	if err != nil {
+		for _, output := range outputs {
+			os.Remove(filepath.Join(scs.db.sstableDir, output.Filename))
+		}
+		return err
+	}
+
+	edit := &pb.VersionEdit{AddedFiles: outputs}
+	for _, inputs := range c.inputs {
+		for _, meta := range inputs {
+			edit.DeletedFiles = append(edit.DeletedFiles, meta.toPb())
+		}
+	}
+	if err := scs.db.manifest.logAndApply(edit); err != nil {
+		return err
+	}
+	scs.compactPointers[c.level] = largest
+
+	log.Infof(
+		"Compacted %d files at level %d and %d files at level %d into %d files at level %d",
+		len(c.inputs[0]), c.level, len(c.inputs[1]), outputLevel, len(outputs), outputLevel,
+	)
+	return nil
+}
+
+// mergeFiles - merges records of the input files into output files of roughly the target file size, or a
+// single file if the output goes to level 0. Older versions of a key that no live snapshot can see are
+// dropped, and so are tombstones that have no older data to shadow
+func (scs *sstableCompactService) mergeFiles(v *version, c *compaction) ([]*pb.SSTableFileMeta, error) {
+	outputLevel := c.outputLevel
+	outputs := make([]*pb.SSTableFileMeta, 0)
+	snapshots := scs.db.snapshots.sequences()
+
+	// input files at `level` have newer data than the ones at `outputLevel`, and level 0 files are already
+	// ordered from the latest to the earliest
+	iters := make([]RecordIterator, 0)
+	for _, inputs := range c.inputs {
+		for _, meta := range inputs {
+			t, err := scs.db.tables.acquire(meta)
+			if err != nil {
+				return outputs, err
+			}
+			defer scs.db.tables.release(t)
+			// corrupted data must never be written into the output files
+			iters = append(iters, t.reader.newIterator(true))
+		}
+	}
+
+	var writer SSTableWriter
+	var output *pb.SSTableFileMeta
+	finishOutput := func() error {
+		if err := writer.Finish(); err != nil {
+			return err
+		}
+		fileInfo, err := os.Stat(writer.File())
+		if err != nil {
+			return err
+		}
+		output.Size = fileInfo.Size()
+		writer = nil
+		return nil
+	}
+
+	// lastStripe - the snapshot stripe of the previous version of the same key, -1 for the first version
+	lastStripe := -1
+	var lastKey string
+	it := newMergingIterator(iters...)
+	for it.First(); it.Valid(); it.Next() {
+		record := it.Record()
+		if record.Key != lastKey {
+			lastKey, lastStripe = record.Key, -1
+		}
+		// a version is only needed if it's the latest version some snapshot (or the latest state) can see,
+		// which is not the case if a later version falls into the same stripe
+		stripe := snapshotStripe(snapshots, record.Seq)
+		if stripe == lastStripe {
+			continue
+		}
+		lastStripe = stripe
+		if record.Tombstone && stripe == 0 && scs.isBaseLevelForKey(v, c, record.Key) {
+			continue
+		}
+
+		// all versions of a key go into the same file, so that files at level 1 and above never overlap
+		if writer != nil && outputLevel > 0 && record.Key != output.LargestKey &&
+			writer.Size() >= uint64(scs.db.setting.SStableTargetFileSizeByte) {
+			if err := finishOutput(); err != nil {
+				return outputs, err
+			}
+		}
+
+		if writer == nil {
+			var err error
+			setting := scs.db.setting
+			if writer, err = NewBasicSSTableWriter(
+				scs.db.sstableDir, setting.SStableDatablockSizeByte, setting.BloomFilterBitsPerKey,
+				setting.compressorForLevel(outputLevel), setting.SStableIndexPartitionSizeByte,
+			); err != nil {
+				return outputs, err
+			}
+			number := c.outputNumber
+			if outputLevel > 0 {
+				number = scs.db.manifest.newFileNumber()
+			}
+			output = &pb.SSTableFileMeta{
+				Number:       number,
+				Filename:     filepath.Base(writer.File()),
+				Level:        uint32(outputLevel),
+				SmallestKey:  record.Key,
+				CreationTime: time.Now().UnixNano(),
+			}
+			outputs = append(outputs, output)
+		}
+		if err := writer.Add(record); err != nil {
+			return outputs, err
+		}
+		output.LargestKey = record.Key
+	}
+	if err := it.Error(); err != nil {
+		return outputs, err
+	}
+
+	if writer != nil {
+		if err := finishOutput(); err != nil {
+			return outputs, err
+		}
+	}
+	return outputs, nil
+}
+
+// isBaseLevelForKey - returns true if no file older than the compaction inputs could possibly contain the key
+func (scs *sstableCompactService) isBaseLevelForKey(v *version, c *compaction, key string) bool {
+	inputs := make(map[uint64]bool)
+	var newestInput uint64
+	for _, files := range c.inputs {
+		for _, meta := range files {
+			inputs[meta.number] = true
+			if meta.number > newestInput {
+				newestInput = meta.number
+			}
+		}
+	}
+
+	for l := c.outputLevel; l < v.numLevels(); l++ {
+		for _, meta := range v.filesAt(l) {
+			// level 0 files newer than the inputs can't be shadowed by the tombstone
+			if inputs[meta.number] || (l == 0 && meta.number > newestInput) {
+				continue
+			}
+			if meta.containsKey(key) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// snapshotStripe - returns the index of the first snapshot that can see the version of sequence number seq,
+// len(snapshots) if only the latest state can see it. Versions of a key in the same stripe are seen by the
+// same set of snapshots. snapshots have to be in increasing order
+func snapshotStripe(snapshots []uint64, seq uint64) int {
+	return sort.Search(len(snapshots), func(i int) bool { return snapshots[i] >= seq })
 }
 
-// TODO: (p0) implement sstable compaction
-func (scs *sstableCompactService) start() {}
+// keyRange - returns the smallest and the largest key covered by the files
+func keyRange(files []*SSTableFileMetadata) (smallest, largest string) {
+	for i, meta := range files {
+		if i == 0 || meta.smallestKey < smallest {
+			smallest = meta.smallestKey
+		}
+		if i == 0 || meta.largestKey > largest {
+			largest = meta.largestKey
+		}
+	}
+	return smallest, largest
+}
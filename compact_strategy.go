@@ -0,0 +1,214 @@
+package dbengine
+
+import (
+	"math"
+	"time"
+)
+
+// CompactionStrategy - decides which sstable files get compacted and where the output goes. The strategy
+// used by a database is configured with `ConfigCompactionStrategy`, built-in strategies are:
+// - `LeveledCompactionStrategy` (default) - optimized for reads and space usage
+// - `SizeTieredCompactionStrategy` - optimized for write heavy workloads
+// - `FIFOCompactionStrategy` - never merges files, drops the oldest ones instead
+// Only the built-in strategies are supported, picking a compaction works on the internal state of the
+// database so the interface can't be implemented outside of this package.
+type CompactionStrategy interface {
+	// Name - returns the name of the strategy: "leveled", "size-tiered" or "fifo". The name is recorded in the
+	// manifest when the database is created (databases created before that are leveled), and reopening the
+	// database with a strategy of another name is refused since the files are laid out for the recorded one.
+	// The parameters of a strategy aren't part of its name and can change between opens.
+	Name() string
+
+	// pickCompaction - returns the next compaction to run for version v, nil if no compaction is needed
+	pickCompaction(scs *sstableCompactService, v *version) *compaction
+}
+
+// LeveledCompactionStrategy - LevelDB style leveled compaction. sstable files are organized into levels:
+// - level 0 holds files serialized from memtables, key ranges of the files may overlap
+// - level 1 to N each holds files with non-overlapping key ranges, and each level can hold
+// `LevelSizeMultiplier` times more data than the level above it
+// When level 0 has too many files or any other level has grown over its size limit, files from the level are
+// merged with the overlapping files from the next level into new files of the next level.
+type LeveledCompactionStrategy struct{}
+
+// NewLeveledCompactionStrategy - creates a leveled compaction strategy, level sizes are controlled by
+// `ConfigLevel0CompactionTrigger`, `ConfigLevelSizeBaseByte` and `ConfigLevelSizeMultiplier`
+func NewLeveledCompactionStrategy() *LeveledCompactionStrategy {
+	return &LeveledCompactionStrategy{}
+}
+
+// Name - returns the name of the strategy
+func (lcs *LeveledCompactionStrategy) Name() string {
+	return "leveled"
+}
+
+// pickCompaction - returns the compaction for the level that needs it the most, nil if no level needs one
+func (lcs *LeveledCompactionStrategy) pickCompaction(scs *sstableCompactService, v *version) *compaction {
+	// level 0 is scored by number of files since every one of them is looked at on reads, other levels are
+	// scored by total size
+	bestLevel := 0
+	bestScore := float64(len(v.filesAt(0))) / float64(scs.db.setting.Level0CompactionTrigger)
+	for level := 1; level < maxLevels-1; level++ {
+		score := float64(v.levelSize(level)) / scs.maxBytesForLevel(level)
+		if score > bestScore {
+			bestLevel, bestScore = level, score
+		}
+	}
+	if bestScore < 1 {
+		return nil
+	}
+
+	c := &compaction{level: bestLevel, outputLevel: bestLevel + 1}
+	if bestLevel == 0 {
+		c.inputs[0] = v.filesAt(0)
+	} else {
+		// pick the first file after the one compacted last time, wrap around to the beginning of the level
+		files := v.filesAt(bestLevel)
+		c.inputs[0] = files[:1]
+		for _, meta := range files {
+			if meta.smallestKey > scs.compactPointers[bestLevel] {
+				c.inputs[0] = []*SSTableFileMetadata{meta}
+				break
+			}
+		}
+	}
+	smallest, largest := keyRange(c.inputs[0])
+	c.inputs[1] = v.overlappingFiles(bestLevel+1, smallest, largest)
+	return c
+}
+
+// SizeTieredCompactionStrategy - size-tiered (a.k.a universal) compaction. All files stay at level 0, each
+// file being a sorted run. Runs of similar sizes are merged into a bigger run, so data gets rewritten far fewer
+// times than leveled compaction at the cost of more files to look at on reads and more temporary disk space.
+//
+// Runs are always picked starting from the latest one:
+// - if the total size of all the runs except the earliest is `MaxSizeAmplificationPercent` of the earliest
+// run or more, all the runs are merged into one
+// - otherwise the next (earlier) run is added to the candidates as long as it's not bigger than the candidates
+// combined by more than `SizeRatioPercent`, and the candidates are merged if there are at least `MinMergeWidth`
+// of them
+// - if there are still `Level0CompactionTrigger` runs or more, the latest runs are merged to bring the number of
+// runs down
+type SizeTieredCompactionStrategy struct {
+	SizeRatioPercent            uint
+	MinMergeWidth               int
+	MaxMergeWidth               int
+	MaxSizeAmplificationPercent uint
+}
+
+// NewSizeTieredCompactionStrategy - creates a size-tiered compaction strategy with default options
+func NewSizeTieredCompactionStrategy() *SizeTieredCompactionStrategy {
+	return &SizeTieredCompactionStrategy{
+		SizeRatioPercent:            1,
+		MinMergeWidth:               2,
+		MaxMergeWidth:               math.MaxInt32,
+		MaxSizeAmplificationPercent: 200,
+	}
+}
+
+// Name - returns the name of the strategy
+func (stcs *SizeTieredCompactionStrategy) Name() string {
+	return "size-tiered"
+}
+
+// pickCompaction - returns the compaction of the latest runs that should be merged, nil if none
+func (stcs *SizeTieredCompactionStrategy) pickCompaction(scs *sstableCompactService, v *version) *compaction {
+	runs := v.filesAt(0)
+	if len(runs) < stcs.MinMergeWidth || len(runs) < 2 {
+		return nil
+	}
+
+	// size amplification - how much extra space the latest runs take compared to the earliest one
+	var newerSize int64
+	for _, run := range runs[:len(runs)-1] {
+		newerSize += run.size
+	}
+	if float64(newerSize)*100 >= float64(stcs.MaxSizeAmplificationPercent)*float64(runs[len(runs)-1].size) {
+		return stcs.newCompaction(runs)
+	}
+
+	// size ratio - merge runs of similar sizes
+	candidateSize := runs[0].size
+	numCandidates := 1
+	for _, run := range runs[1:] {
+		if numCandidates >= stcs.MaxMergeWidth {
+			break
+		}
+		if float64(run.size)*100 > float64(candidateSize)*float64(100+stcs.SizeRatioPercent) {
+			break
+		}
+		candidateSize += run.size
+		numCandidates++
+	}
+	if numCandidates >= stcs.MinMergeWidth {
+		return stcs.newCompaction(runs[:numCandidates])
+	}
+
+	// too many runs - merge just enough of the latest runs to go back under the trigger
+	if trigger := scs.db.setting.Level0CompactionTrigger; len(runs) >= trigger {
+		numCandidates = len(runs) - trigger + 2
+		if numCandidates > stcs.MaxMergeWidth {
+			numCandidates = stcs.MaxMergeWidth
+		}
+		return stcs.newCompaction(runs[:numCandidates])
+	}
+	return nil
+}
+
+func (stcs *SizeTieredCompactionStrategy) newCompaction(runs []*SSTableFileMetadata) *compaction {
+	c := &compaction{level: 0, outputLevel: 0}
+	c.inputs[0] = runs
+	return c
+}
+
+// FIFOCompactionStrategy - never merges sstable files, instead the earliest files at level 0 are dropped once
+// the total size of files goes over `MaxTotalSizeByte` or the files are older than `MaxFileAge`. Useful for
+// data that's only valuable for a limited time (e.g. metrics). A limit of 0 means no limit.
+//
+// Note that data dropped this way is gone even if it's never been overwritten or deleted.
+type FIFOCompactionStrategy struct {
+	MaxTotalSizeByte int64
+	MaxFileAge       time.Duration
+}
+
+// NewFIFOCompactionStrategy - creates a FIFO compaction strategy
+func NewFIFOCompactionStrategy(maxTotalSizeByte int64, maxFileAge time.Duration) *FIFOCompactionStrategy {
+	return &FIFOCompactionStrategy{
+		MaxTotalSizeByte: maxTotalSizeByte,
+		MaxFileAge:       maxFileAge,
+	}
+}
+
+// Name - returns the name of the strategy
+func (fcs *FIFOCompactionStrategy) Name() string {
+	return "fifo"
+}
+
+// pickCompaction - returns the compaction that drops the files over the size or age budget, nil if none
+func (fcs *FIFOCompactionStrategy) pickCompaction(scs *sstableCompactService, v *version) *compaction {
+	files := v.filesAt(0)
+
+	var totalSize int64
+	for _, meta := range files {
+		totalSize += meta.size
+	}
+
+	// files are ordered from the latest to the earliest, drop from the end
+	numToDrop := 0
+	for i := len(files) - 1; i >= 0; i-- {
+		overSize := fcs.MaxTotalSizeByte > 0 && totalSize > fcs.MaxTotalSizeByte
+		overAge := fcs.MaxFileAge > 0 && time.Since(files[i].creationTime) > fcs.MaxFileAge
+		if !overSize && !overAge {
+			break
+		}
+		totalSize -= files[i].size
+		numToDrop++
+	}
+	if numToDrop == 0 {
+		return nil
+	}
+
+	c := &compaction{level: 0, outputLevel: 0, dropInputs: true}
+	c.inputs[0] = files[len(files)-numToDrop:]
+	return c
+}
@@ -0,0 +1,234 @@
+package dbengine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func getTestCompactionDB(t *testing.T, configs ...DBConfig) *Database {
+	t.Helper()
+
+	db, err := NewDatabase(append([]DBConfig{
+		ConfigDBDir(setupTestDBDir(t)),
+		ConfigMemtableSizeByte(512),
+		ConfigSStableDatablockSizeByte(512 / 4),
+		ConfigSStableTargetFileSizeByte(1024),
+		ConfigLevel0CompactionTrigger(2),
+		ConfigLevelSizeBaseByte(4 * 1024),
+		ConfigLevelSizeMultiplier(2),
+		ConfigLogLevel(log.InfoLevel),
+	}, configs...)...)
+	if err != nil {
+		t.Fatalf("Failed to initialize database - Error: %s", err.Error())
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// readAllRecords - reads every record stored in all the sstable files of the current version
+func readAllRecords(t *testing.T, db *Database) []*MemtableRecord {
+	t.Helper()
+
+	records := make([]*MemtableRecord, 0)
+	for _, meta := range db.manifest.currentVersion().files() {
+		reader, err := NewBasicSSTableReader(filepath.Join(db.sstableDir, meta.filename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		it := reader.NewIterator()
+		for it.First(); it.Valid(); it.Next() {
+			records = append(records, it.Record())
+		}
+		reader.Close()
+	}
+	return records
+}
+
+func Test_leveledCompactionShouldKeepLatestValues(t *testing.T) {
+	db := getTestCompactionDB(t)
+
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 500; i++ {
+			db.Write(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%03d-%d", i, round)))
+		}
+	}
+	for i := 0; i < 500; i += 5 {
+		db.Delete(fmt.Sprintf("key-%03d", i))
+	}
+	waitForMemtableSerialization(t, db)
+
+	if err := db.compactSvc.compact(); err != nil {
+		t.Fatalf("Failed to compact - Error: %s", err.Error())
+	}
+
+	for i := 0; i < 500; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		value, err := db.Get(key)
+		if err != nil {
+			t.Errorf("Failed to read key %s from db - Error: %s", key, err.Error())
+		}
+		if i%5 == 0 && value != nil {
+			t.Errorf("Deleted key %s should not be found, got %s", key, string(value))
+		}
+		if i%5 != 0 && string(value) != fmt.Sprintf("value-%03d-2", i) {
+			t.Errorf("Latest value for key %s not found, got %s", key, string(value))
+		}
+	}
+}
+
+func Test_leveledCompactionShouldKeepLevelsWithinLimits(t *testing.T) {
+	db := getTestCompactionDB(t)
+
+	for i := 0; i < 2000; i++ {
+		db.Write(fmt.Sprintf("key-%04d", i), []byte(fmt.Sprintf("value-%04d", i)))
+	}
+	waitForMemtableSerialization(t, db)
+
+	if err := db.compactSvc.compact(); err != nil {
+		t.Fatalf("Failed to compact - Error: %s", err.Error())
+	}
+
+	v := db.manifest.currentVersion()
+	if len(v.filesAt(0)) >= db.setting.Level0CompactionTrigger {
+		t.Errorf("Level 0 should have less than %d files, got %d", db.setting.Level0CompactionTrigger, len(v.filesAt(0)))
+	}
+	for level := 1; level < v.numLevels(); level++ {
+		files := v.filesAt(level)
+		for i := 1; i < len(files); i++ {
+			if files[i-1].largestKey >= files[i].smallestKey {
+				t.Errorf("Files at level %d overlap - %s and %s", level, files[i-1].filename, files[i].filename)
+			}
+		}
+		if level < maxLevels-1 && float64(v.levelSize(level)) > db.compactSvc.maxBytesForLevel(level) {
+			t.Errorf("Level %d has grown over its size limit - size %d", level, v.levelSize(level))
+		}
+	}
+}
+
+func Test_leveledCompactionShouldDropTombstonesAtBottomLevel(t *testing.T) {
+	db := getTestCompactionDB(t)
+
+	for i := 0; i < 200; i++ {
+		db.Write(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%03d", i)))
+	}
+	for i := 0; i < 200; i++ {
+		db.Delete(fmt.Sprintf("key-%03d", i))
+	}
+	// make sure the last tombstones are serialized too
+	for i := 0; i < 100; i++ {
+		db.Write(fmt.Sprintf("other-%03d", i), []byte(fmt.Sprintf("value-%03d", i)))
+	}
+	waitForMemtableSerialization(t, db)
+
+	if err := db.compactSvc.compact(); err != nil {
+		t.Fatalf("Failed to compact - Error: %s", err.Error())
+	}
+
+	for _, record := range readAllRecords(t, db) {
+		if record.Tombstone {
+			t.Errorf("Tombstone of key %s should have been dropped", record.Key)
+		}
+	}
+}
+
+func Test_compactionShouldDeleteObsoleteFiles(t *testing.T) {
+	db := getTestCompactionDB(t)
+
+	for i := 0; i < 1000; i++ {
+		db.Write(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%03d", i)))
+	}
+	waitForMemtableSerialization(t, db)
+
+	if err := db.compactSvc.compact(); err != nil {
+		t.Fatalf("Failed to compact - Error: %s", err.Error())
+	}
+
+	files, _ := ioutil.ReadDir(db.sstableDir)
+	liveFiles := db.manifest.currentVersion().files()
+	if len(files) != len(liveFiles) {
+		t.Errorf("Expected %d sstable files on disk, got %d", len(liveFiles), len(files))
+	}
+}
+
+func Test_sizeTieredCompactionShouldMergeRunsWithinLevel0(t *testing.T) {
+	db := getTestCompactionDB(t, ConfigCompactionStrategy(NewSizeTieredCompactionStrategy()))
+
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 500; i++ {
+			db.Write(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%03d-%d", i, round)))
+		}
+	}
+	for i := 0; i < 500; i += 5 {
+		db.Delete(fmt.Sprintf("key-%03d", i))
+	}
+	waitForMemtableSerialization(t, db)
+
+	if err := db.compactSvc.compact(); err != nil {
+		t.Fatalf("Failed to compact - Error: %s", err.Error())
+	}
+
+	v := db.manifest.currentVersion()
+	if v.numLevels() > 1 && len(v.filesAt(1)) > 0 {
+		t.Error("Size-tiered compaction should keep all files at level 0")
+	}
+	if len(v.filesAt(0)) >= db.setting.Level0CompactionTrigger {
+		t.Errorf("Level 0 should have less than %d runs, got %d", db.setting.Level0CompactionTrigger, len(v.filesAt(0)))
+	}
+	for i := 0; i < 500; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		value, _ := db.Get(key)
+		if i%5 == 0 && value != nil {
+			t.Errorf("Deleted key %s should not be found, got %s", key, string(value))
+		}
+		if i%5 != 0 && string(value) != fmt.Sprintf("value-%03d-2", i) {
+			t.Errorf("Latest value for key %s not found, got %s", key, string(value))
+		}
+	}
+}
+
+func Test_fifoCompactionShouldDropEarliestFilesOverSizeLimit(t *testing.T) {
+	db := getTestCompactionDB(t, ConfigCompactionStrategy(NewFIFOCompactionStrategy(4*1024, 0)))
+
+	for i := 0; i < 1000; i++ {
+		db.Write(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%03d", i)))
+	}
+	waitForMemtableSerialization(t, db)
+
+	if err := db.compactSvc.compact(); err != nil {
+		t.Fatalf("Failed to compact - Error: %s", err.Error())
+	}
+
+	v := db.manifest.currentVersion()
+	if size := v.levelSize(0); size > 4*1024 || size == 0 {
+		t.Errorf("Total size of files should be within the limit, got %d", size)
+	}
+	if value, _ := db.Get("key-000"); value != nil {
+		t.Errorf("Earliest data should have been dropped, got %s", string(value))
+	}
+	if value, _ := db.Get("key-991"); string(value) != "value-991" {
+		t.Errorf("Latest data should be kept, got %s", string(value))
+	}
+}
+
+func Test_fifoCompactionShouldDropFilesOverMaxAge(t *testing.T) {
+	db := getTestCompactionDB(t, ConfigCompactionStrategy(NewFIFOCompactionStrategy(0, time.Millisecond)))
+
+	for i := 0; i < 100; i++ {
+		db.Write(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%03d", i)))
+	}
+	waitForMemtableSerialization(t, db)
+	time.Sleep(10 * time.Millisecond)
+
+	if err := db.compactSvc.compact(); err != nil {
+		t.Fatalf("Failed to compact - Error: %s", err.Error())
+	}
+
+	if files := db.manifest.currentVersion().files(); len(files) != 0 {
+		t.Errorf("All files should have been dropped, got %d", len(files))
+	}
+}
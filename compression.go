@@ -0,0 +1,225 @@
+package dbengine
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/golang/snappy"
+)
+
+// Compressor - compresses the data blocks of sstable files. The id of the compressor is stored along with
+// every block it compresses, so a block can always be decompressed no matter which compressor the database is
+// configured with when it's read. A custom compressor has to be registered with `RegisterCompressor` before any
+// database using it is opened.
+type Compressor interface {
+	// ID - returns the id stored with the blocks compressed, unique among the compressors registered
+	ID() uint8
+
+	// Name - returns a human readable name of the compressor
+	Name() string
+
+	// Compress - returns the compressed data
+	Compress(data []byte) ([]byte, error)
+
+	// Decompress - returns the data compressed by `Compress`
+	Decompress(compressed []byte) ([]byte, error)
+}
+
+// ids of the built-in compressors
+const (
+	noCompressionID uint8 = iota
+	snappyCompressionID
+	flateCompressionID
+	zlibCompressionID
+)
+
+var (
+	// NoCompression - stores blocks as they are
+	NoCompression Compressor = noCompressor{}
+	// SnappyCompression - fast compression with a moderate compression ratio
+	SnappyCompression Compressor = snappyCompressor{}
+)
+
+// NewFlateCompressor - returns a compressor using DEFLATE at level (see `compress/flate`), slower than snappy but
+// with a better compression ratio
+func NewFlateCompressor(level int) Compressor {
+	return &flateCompressor{level: level}
+}
+
+// NewZlibCompressor - returns a compressor using zlib at level (see `compress/zlib`), DEFLATE with a checksum
+// of the data
+func NewZlibCompressor(level int) Compressor {
+	return &zlibCompressor{level: level}
+}
+
+var compressorRegistry = struct {
+	lock        sync.RWMutex
+	compressors map[uint8]Compressor
+}{
+	compressors: map[uint8]Compressor{
+		noCompressionID:     NoCompression,
+		snappyCompressionID: SnappyCompression,
+		flateCompressionID:  NewFlateCompressor(flate.DefaultCompression),
+		zlibCompressionID:   NewZlibCompressor(zlib.DefaultCompression),
+	},
+}
+
+// RegisterCompressor - registers a custom compressor so that the blocks it compresses can be decompressed.
+// Returns an error if its id is taken by another compressor already.
+func RegisterCompressor(c Compressor) error {
+	compressorRegistry.lock.Lock()
+	defer compressorRegistry.lock.Unlock()
+
+	if registered, ok := compressorRegistry.compressors[c.ID()]; ok {
+		return fmt.Errorf("Compressor id %d is already used by compressor %s", c.ID(), registered.Name())
+	}
+	compressorRegistry.compressors[c.ID()] = c
+	return nil
+}
+
+// compressorByID - returns the compressor registered with id
+func compressorByID(id uint8) (Compressor, bool) {
+	compressorRegistry.lock.RLock()
+	defer compressorRegistry.lock.RUnlock()
+
+	c, ok := compressorRegistry.compressors[id]
+	return c, ok
+}
+
+// checkCompressorRegistered - returns an error unless c is nil or registered under its id, the blocks it
+// compresses couldn't be decompressed otherwise. Compressors of the same name are taken to be the same, such as
+// two flate compressors with different levels.
+func checkCompressorRegistered(c Compressor) error {
+	if c == nil {
+		return nil
+	}
+	registered, ok := compressorByID(c.ID())
+	if !ok {
+		return fmt.Errorf("Compressor %s with id %d isn't registered", c.Name(), c.ID())
+	}
+	if registered.Name() != c.Name() {
+		return fmt.Errorf(
+			"Compressor id %d of compressor %s is registered to compressor %s", c.ID(), c.Name(), registered.Name(),
+		)
+	}
+	return nil
+}
+
+// compressorName - returns the name of the compressor, "none" if it's nil
+func compressorName(c Compressor) string {
+	if c == nil {
+		return NoCompression.Name()
+	}
+	return c.Name()
+}
+
+// compressBlock - compresses the block with c and appends the id of the compressor used. The block is stored
+// uncompressed if compression doesn't shrink it by at least 1/8, since decompressing it wouldn't be worth it
+func compressBlock(c Compressor, raw []byte) ([]byte, error) {
+	if c != nil && c.ID() != noCompressionID {
+		compressed, err := c.Compress(raw)
+		if err != nil {
+			return nil, err
+		}
+		if len(compressed) < len(raw)-len(raw)/8 {
+			return append(compressed, c.ID()), nil
+		}
+	}
+	return append(raw[:len(raw):len(raw)], noCompressionID), nil
+}
+
+// decompressBlock - decompresses a block compressed by `compressBlock`
+func decompressBlock(block []byte) ([]byte, error) {
+	if len(block) == 0 {
+		return nil, fmt.Errorf("missing compressor id")
+	}
+	id := block[len(block)-1]
+	c, ok := compressorByID(id)
+	if !ok {
+		return nil, fmt.Errorf("unknown compressor id %d", id)
+	}
+	return c.Decompress(block[:len(block)-1])
+}
+
+type noCompressor struct{}
+
+func (noCompressor) ID() uint8                              { return noCompressionID }
+func (noCompressor) Name() string                           { return "none" }
+func (noCompressor) Compress(data []byte) ([]byte, error)   { return data, nil }
+func (noCompressor) Decompress(data []byte) ([]byte, error) { return data, nil }
+
+type snappyCompressor struct{}
+
+func (snappyCompressor) ID() uint8    { return snappyCompressionID }
+func (snappyCompressor) Name() string { return "snappy" }
+
+func (snappyCompressor) Compress(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+func (snappyCompressor) Decompress(compressed []byte) ([]byte, error) {
+	return snappy.Decode(nil, compressed)
+}
+
+type flateCompressor struct {
+	level int
+}
+
+func (c *flateCompressor) ID() uint8    { return flateCompressionID }
+func (c *flateCompressor) Name() string { return "flate" }
+
+func (c *flateCompressor) Compress(data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w, err := flate.NewWriter(buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *flateCompressor) Decompress(compressed []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(compressed))
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+type zlibCompressor struct {
+	level int
+}
+
+func (c *zlibCompressor) ID() uint8    { return zlibCompressionID }
+func (c *zlibCompressor) Name() string { return "zlib" }
+
+func (c *zlibCompressor) Compress(data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w, err := zlib.NewWriterLevel(buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *zlibCompressor) Decompress(compressed []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
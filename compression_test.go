@@ -0,0 +1,155 @@
+package dbengine
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_compressBlockShouldRoundTripWithBuiltInCompressors(t *testing.T) {
+	raw := []byte(strings.Repeat("key-001 value-001 ", 100))
+	compressors := []Compressor{
+		NoCompression, SnappyCompression, NewFlateCompressor(flate.BestCompression), NewZlibCompressor(flate.BestSpeed),
+	}
+	for _, c := range compressors {
+		block, err := compressBlock(c, raw)
+		if err != nil {
+			t.Fatalf("Failed to compress with %s - Error: %s", c.Name(), err.Error())
+		}
+		if block[len(block)-1] != c.ID() {
+			t.Errorf("Expected compressor id %d to be stored with the block, got %d", c.ID(), block[len(block)-1])
+		}
+		if c != NoCompression && len(block) >= len(raw) {
+			t.Errorf("Expected %s to shrink the block, got %d bytes from %d", c.Name(), len(block), len(raw))
+		}
+
+		data, err := decompressBlock(block)
+		if err != nil || !bytes.Equal(data, raw) {
+			t.Errorf("Block compressed by %s didn't round trip - Error: %v", c.Name(), err)
+		}
+	}
+}
+
+func Test_compressBlockShouldStoreIncompressibleBlockUncompressed(t *testing.T) {
+	raw := make([]byte, 1024)
+	rand.New(rand.NewSource(1)).Read(raw)
+
+	block, _ := compressBlock(NewFlateCompressor(flate.BestCompression), raw)
+	if block[len(block)-1] != noCompressionID || !bytes.Equal(block[:len(block)-1], raw) {
+		t.Error("Block that doesn't shrink enough should be stored uncompressed")
+	}
+	if data, err := decompressBlock(block); err != nil || !bytes.Equal(data, raw) {
+		t.Errorf("Uncompressed block didn't round trip - Error: %v", err)
+	}
+}
+
+// halveCompressor - a custom compressor for data made of two identical halves, it keeps only one of them
+type halveCompressor struct{}
+
+func (halveCompressor) ID() uint8    { return 200 }
+func (halveCompressor) Name() string { return "test-halve" }
+func (halveCompressor) Compress(data []byte) ([]byte, error) {
+	return data[:len(data)/2], nil
+}
+func (halveCompressor) Decompress(compressed []byte) ([]byte, error) {
+	return append(compressed, compressed...), nil
+}
+
+func Test_RegisterCompressorShouldMakeCustomCompressorReadable(t *testing.T) {
+	if err := RegisterCompressor(halveCompressor{}); err != nil {
+		t.Fatalf("Failed to register compressor - Error: %s", err.Error())
+	}
+	if err := RegisterCompressor(NewZlibCompressor(flate.BestSpeed)); err == nil {
+		t.Error("Compressor with an id already taken shouldn't be registered")
+	}
+
+	raw := []byte(strings.Repeat("ab", 50))
+	block, _ := compressBlock(halveCompressor{}, raw)
+	if data, err := decompressBlock(block); err != nil || !bytes.Equal(data, raw) {
+		t.Errorf("Block compressed by custom compressor didn't round trip - Error: %v", err)
+	}
+	if _, err := decompressBlock([]byte{1, 2, 3, 250}); err == nil {
+		t.Error("Block compressed by an unknown compressor shouldn't be decompressed")
+	}
+}
+
+// impostorCompressor - a custom compressor that claims the id of snappy
+type impostorCompressor struct{ halveCompressor }
+
+func (impostorCompressor) ID() uint8    { return snappyCompressionID }
+func (impostorCompressor) Name() string { return "test-impostor" }
+
+// unregisteredCompressor - a custom compressor never registered
+type unregisteredCompressor struct{ halveCompressor }
+
+func (unregisteredCompressor) ID() uint8    { return 201 }
+func (unregisteredCompressor) Name() string { return "test-unregistered" }
+
+func Test_dbShouldRefuseToOpenWithCompressorsNotRegistered(t *testing.T) {
+	testDBDir := setupTestDBDir(t)
+	defer os.RemoveAll(testDBDir)
+
+	configs := map[string]DBConfig{
+		"unregistered compressor":       ConfigCompressor(unregisteredCompressor{}),
+		"compressor with id taken":      ConfigCompressor(impostorCompressor{}),
+		"unregistered level compressor": ConfigLevelCompressors(SnappyCompression, unregisteredCompressor{}),
+	}
+	for name, config := range configs {
+		if db, err := NewDatabase(ConfigDBDir(testDBDir), config); err == nil {
+			db.Close()
+			t.Errorf("%s - expected database to refuse to open", name)
+		}
+	}
+
+	db, err := NewDatabase(ConfigDBDir(testDBDir), ConfigLevelCompressors(nil, NewFlateCompressor(flate.BestSpeed)))
+	if err != nil {
+		t.Fatalf("Failed to open database with registered compressors - Error: %s", err.Error())
+	}
+	db.Close()
+}
+
+func Test_sstablesWithDifferentCompressorsShouldBeReadable(t *testing.T) {
+	compressors := []Compressor{
+		nil, SnappyCompression, NewFlateCompressor(flate.DefaultCompression), NewZlibCompressor(flate.DefaultCompression),
+	}
+	for _, c := range compressors {
+		s, _ := NewBasicSSTableWriter(os.TempDir(), 256, 0, c, 0)
+		s.Dump(getTestMemtable(t, 100))
+
+		sr, err := NewBasicSSTableReader(s.File())
+		if err != nil {
+			t.Fatalf("Failed to open sstable - Error: %s", err.Error())
+		}
+		if name := sr.Properties().Compression; name != compressorName(c) {
+			t.Errorf("Expected compression %s to be recorded, got %s", compressorName(c), name)
+		}
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("key-%03d", i)
+			record, err := sr.Get(key, maxSequence)
+			if err != nil || record == nil || string(record.Value) != fmt.Sprintf("value-%03d", i) {
+				t.Errorf("Key %s should be found with compression %s, got %v - Error: %v",
+					key, compressorName(c), record, err)
+			}
+		}
+		sr.Close()
+	}
+}
+
+func Test_compressorForLevelShouldFallBackToLastLevel(t *testing.T) {
+	flateCompressor := NewFlateCompressor(flate.BestCompression)
+	setting := generateDBSetting(ConfigLevelCompressors(NoCompression, SnappyCompression, flateCompressor))
+	expected := []Compressor{NoCompression, SnappyCompression, flateCompressor, flateCompressor}
+	for level, c := range expected {
+		if setting.compressorForLevel(level) != c {
+			t.Errorf("Unexpected compressor at level %d - %s", level, setting.compressorForLevel(level).Name())
+		}
+	}
+
+	if generateDBSetting().compressorForLevel(3) != SnappyCompression {
+		t.Error("Snappy should be used at every level by default")
+	}
+}
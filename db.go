@@ -1,51 +1,101 @@
 package dbengine
 
 import (
-	"io/ioutil"
+	"errors"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
-// TODO: (p3) implement saving of database configs & load an existing database
+var (
+	// ErrDBClosed - the database has been closed
+	ErrDBClosed = errors.New("database is closed")
+	// ErrWriteStall - a write with `WriteOptions.NoSlowdown` set would have to wait for a memtable to be
+	// serialized
+	ErrWriteStall = errors.New("write stalled waiting for memtable serialization")
+)
 
-// Database - something that you can write data to and read data from
+// Database - something that you can write data to and read data from. It's safe for concurrent use by
+// multiple goroutines
 type Database struct {
-	setting    *DBSetting
-	walDir     string
+	setting *DBSetting
+	// walDir - where WAL files of a memtable each were written before the WAL was shared, they're only recovered
+	walDir string
+	// wal - the WAL shared by all the memtables
+	wal        *SegmentedWal
 	sstableDir string
+	// curMem - the memtable being written, memLock guards swapping it so that readers never wait for a rotation
+	// longer than the swap itself. Writers hold writeLock, so they can read it without memLock
 	curMem     MemTable
+	memLock    sync.RWMutex
+	manifest   *manifest
 	memSvc     *memtableCompactService
 	compactSvc *sstableCompactService
+	tables     *tableCache
+	blocks     *blockCache
+	// lastSeq - sequence number of the latest write, every write gets the next sequence number
+	lastSeq   uint64
+	snapshots *snapshotList
+	// writeLock - makes sure writes are applied one at a time, so that sequence numbers are assigned in order
+	writeLock sync.Mutex
+	// writes - groups concurrent writes so that they share a WAL log, see `writeQueue`
+	writes *writeQueue
+	// locks - key locks held by pessimistic transactions, which every write has to respect
+	locks *lockManager
+	// closed - set to 1 once Close is called, accessed atomically
+	closed  int32
+	logFile *os.File
 }
 
 // SSTableFileMetadata - metadata about sstable file
 type SSTableFileMetadata struct {
+	number       uint64
 	filename     string
+	level        int
+	smallestKey  string
+	largestKey   string
 	size         int64
-	lastModified time.Time
+	creationTime time.Time
 }
 
-// NewDatabase - creates a new database instance
+// NewDatabase - creates a new database instance, or opens the existing database under the configured
+// DB directory. Writes that were not yet serialized into sstable files when the previous process stopped
+// are recovered from the WAL files left behind.
 func NewDatabase(configs ...DBConfig) (*Database, error) {
 	setting := generateDBSetting(configs...)
+	if err := setting.checkCompressors(); err != nil {
+		return nil, err
+	}
 	walDir := filepath.Join(setting.DBDir, "wal")
+	segmentDir := setting.walSegmentDir()
 	sstableDir := filepath.Join(setting.DBDir, "sstable")
 
-	if err := os.Mkdir(walDir, 0700); err != nil {
+	if err := os.MkdirAll(walDir, 0700); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(segmentDir, 0700); err != nil {
 		return nil, err
 	}
-	if err := os.Mkdir(sstableDir, 0700); err != nil {
+	if err := os.MkdirAll(sstableDir, 0700); err != nil {
 		return nil, err
 	}
 
+	blocks := newBlockCache(int(setting.BlockCacheSizeByte))
 	db := &Database{
 		setting:    setting,
 		walDir:     walDir,
 		sstableDir: sstableDir,
-		curMem:     NewBasicMemTable(walDir, setting.WalStrictModeOn),
+		snapshots:  newSnapshotList(),
+		locks:      newLockManager(),
+		writes:     newWriteQueue(),
+		tables: newTableCache(
+			sstableDir, setting.MaxOpenFiles, blocks, setting.PinIndexAndFilterBlocks, setting.VerifyChecksums,
+		),
+		blocks: blocks,
 	}
 
 	db.memSvc = newMemtableCompactService(db)
@@ -55,12 +105,206 @@ func NewDatabase(configs ...DBConfig) (*Database, error) {
 		return nil, err
 	}
 
+	m, err := openManifest(setting.DBDir, sstableDir, setting)
+	if err != nil {
+		db.abortOpen()
+		return nil, err
+	}
+	m.tables = db.tables
+	db.manifest = m
+	db.lastSeq = m.lastSequence
+
+	// the segments left behind are kept to be recovered, new writes go to a segment after them
+	wal, err := OpenSegmentedWal(segmentDir, setting.WalSegmentSizeByte, setting.WalStrictModeOn)
+	if err != nil {
+		db.abortOpen()
+		return nil, err
+	}
+	db.wal = wal
+	// recovered memtables are serialized as they're enqueued, compactions only start once recovery succeeds
 	go db.memSvc.start()
+	if err := db.recoverMemtables(); err != nil {
+		db.abortOpen()
+		return nil, err
+	}
 	go db.compactSvc.start()
+	db.memLock.Lock()
+	db.curMem = newSegmentedMemTable(wal, wal.Segment())
+	db.memLock.Unlock()
+	db.removeObsoleteWalSegments()
 
 	return db, nil
 }
 
+// abortOpen - releases what has been acquired by `NewDatabase` when the database fails to open. The memtables
+// recovered so far are serialized, the writes in the WAL left to recover are kept for the next open.
+func (db *Database) abortOpen() {
+	atomic.StoreInt32(&db.closed, 1)
+	// the memtable service is started once the WAL is open
+	if db.wal != nil {
+		db.memSvc.stop()
+		for _, queued := range db.memSvc.getQueuedTables() {
+			if queued.WalRange().Segment == 0 {
+				queued.Wal().Close()
+			}
+		}
+		db.wal.Close()
+	}
+
+	db.tables.close()
+	if db.manifest != nil {
+		db.manifest.close()
+	}
+	if log.StandardLogger().Out == db.logFile {
+		log.SetOutput(os.Stderr)
+	}
+	db.logFile.Close()
+}
+
+// recoverMemtables - rebuilds memtables from the WAL left behind by a previous process and sends them for
+// serialization in the order they were written. Damaged logs are handled according to the configured
+// `WalRecoveryMode`, the ones dropped are reported in the log file.
+func (db *Database) recoverMemtables() error {
+	// writes up to the last sequence number in the manifest are in sstable files already
+	persistedSeq := db.lastSeq
+
+	// WAL files of a memtable each, written before the WAL was shared, are older than any segment
+	walFiles, err := ListWalFiles(db.walDir)
+	if err != nil {
+		return err
+	}
+
+	mode := db.setting.WalRecoveryMode
+	discard := false // discard - set once point-in-time recovery stops, the writes after that point are discarded
+	for _, walFile := range walFiles {
+		wal, err := OpenBasicWal(walFile, db.setting.WalStrictModeOn)
+		if err != nil {
+			return err
+		}
+		if discard {
+			if err := wal.Delete(); err != nil {
+				return err
+			}
+			log.Warnf("Discarded WAL file %s written after the point in time recovery stopped at", walFile)
+			continue
+		}
+
+		mem, report, err := NewBasicMemTableFromWal(wal, mode, db.lastSeq)
+		if err != nil {
+			wal.Close()
+			return err
+		}
+		for _, dropped := range report.Dropped {
+			log.Warnf("Dropped damaged log while recovering WAL file %s - Error: %s", walFile, dropped.Error())
+		}
+		if report.Stopped {
+			log.Warnf("Stopped recovering WAL file %s at a damaged log, discarded the logs after it", walFile)
+			discard = mode == WalRecoveryPointInTime
+		}
+
+		records := mem.GetAll()
+		for _, record := range records {
+			if record.Seq > db.lastSeq {
+				db.lastSeq = record.Seq
+			}
+		}
+
+		// a WAL file is only deleted when it held nothing to replay, never because its records were skipped
+		numRecords := len(records)
+		if numRecords == 0 && report.Replayed == 0 {
+			if err := wal.Delete(); err != nil {
+				return err
+			}
+			log.Infof("Deleted empty WAL file %s", walFile)
+			continue
+		}
+
+		db.memSvc.enqueue(mem)
+		log.Infof("Recovered %d records from WAL file %s. Enqueued for serialization to sstable", numRecords, walFile)
+	}
+	if discard {
+		if err := db.wal.RemoveSegmentsBefore(db.wal.Segment()); err != nil {
+			return err
+		}
+		log.Warnf("Discarded WAL segments in %s written after the point in time recovery stopped at", db.wal.dir)
+		return nil
+	}
+
+	return db.recoverWalSegments(persistedSeq)
+}
+
+// recoverWalSegments - rebuilds memtables from the WAL segments left behind, writes at or before persistedSeq
+// are skipped. A memtable is sent for serialization once it's full, like the memtables being written.
+func (db *Database) recoverWalSegments(persistedSeq uint64) error {
+	var mem *SkipListMemTable
+	lastSeq := db.lastSeq
+	reports, err := db.wal.recoverSegments(db.setting.WalRecoveryMode, func(segment uint64, data []byte) error {
+		if mem == nil {
+			mem = newSegmentedMemTable(db.wal, segment)
+		}
+		if err := mem.applyWalLog(data, persistedSeq, &lastSeq); err != nil {
+			return err
+		}
+		if mem.SizeBytes() >= uint32(db.setting.MemtableSizeByte) {
+			db.enqueueRecoveredMemtable(mem)
+			mem = nil
+		}
+		return nil
+	})
+	for _, report := range reports {
+		for _, dropped := range report.Dropped {
+			log.Warnf("Dropped damaged log while recovering WAL file %s - Error: %s", report.File, dropped.Error())
+		}
+		if report.Stopped {
+			log.Warnf("Stopped recovering WAL file %s at a damaged log, discarded the logs after it", report.File)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if mem != nil && mem.WalRange().LastSeq > 0 {
+		db.enqueueRecoveredMemtable(mem)
+	}
+	return nil
+}
+
+// enqueueRecoveredMemtable - sends a memtable rebuilt from the WAL segments for serialization
+func (db *Database) enqueueRecoveredMemtable(mem *SkipListMemTable) {
+	walRange := mem.WalRange()
+	if walRange.LastSeq > db.lastSeq {
+		db.lastSeq = walRange.LastSeq
+	}
+	db.memSvc.enqueue(mem)
+	log.Infof(
+		"Recovered records %d to %d from WAL segments %d and after. Enqueued for serialization to sstable",
+		walRange.FirstSeq, walRange.LastSeq, walRange.Segment,
+	)
+}
+
+// removeObsoleteWalSegments - deletes the WAL segments older than the first segment holding writes not
+// serialized yet. Nothing is deleted while the memtables are being recovered.
+func (db *Database) removeObsoleteWalSegments() {
+	db.memLock.RLock()
+	mem := db.curMem
+	db.memLock.RUnlock()
+	if mem == nil {
+		return
+	}
+
+	// a full memtable is enqueued before it's swapped for a new one, so reading the queue after the current
+	// memtable can't miss a memtable in between
+	oldest := mem.WalRange().Segment
+	for _, queued := range db.memSvc.getQueuedTables() {
+		if segment := queued.WalRange().Segment; segment != 0 && segment < oldest {
+			oldest = segment
+		}
+	}
+	if err := db.wal.RemoveSegmentsBefore(oldest); err != nil {
+		log.Warnf("Failed to delete WAL segments before segment %d - Error: %s", oldest, err.Error())
+	}
+}
+
 // setupLogging - setup logging for the database
 func (db *Database) setupLogging() error {
 	file, err := os.OpenFile(filepath.Join(db.setting.DBDir, "db.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
@@ -70,74 +314,289 @@ func (db *Database) setupLogging() error {
 
 	log.SetOutput(file)
 	log.SetLevel(db.setting.LogLevel)
+	db.logFile = file
 	return nil
 }
 
-// getAllSSTableFileMetadata - get all sstable files metadata in reverse chronological order (latest first)
-func (db *Database) getAllSSTableFileMetadata() ([]*SSTableFileMetadata, error) {
-	files, err := ioutil.ReadDir(db.sstableDir)
-	if err != nil {
-		return nil, err
+// Close - stops accepting reads and writes, waits for the background serialization and compaction to stop and
+// closes all the files of the database. The data in memory is serialized into sstable files first if
+// `ConfigFlushOnClose` is on or some of it skipped the WAL. Returns the failure of any background work. All the
+// iterators have to be closed and all the other calls have returned before the database is closed.
+func (db *Database) Close() error {
+	db.writeLock.Lock()
+	if !atomic.CompareAndSwapInt32(&db.closed, 0, 1) {
+		db.writeLock.Unlock()
+		return ErrDBClosed
+	}
+	mem := db.curMem
+	// writes that skipped the WAL can't be recovered, so they're always serialized
+	flush := (db.setting.FlushOnClose || mem.WalRange().Unlogged) && mem.SizeBytes() > 0
+	if flush {
+		db.memSvc.enqueue(mem)
+	}
+	db.writeLock.Unlock()
+
+	errs := make([]error, 0)
+	errs = append(errs, db.memSvc.stop(), db.compactSvc.stop())
+
+	// WAL segments holding writes not serialized are kept to be recovered, the others are deleted
+	oldest := ^uint64(0)
+	if !flush && mem.SizeBytes() > 0 {
+		oldest = mem.WalRange().Segment
+	}
+	for _, queued := range db.memSvc.getQueuedTables() {
+		walRange := queued.WalRange()
+		if walRange.Segment == 0 {
+			errs = append(errs, queued.Wal().Close())
+		} else if walRange.Segment < oldest {
+			oldest = walRange.Segment
+		}
+	}
+	errs = append(errs, db.wal.Close(), db.wal.RemoveSegmentsBefore(oldest))
+
+	db.tables.close()
+	errs = append(errs, db.manifest.close())
+
+	// restore the default output if it's still going to the log file of the database
+	if log.StandardLogger().Out == db.logFile {
+		log.SetOutput(os.Stderr)
 	}
+	errs = append(errs, db.logFile.Close())
 
-	allMeta := make([]*SSTableFileMetadata, len(files))
-	for idx, file := range files {
-		allMeta[len(files)-idx-1] = &SSTableFileMetadata{
-			filename:     file.Name(),
-			size:         file.Size(),
-			lastModified: file.ModTime(),
+	for _, err := range errs {
+		if err != nil {
+			return err
 		}
 	}
-	return allMeta, nil
+	return nil
+}
+
+// isClosed - returns true if the database has been closed
+func (db *Database) isClosed() bool {
+	return atomic.LoadInt32(&db.closed) == 1
 }
 
-// Get - read value for key from the database
+// getAllSSTableFileMetadata - get metadata of all the live sstable files recorded in the manifest in
+// reverse chronological order (latest first)
+func (db *Database) getAllSSTableFileMetadata() ([]*SSTableFileMetadata, error) {
+	return db.manifest.currentVersion().files(), nil
+}
+
+// Get - read value for key from the database, nil if the key doesn't exist or has been deleted
 func (db *Database) Get(key string) ([]byte, error) {
+	return db.GetWithOptions(key, nil)
+}
+
+// GetWithOptions - read value for key from the database with read options, nil if the key doesn't exist or
+// has been deleted
+func (db *Database) GetWithOptions(key string, opts *ReadOptions) ([]byte, error) {
+	if db.isClosed() {
+		return nil, ErrDBClosed
+	}
+
+	record, err := db.getRecord(
+		key, opts.sequence(db.lastSequence()), opts.verifyChecksums(db.setting.VerifyChecksums),
+	)
+	if err != nil || record == nil || record.Tombstone {
+		return nil, err
+	}
+	return record.Value, nil
+}
+
+// getRecord - find the latest record of key written at or before sequence number seq, which could be a
+// tombstone if the key was deleted. Data blocks read from sstable files are verified against their checksum if
+// verify is true
+func (db *Database) getRecord(key string, seq uint64, verify bool) (*MemtableRecord, error) {
+	mem, queuedTables := db.memtables()
+
 	// Try to read first from the current memtable
-	value := db.curMem.Get(key)
-	if value != nil {
-		return value, nil
+	if record := mem.Get(key, seq); record != nil {
+		return record, nil
 	}
 
-	// Try to read from the memtables that are in queue for serialization
-	for _, memToSerialize := range db.memSvc.getQueuedTables() {
-		value = memToSerialize.Get(key)
-		if value != nil {
-			return value, nil
+	// Try to read from the memtables that are in queue for serialization, from latest to earliest
+	for i := len(queuedTables) - 1; i >= 0; i-- {
+		if record := queuedTables[i].Get(key, seq); record != nil {
+			return record, nil
 		}
 	}
 
-	// if still no luck, iterate through the sstable files from latest to earliest
-	metas, err := db.getAllSSTableFileMetadata()
-	if err != nil {
-		return nil, err
-	}
+	// if still no luck, iterate through the sstable files from latest to earliest. The version is held
+	// so that none of its files gets deleted by compaction in the middle of reading
+	v := db.manifest.acquireVersion()
+	defer db.manifest.releaseVersion(v)
 
-	for _, meta := range metas {
-		// TODO: (p2) cache the opened reader using an LRU cache to improve performance
-		reader, err := NewBasicSSTableReader(filepath.Join(db.sstableDir, meta.filename))
+	for _, meta := range v.files() {
+		if !meta.containsKey(key) {
+			continue
+		}
+		t, err := db.tables.acquire(meta)
 		if err != nil {
 			return nil, err
 		}
-		value, err = reader.Get(key)
-		if err != nil || value != nil {
-			return value, err
+		record, err := t.reader.get(key, seq, verify)
+		db.tables.release(t)
+		if err != nil || record != nil {
+			return record, err
 		}
 	}
 	return nil, nil
 }
 
+// memtables - returns the current memtable and the memtables in queue for serialization from the earliest to
+// the latest. A memtable is enqueued before it stops being the current one and only leaves the queue after it's
+// recorded in the manifest, so all the data written is in either of them or the version acquired afterwards
+func (db *Database) memtables() (MemTable, []MemTable) {
+	db.memLock.RLock()
+	defer db.memLock.RUnlock()
+
+	return db.curMem, db.memSvc.getQueuedTables()
+}
+
 // Write - write value into the database
 func (db *Database) Write(key string, value []byte) error {
-	if err := db.curMem.Write(key, value); err != nil {
+	return db.WriteWithOptions(key, value, nil)
+}
+
+// WriteWithOptions - same as `Write`, with the options of the write
+func (db *Database) WriteWithOptions(key string, value []byte, opts *WriteOptions) error {
+	batch := NewWriteBatch()
+	batch.Put(key, value)
+	return db.ApplyBatchWithOptions(batch, opts)
+}
+
+// Delete - delete a key from the database
+func (db *Database) Delete(key string) error {
+	return db.DeleteWithOptions(key, nil)
+}
+
+// DeleteWithOptions - same as `Delete`, with the options of the delete
+func (db *Database) DeleteWithOptions(key string, opts *WriteOptions) error {
+	batch := NewWriteBatch()
+	batch.Delete(key)
+	return db.ApplyBatchWithOptions(batch, opts)
+}
+
+// ApplyBatch - applies all the operations of the batch to the database atomically. If any key of the batch is
+// locked by a pessimistic transaction, waits for the lock to be released up to the configured lock timeout.
+//
+// Batches written concurrently are committed in groups, each group is recorded in the WAL as a single log. This
+// way concurrent writers share the cost of syncing the WAL in strict mode. A batch is still applied atomically,
+// but it fails along with the rest of its group.
+func (db *Database) ApplyBatch(batch *WriteBatch) error {
+	return db.ApplyBatchWithOptions(batch, nil)
+}
+
+// ApplyBatchWithOptions - same as `ApplyBatch`, with the options of the batch. A batch is only grouped with
+// batches written with the same options.
+func (db *Database) ApplyBatchWithOptions(batch *WriteBatch, opts *WriteOptions) error {
+	owner := db.locks.newOwner()
+	if err := db.locks.acquireAll(owner, batch.keys(), db.setting.LockTimeout); err != nil {
+		return err
+	}
+	defer db.locks.releaseAll(owner)
+
+	return db.writes.commit(batch, opts, db.applyGroup)
+}
+
+// applyGroup - applies a group of batches merged by the write queue with the options of the group
+func (db *Database) applyGroup(group *WriteBatch, opts *WriteOptions) error {
+	db.writeLock.Lock()
+	defer db.writeLock.Unlock()
+
+	return db.applyBatchLocked(group, opts)
+}
+
+// applyBatchLocked - applies the batch with the options, writeLock must be held
+func (db *Database) applyBatchLocked(batch *WriteBatch, opts *WriteOptions) error {
+	if db.isClosed() {
+		return ErrDBClosed
+	}
+	// memtables can't be serialized after a serialization failure, so writes would only pile up in memory
+	if err := db.memSvc.error(); err != nil {
+		return err
+	}
+	if batch.Len() == 0 {
+		return nil
+	}
+	if writeOptionsOf(opts).NoSlowdown && db.wouldStall(batch) {
+		return ErrWriteStall
+	}
+
+	firstSeq := db.lastSequence() + 1
+	if err := db.curMem.ApplyBatch(batch, firstSeq, opts); err != nil {
 		return err
 	}
+	// the writes become visible to new reads and snapshots only after they're all in the memtable
+	atomic.StoreUint64(&db.lastSeq, firstSeq+uint64(batch.Len())-1)
+	db.rotateMemtableIfFull()
+	return nil
+}
+
+// wouldStall - returns true if applying the batch fills up the current memtable while another memtable is
+// being serialized, in which case the rotation has to wait for it. writeLock must be held
+func (db *Database) wouldStall(batch *WriteBatch) bool {
+	if db.curMem.SizeBytes()+uint32(batch.sizeBytes()) < uint32(db.setting.MemtableSizeByte) {
+		return false
+	}
+	return len(db.memSvc.getQueuedTables()) > 0
+}
+
+// lastSequence - returns the sequence number of the latest write
+func (db *Database) lastSequence() uint64 {
+	return atomic.LoadUint64(&db.lastSeq)
+}
+
+// NewSnapshot - takes a snapshot of the current state of the database, the snapshot has to be released
+// after use
+func (db *Database) NewSnapshot() *Snapshot {
+	return db.snapshots.add(db.lastSequence())
+}
+
+// BlockCacheStats - returns the statistics of the block cache
+func (db *Database) BlockCacheStats() BlockCacheStats {
+	return db.blocks.stats()
+}
+
+// VerifyChecksums - verifies every block of the live sstable files against its checksum, blocks are read
+// from disk even if they're cached. Returns an error matching `ErrCorruption` that names the file and offset of
+// the first block corrupted.
+func (db *Database) VerifyChecksums() error {
+	if db.isClosed() {
+		return ErrDBClosed
+	}
+
+	v := db.manifest.acquireVersion()
+	defer db.manifest.releaseVersion(v)
+
+	for _, meta := range v.files() {
+		// a reader of its own verifies index and filter as they're loaded, and isn't served by the block cache
+		reader, err := newCachedSSTableReader(filepath.Join(db.sstableDir, meta.filename), 0, nil, true, true)
+		if err != nil {
+			return err
+		}
+		err = reader.verifyChecksums()
+		reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+// rotateMemtableIfFull - when memtable has grown over threshold, send it for serialization and start
+// writing into a new memtable. writeLock must be held
+func (db *Database) rotateMemtableIfFull() {
 	sizeAfterWrite := db.curMem.SizeBytes()
-	// when memtable has grown over threshold, send it for serialization
-	if db.curMem.SizeBytes() >= uint32(db.setting.MemtableSizeByte) {
+	if sizeAfterWrite >= uint32(db.setting.MemtableSizeByte) {
+		newMem := newSegmentedMemTable(db.wal, db.wal.Segment())
+		// readers keep reading the full memtable from the current memtable or the queue in the meantime
 		db.memSvc.enqueue(db.curMem)
-		db.curMem = NewBasicMemTable(db.walDir, db.setting.WalStrictModeOn)
+		db.memLock.Lock()
+		db.curMem = newMem
+		db.memLock.Unlock()
+		// the full memtable may have been serialized before the swap, in which case its segments are left behind
+		db.removeObsoleteWalSegments()
 
 		log.Infof(
 			"Memtable has exceeded size limit (size: %d, limit: %d). Enqueued for serialization to sstable",
@@ -145,14 +604,4 @@ func (db *Database) Write(key string, value []byte) error {
 			db.setting.MemtableSizeByte,
 		)
 	}
-	return nil
-}
-
-// Delete - delete a key from the database
-// TODO: (p1) make the deletion behavior correct across from memtable and sstable
-func (db *Database) Delete(key string) error {
-	if err := db.curMem.Delete(key); err != nil {
-		return err
-	}
-	return nil
 }
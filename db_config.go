@@ -1,16 +1,38 @@
 package dbengine
 
 import (
+	"path/filepath"
+	"time"
+
 	log "github.com/sirupsen/logrus"
 )
 
 // DBSetting - sepcifies the various configurations of the database that are customizable
 type DBSetting struct {
-	DBDir                    string
-	WalStrictModeOn          bool
-	MemtableSizeByte         uint
-	SStableDatablockSizeByte uint
-	LogLevel                 log.Level
+	DBDir                         string
+	WalDir                        string
+	WalSegmentSizeByte            uint
+	WalStrictModeOn               bool
+	WalRecoveryMode               WalRecoveryMode
+	MemtableSizeByte              uint
+	SStableDatablockSizeByte      uint
+	SStableTargetFileSizeByte     uint
+	SStableIndexPartitionSizeByte uint
+	BloomFilterBitsPerKey         int
+	Compressor                    Compressor
+	LevelCompressors              []Compressor
+	MaxOpenFiles                  int
+	BlockCacheSizeByte            uint
+	PinIndexAndFilterBlocks       bool
+	FlushOnClose                  bool
+	VerifyChecksums               bool
+	Level0CompactionTrigger       int
+	LevelSizeBaseByte             uint
+	LevelSizeMultiplier           uint
+	CompactionStrategy            CompactionStrategy
+	CompactionInterval            time.Duration
+	LockTimeout                   time.Duration
+	LogLevel                      log.Level
 }
 
 // DBConfig - configuration function for db setting
@@ -23,6 +45,24 @@ func ConfigDBDir(dir string) DBConfig {
 	}
 }
 
+// ConfigWalDir - configures the directory the WAL (write-ahead-log) segments are stored in, default to the "wal"
+// directory under the DB directory. Putting the WAL on a separate, faster storage device speeds up writes. The
+// directory is recorded in the manifest, a database can only be opened with another one once all the writes in
+// the segments left behind are serialized into sstable files.
+func ConfigWalDir(dir string) DBConfig {
+	return func(d *DBSetting) {
+		d.WalDir = dir
+	}
+}
+
+// ConfigWalSegmentSizeByte - configures roughly how large (in bytes) a WAL segment grows before a new one is
+// started, default to 8MB. A segment is deleted once all the writes in it are serialized into sstable files.
+func ConfigWalSegmentSizeByte(size uint) DBConfig {
+	return func(d *DBSetting) {
+		d.WalSegmentSizeByte = size
+	}
+}
+
 // ConfigWalStrictMode - configures if strict mode should be turned on or not for the wal (write-ahead-log).
 // Strict mode means that every write to the wal file will be flushed to the storage device (instead of
 // being buffered in the kernel's page cache, similar to calling `fsync` after every `write` syscall).
@@ -34,6 +74,15 @@ func ConfigWalStrictMode(isOn bool) DBConfig {
 	}
 }
 
+// ConfigWalRecoveryMode - configures what happens to damaged logs found in the WAL files when the database is
+// opened, default to `WalRecoveryTolerateCorruptedTail`. See `WalRecoveryMode` for the available modes, the logs
+// dropped are reported in the log file.
+func ConfigWalRecoveryMode(mode WalRecoveryMode) DBConfig {
+	return func(d *DBSetting) {
+		d.WalRecoveryMode = mode
+	}
+}
+
 // ConfigMemtableSizeByte - configures roughly how much data (in bytes) should be saved into the storage in memroy
 // before it gets flushed into disk.
 // Tuning this paramter could demonstrate different performance depending on the worklaod.
@@ -52,6 +101,145 @@ func ConfigSStableDatablockSizeByte(size uint) DBConfig {
 	}
 }
 
+// ConfigSStableTargetFileSizeByte - configures roughly how big (in bytes) each sstable file produced by
+// compaction should be. Compaction output is split into multiple files of this size.
+func ConfigSStableTargetFileSizeByte(size uint) DBConfig {
+	return func(d *DBSetting) {
+		d.SStableTargetFileSizeByte = size
+	}
+}
+
+// ConfigSStableIndexPartitionSizeByte - configures roughly how big (in bytes) each partition of the index of an
+// sstable file should be. An index bigger than that is split into partitions that are read through the block
+// cache like data blocks, so that only a small top-level index has to stay in memory for each open file, which
+// matters for large files. Setting it to 0 keeps every index in a single block.
+func ConfigSStableIndexPartitionSizeByte(size uint) DBConfig {
+	return func(d *DBSetting) {
+		d.SStableIndexPartitionSizeByte = size
+	}
+}
+
+// ConfigLevel0CompactionTrigger - configures how many sstable files can pile up at level 0 (files serialized
+// from memtables) before they get compacted into level 1.
+func ConfigLevel0CompactionTrigger(numFiles int) DBConfig {
+	return func(d *DBSetting) {
+		d.Level0CompactionTrigger = numFiles
+	}
+}
+
+// ConfigLevelSizeBaseByte - configures the max total size (in bytes) of sstable files at level 1 before they
+// get compacted into level 2. Each level after can hold `LevelSizeMultiplier` times more data than the
+// level above it.
+func ConfigLevelSizeBaseByte(size uint) DBConfig {
+	return func(d *DBSetting) {
+		d.LevelSizeBaseByte = size
+	}
+}
+
+// ConfigLevelSizeMultiplier - configures how many times more data each level can hold than the level above it.
+// A bigger multiplier means fewer levels but more data rewritten by each compaction.
+func ConfigLevelSizeMultiplier(multiplier uint) DBConfig {
+	return func(d *DBSetting) {
+		d.LevelSizeMultiplier = multiplier
+	}
+}
+
+// ConfigCompactionStrategy - configures how sstable files are compacted, default to leveled compaction.
+// See `CompactionStrategy` for the available strategies. The strategy of an existing database can't be changed.
+func ConfigCompactionStrategy(strategy CompactionStrategy) DBConfig {
+	return func(d *DBSetting) {
+		d.CompactionStrategy = strategy
+	}
+}
+
+// ConfigCompactionInterval - configures how often the database checks if any sstable files need compaction
+func ConfigCompactionInterval(interval time.Duration) DBConfig {
+	return func(d *DBSetting) {
+		d.CompactionInterval = interval
+	}
+}
+
+// ConfigBloomFilterBitsPerKey - configures how many bits per key the bloom filter of each sstable file uses, the
+// more bits the fewer sstable files a lookup of a missing key has to read, at the cost of memory and disk space.
+// 10 bits per key gives roughly 1% false positive rate. Setting it to 0 disables bloom filter.
+func ConfigBloomFilterBitsPerKey(bits int) DBConfig {
+	return func(d *DBSetting) {
+		d.BloomFilterBitsPerKey = bits
+	}
+}
+
+// ConfigCompressor - configures how data blocks of sstable files are compressed, default to snappy. See
+// `Compressor` for the available compressors, nil stores data blocks uncompressed.
+func ConfigCompressor(c Compressor) DBConfig {
+	return func(d *DBSetting) {
+		d.Compressor = c
+	}
+}
+
+// ConfigLevelCompressors - configures a compressor for each level, starting from level 0, in place of the one
+// set by `ConfigCompressor`. Levels below the last one given use the last compressor. Since most of the data
+// ends up in the bottom levels, a cheap compressor at the top levels (whose files are rewritten soon) and the
+// strongest one at the bottom usually gives the best trade-off.
+func ConfigLevelCompressors(compressors ...Compressor) DBConfig {
+	return func(d *DBSetting) {
+		d.LevelCompressors = compressors
+	}
+}
+
+// ConfigMaxOpenFiles - configures how many sstable files can be kept open for reading at the same time, files
+// that are read often are kept open so that they don't have to be opened and have their index loaded for every
+// read. The least recently used file is closed once the limit is reached.
+func ConfigMaxOpenFiles(n int) DBConfig {
+	return func(d *DBSetting) {
+		d.MaxOpenFiles = n
+	}
+}
+
+// ConfigBlockCacheSizeByte - configures how much memory (in bytes) the blocks read from sstable files can take
+// up in the block cache shared by all the sstable files. Blocks that are read often stay in memory so they don't
+// have to be read from disk again, the least recently used blocks are evicted once the limit is reached.
+func ConfigBlockCacheSizeByte(size uint) DBConfig {
+	return func(d *DBSetting) {
+		d.BlockCacheSizeByte = size
+	}
+}
+
+// ConfigPinIndexAndFilterBlocks - configures if the index and bloom filter of an open sstable file should be kept
+// in memory until the file is closed, default to true. Turning it off lets them be evicted from the block cache
+// like data blocks, which bounds the memory used by a large number of open files at the cost of reading them
+// from disk again.
+func ConfigPinIndexAndFilterBlocks(pin bool) DBConfig {
+	return func(d *DBSetting) {
+		d.PinIndexAndFilterBlocks = pin
+	}
+}
+
+// ConfigLockTimeout - configures how long a write or a transaction waits for a key locked by a pessimistic
+// transaction before giving up with `ErrLockTimeout`
+func ConfigLockTimeout(timeout time.Duration) DBConfig {
+	return func(d *DBSetting) {
+		d.LockTimeout = timeout
+	}
+}
+
+// ConfigVerifyChecksums - configures if the data blocks read from sstable files should be verified against their
+// checksum, default to true. Index and bloom filter blocks are verified regardless, and so are the blocks read
+// by compaction. A read can still ask for verification with `ReadOptions`.
+func ConfigVerifyChecksums(verify bool) DBConfig {
+	return func(d *DBSetting) {
+		d.VerifyChecksums = verify
+	}
+}
+
+// ConfigFlushOnClose - configures if the data in memory should be serialized into sstable files when the database
+// is closed, default to false. Either way no data is lost, otherwise it's recovered from the WAL files when the
+// database is opened again.
+func ConfigFlushOnClose(flush bool) DBConfig {
+	return func(d *DBSetting) {
+		d.FlushOnClose = flush
+	}
+}
+
 // ConfigLogLevel - configures the log level of the database, default to WARN
 func ConfigLogLevel(level log.Level) DBConfig {
 	return func(d *DBSetting) {
@@ -61,12 +249,58 @@ func ConfigLogLevel(level log.Level) DBConfig {
 
 func defaultDBSetting() *DBSetting {
 	return &DBSetting{
-		DBDir:                    "./db",
-		WalStrictModeOn:          false,
-		MemtableSizeByte:         4 * 1024 * 1024, // 4 MB
-		SStableDatablockSizeByte: 4 * 1024,        // 4 KB
-		LogLevel:                 log.WarnLevel,
+		DBDir:                         "./db",
+		WalDir:                        "",
+		WalSegmentSizeByte:            8 * 1024 * 1024, // 8 MB
+		WalStrictModeOn:               false,
+		WalRecoveryMode:               WalRecoveryTolerateCorruptedTail,
+		MemtableSizeByte:              4 * 1024 * 1024, // 4 MB
+		SStableDatablockSizeByte:      4 * 1024,        // 4 KB
+		SStableTargetFileSizeByte:     2 * 1024 * 1024, // 2 MB
+		SStableIndexPartitionSizeByte: 4 * 1024,        // 4 KB
+		BloomFilterBitsPerKey:         10,
+		Compressor:                    SnappyCompression,
+		MaxOpenFiles:                  1000,
+		BlockCacheSizeByte:            8 * 1024 * 1024, // 8 MB
+		PinIndexAndFilterBlocks:       true,
+		VerifyChecksums:               true,
+		Level0CompactionTrigger:       4,
+		LevelSizeBaseByte:             10 * 1024 * 1024, // 10 MB
+		LevelSizeMultiplier:           10,
+		CompactionStrategy:            NewLeveledCompactionStrategy(),
+		CompactionInterval:            5 * time.Second,
+		LockTimeout:                   time.Second,
+		LogLevel:                      log.WarnLevel,
+	}
+}
+
+// walSegmentDir - returns the directory the WAL segments are stored in
+func (d *DBSetting) walSegmentDir() string {
+	if d.WalDir == "" {
+		return filepath.Join(d.DBDir, "wal")
+	}
+	return d.WalDir
+}
+
+// compressorForLevel - returns the compressor for the sstable files at level
+func (d *DBSetting) compressorForLevel(level int) Compressor {
+	if len(d.LevelCompressors) == 0 {
+		return d.Compressor
+	}
+	if level >= len(d.LevelCompressors) {
+		level = len(d.LevelCompressors) - 1
+	}
+	return d.LevelCompressors[level]
+}
+
+// checkCompressors - returns an error if any of the compressors configured isn't registered
+func (d *DBSetting) checkCompressors() error {
+	for _, c := range append([]Compressor{d.Compressor}, d.LevelCompressors...) {
+		if err := checkCompressorRegistered(c); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 // generateDBSetting - generates configuration for the database from the input configs
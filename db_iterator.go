@@ -0,0 +1,207 @@
+package dbengine
+
+// Iterator - iterates through the live keys of the database in key order within [lower, upper). The
+// iterator reads at a fixed point in time - the snapshot given in `ReadOptions`, or the moment the iterator
+// is created - so writes made afterwards are never seen. Iterator is not positioned when created, call
+// `First`, `Last` or `Seek` before reading from it. Iterator must be closed after use. Unlike `Database`, an
+// iterator must not be used by multiple goroutines at the same time.
+type Iterator struct {
+	db     *Database
+	v      *version
+	tables []*cachedTable
+	it     *mergingIterator
+	seq    uint64 // seq - only versions written at or before seq are visible
+	lower  string
+	upper  string // upper - exclusive upper bound, empty means no upper bound
+	// cur - the current record. When moving forward the merging iterator is positioned at it, when
+	// moving backward the merging iterator is positioned before all the versions of its key
+	cur     *MemtableRecord
+	forward bool
+	err     error
+}
+
+// NewIterator - creates an iterator over keys in [lower, upper), an empty upper means there is no upper bound
+func (db *Database) NewIterator(lower, upper string) (*Iterator, error) {
+	return db.NewIteratorWithOptions(lower, upper, nil)
+}
+
+// NewIteratorWithOptions - creates an iterator over keys in [lower, upper) with read options
+func (db *Database) NewIteratorWithOptions(lower, upper string, opts *ReadOptions) (*Iterator, error) {
+	if db.isClosed() {
+		return nil, ErrDBClosed
+	}
+
+	seq := opts.sequence(db.lastSequence())
+	verify := opts.verifyChecksums(db.setting.VerifyChecksums)
+
+	mem, queuedTables := db.memtables()
+	iters := []RecordIterator{mem.NewIterator()}
+	for i := len(queuedTables) - 1; i >= 0; i-- {
+		iters = append(iters, queuedTables[i].NewIterator())
+	}
+
+	// the version is held until the iterator is closed so that none of its files gets deleted by compaction
+	v := db.manifest.acquireVersion()
+	tables := make([]*cachedTable, 0)
+	for _, meta := range v.files() {
+		if meta.largestKey < lower || (upper != "" && meta.smallestKey >= upper) {
+			continue
+		}
+		t, err := db.tables.acquire(meta)
+		if err != nil {
+			for _, t := range tables {
+				db.tables.release(t)
+			}
+			db.manifest.releaseVersion(v)
+			return nil, err
+		}
+		tables = append(tables, t)
+		iters = append(iters, t.reader.newIterator(verify))
+	}
+
+	return &Iterator{
+		db:     db,
+		v:      v,
+		tables: tables,
+		it:     newMergingIterator(iters...),
+		seq:    seq,
+		lower:  lower,
+		upper:  upper,
+	}, nil
+}
+
+// First - moves to the first key in range
+func (it *Iterator) First() {
+	it.Seek(it.lower)
+}
+
+// Last - moves to the last key in range
+func (it *Iterator) Last() {
+	if it.upper == "" {
+		it.it.Last()
+	} else {
+		it.it.Seek(it.upper)
+		if it.it.Valid() {
+			it.it.Prev()
+		} else {
+			it.it.Last()
+		}
+	}
+	it.findPrevKey()
+}
+
+// Seek - moves to the first key in range that is greater than or equal to key
+func (it *Iterator) Seek(key string) {
+	if key < it.lower {
+		key = it.lower
+	}
+	it.it.Seek(key)
+	it.findNextKey("", false)
+}
+
+// Valid - returns true if the iterator is positioned at a key in range
+func (it *Iterator) Valid() bool {
+	return it.cur != nil && it.Error() == nil
+}
+
+// Next - moves to the next key, only valid to call when `Valid` is true
+func (it *Iterator) Next() {
+	if it.forward {
+		it.it.Next()
+	} else {
+		// the merging iterator is before the current key, move it back to the current key
+		it.it.Seek(it.cur.Key)
+	}
+	it.findNextKey(it.cur.Key, true)
+}
+
+// Prev - moves to the previous key, only valid to call when `Valid` is true
+func (it *Iterator) Prev() {
+	if it.forward {
+		// move the merging iterator before all the versions of the current key
+		key := it.cur.Key
+		for it.it.Valid() && it.it.Record().Key >= key {
+			it.it.Prev()
+		}
+	}
+	it.findPrevKey()
+}
+
+// findNextKey - moves forward to the latest visible version of the next live key, all the versions of skipKey
+// are skipped if skipping is true
+func (it *Iterator) findNextKey(skipKey string, skipping bool) {
+	it.forward = true
+	it.cur = nil
+	for ; it.it.Valid(); it.it.Next() {
+		record := it.it.Record()
+		if it.upper != "" && record.Key >= it.upper {
+			return
+		}
+		if record.Seq > it.seq || (skipping && record.Key == skipKey) {
+			continue
+		}
+		if record.Tombstone {
+			// older versions of a deleted key are shadowed by the tombstone
+			skipKey, skipping = record.Key, true
+			continue
+		}
+		it.cur = record
+		return
+	}
+}
+
+// findPrevKey - moves backward to the previous live key. Versions of a key are seen from the earliest to
+// the latest going backward, so the key is only known to be live once all of its versions are passed
+func (it *Iterator) findPrevKey() {
+	it.forward = false
+	var latest *MemtableRecord
+	for ; it.it.Valid(); it.it.Prev() {
+		record := it.it.Record()
+		if record.Key < it.lower {
+			break
+		}
+		if record.Seq > it.seq {
+			continue
+		}
+		if latest != nil && record.Key < latest.Key {
+			break
+		}
+		if record.Tombstone {
+			latest = nil
+		} else {
+			latest = record
+		}
+	}
+	it.cur = latest
+}
+
+// Key - returns the current key
+func (it *Iterator) Key() string {
+	return it.cur.Key
+}
+
+// Value - returns the value of the current key
+func (it *Iterator) Value() []byte {
+	return it.cur.Value
+}
+
+// Error - returns the error encountered during iteration, if any
+func (it *Iterator) Error() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.it.Error()
+}
+
+// Close - releases the sstable files read by the iterator and lets compaction delete them
+func (it *Iterator) Close() error {
+	for _, t := range it.tables {
+		it.db.tables.release(t)
+	}
+	it.tables = nil
+	if it.v != nil {
+		it.db.manifest.releaseVersion(it.v)
+		it.v = nil
+	}
+	return it.err
+}
@@ -1,13 +1,21 @@
 package dbengine
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/DrakeW/go-db-engine/pb"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
 )
 
 func setupTestDBDir(tb testing.TB) string {
@@ -20,6 +28,15 @@ func setupTestDBDir(tb testing.TB) string {
 	return dirpath
 }
 
+// waitForMemtableSerialization - blocks until all the enqueued memtables have been serialized into sstables
+func waitForMemtableSerialization(tb testing.TB, db *Database) {
+	tb.Helper()
+
+	for len(db.memSvc.getQueuedTables()) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+}
+
 func Test_dbInit(t *testing.T) {
 	testDBDir := setupTestDBDir(t)
 
@@ -53,6 +70,8 @@ func Test_dbWriteAndMemtableCompaction(t *testing.T) {
 		)
 	}
 
+	waitForMemtableSerialization(t, db)
+
 	// test if the correct number of sstables are created bsaed on the configuration
 	// with 512 byte memtable, and 1000 key-value pair that sums to a total of roughly 16 * 1000 bytes
 	// we should have about 16 * 1000 / 512 -> 31 sstable files
@@ -103,6 +122,654 @@ func Test_dbGet(t *testing.T) {
 	}
 }
 
+func Test_dbShouldRecoverUnserializedWritesFromWal(t *testing.T) {
+	testDBDir := setupTestDBDir(t)
+
+	db, err := NewDatabase(
+		ConfigDBDir(testDBDir),
+		ConfigLogLevel(log.InfoLevel),
+	)
+	if err != nil {
+		t.Errorf("Failed to initialize database - Error: %s", err.Error())
+	}
+
+	// default memtable size is large enough that none of the writes get serialized
+	for i := 0; i < 100; i++ {
+		db.Write(
+			fmt.Sprintf("key-%03d", i),
+			[]byte(fmt.Sprintf("value-%03d", i)),
+		)
+	}
+
+	// open the same directory again as if the previous process had crashed
+	recovered, err := NewDatabase(
+		ConfigDBDir(testDBDir),
+		ConfigLogLevel(log.InfoLevel),
+	)
+	if err != nil {
+		t.Errorf("Failed to open existing database - Error: %s", err.Error())
+	}
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		value, err := recovered.Get(key)
+		if err != nil {
+			t.Errorf("Failed to read key %s from db - Error: %s", key, err.Error())
+		}
+		if string(value) != fmt.Sprintf("value-%03d", i) {
+			t.Errorf("Value for key %s not recovered, got %s", key, string(value))
+		}
+	}
+}
+
+func Test_dbShouldRecoverWalFileWrittenBeforeSequenceNumbers(t *testing.T) {
+	testDBDir := setupTestDBDir(t)
+	walDir := filepath.Join(testDBDir, "wal")
+	if err := os.Mkdir(walDir, 0744); err != nil {
+		t.Fatal(err)
+	}
+	// a WAL file of the first format, a stream of varint size prefixed logs each holding a single record
+	// without a sequence number
+	f, err := os.Create(filepath.Join(walDir, fmt.Sprintf("wal_%d", time.Now().UnixNano())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 200; i++ {
+		record, _ := proto.Marshal(&pb.MemtableKeyValue{
+			Key:   fmt.Sprintf("key-%03d", i%150),
+			Value: []byte(fmt.Sprintf("value-%03d", i)),
+		})
+		walLog, _ := proto.Marshal(&pb.WalLog{Seq: uint32(i + 1), Data: record})
+		WriteDataWithVarintSizePrefix(f, walLog)
+	}
+	f.Close()
+
+	db, err := NewDatabase(ConfigDBDir(testDBDir))
+	if err != nil {
+		t.Fatalf("Failed to open existing database - Error: %s", err.Error())
+	}
+	defer db.Close()
+	for i := 0; i < 150; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		// keys written twice keep their later value
+		expected := fmt.Sprintf("value-%03d", i)
+		if i < 50 {
+			expected = fmt.Sprintf("value-%03d", i+150)
+		}
+		if value, _ := db.Get(key); string(value) != expected {
+			t.Errorf("Value for key %s not recovered, expected %s got %s", key, expected, string(value))
+		}
+	}
+	if db.lastSeq != 200 {
+		t.Errorf("Expected recovered records to be given sequence numbers 1 to 200, got last sequence %d", db.lastSeq)
+	}
+}
+
+func Test_dbShouldSkipSerializedWritesWhenRecoveringWalSegments(t *testing.T) {
+	testDBDir := setupTestDBDir(t)
+	configs := []DBConfig{ConfigDBDir(testDBDir), ConfigMemtableSizeByte(512), ConfigLogLevel(log.InfoLevel)}
+
+	db, err := NewDatabase(configs...)
+	if err != nil {
+		t.Fatalf("Failed to initialize database - Error: %s", err.Error())
+	}
+	// the default segment size is large enough that all the writes go to the same segment
+	for i := 0; i < 100; i++ {
+		db.Write(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%03d", i)))
+	}
+	waitForMemtableSerialization(t, db)
+	persisted := db.manifest.lastSequence
+	if persisted == 0 || db.curMem.WalRange().Segment != 1 {
+		t.Fatalf("Expected writes serialized from segment 1, got last sequence %d", persisted)
+	}
+
+	// open the same directory again as if the previous process had crashed
+	recovered, err := NewDatabase(configs...)
+	if err != nil {
+		t.Fatalf("Failed to open existing database - Error: %s", err.Error())
+	}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		if value, _ := recovered.Get(key); string(value) != fmt.Sprintf("value-%03d", i) {
+			t.Errorf("Value for key %s not recovered, got %s", key, string(value))
+		}
+	}
+	recovered.Close()
+
+	dbLog, _ := ioutil.ReadFile(filepath.Join(testDBDir, "db.log"))
+	if !strings.Contains(string(dbLog), fmt.Sprintf("Recovered records %d to 100 from WAL segments 1", persisted+1)) {
+		t.Errorf("Expected only the writes after sequence %d to be recovered", persisted)
+	}
+}
+
+func Test_dbShouldRemoveWalSegmentsOnceSerialized(t *testing.T) {
+	testDBDir := setupTestDBDir(t)
+	walDir := setupTestDBDir(t)
+	db, err := NewDatabase(
+		ConfigDBDir(testDBDir),
+		ConfigWalDir(walDir),
+		ConfigWalSegmentSizeByte(256),
+		ConfigMemtableSizeByte(512),
+		ConfigFlushOnClose(true),
+	)
+	if err != nil {
+		t.Fatalf("Failed to initialize database - Error: %s", err.Error())
+	}
+
+	for i := 0; i < 100; i++ {
+		db.Write(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%03d", i)))
+	}
+	waitForMemtableSerialization(t, db)
+
+	// segments are removed right after a memtable leaves the queue
+	var segments []uint64
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if segments, _ = listWalSegments(walDir); len(segments) > 0 && segments[0] >= db.curMem.WalRange().Segment {
+			break
+		}
+	}
+	if len(segments) == 0 || segments[0] < db.curMem.WalRange().Segment {
+		t.Errorf("Expected segments before %d to be removed, got %v", db.curMem.WalRange().Segment, segments)
+	}
+	if segments, _ := listWalSegments(filepath.Join(testDBDir, "wal")); len(segments) != 0 {
+		t.Errorf("Expected no segment under the DB directory, got %v", segments)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if segments, _ := listWalSegments(walDir); len(segments) != 0 {
+		t.Errorf("Expected all segments to be removed after flushing on close, got %v", segments)
+	}
+}
+
+func Test_dbShouldOnlyChangeWalDirOnceSegmentsAreSerialized(t *testing.T) {
+	testDBDir := setupTestDBDir(t)
+	walDir := setupTestDBDir(t)
+	db, err := NewDatabase(ConfigDBDir(testDBDir), ConfigWalDir(walDir))
+	if err != nil {
+		t.Fatalf("Failed to initialize database - Error: %s", err.Error())
+	}
+	db.Write("key-001", []byte("value-001"))
+	db.Close()
+
+	// the write is left in a segment of the configured directory
+	if db, err := NewDatabase(ConfigDBDir(testDBDir)); err == nil {
+		db.Close()
+		t.Fatal("Expected database with WAL segments in another directory to refuse to open")
+	}
+
+	db, err = NewDatabase(ConfigDBDir(testDBDir), ConfigWalDir(walDir), ConfigFlushOnClose(true))
+	if err != nil {
+		t.Fatalf("Failed to open existing database - Error: %s", err.Error())
+	}
+	if value, _ := db.Get("key-001"); string(value) != "value-001" {
+		t.Errorf("Value for key-001 not recovered, got %s", string(value))
+	}
+	db.Close()
+
+	// all the writes are serialized, the WAL can move to the default directory
+	db, err = NewDatabase(ConfigDBDir(testDBDir))
+	if err != nil {
+		t.Fatalf("Failed to open database with another WAL directory - Error: %s", err.Error())
+	}
+	db.Write("key-002", []byte("value-002"))
+	db.Close()
+	if db, err := NewDatabase(ConfigDBDir(testDBDir), ConfigWalDir(walDir)); err == nil {
+		db.Close()
+		t.Error("Expected the new WAL directory to be recorded")
+	}
+}
+
+func Test_dbShouldRecoverDamagedWalAccordingToRecoveryMode(t *testing.T) {
+	tests := []struct {
+		mode   WalRecoveryMode
+		keys   []string // keys - recovered keys
+		failed bool
+	}{
+		{mode: WalRecoveryTolerateCorruptedTail, failed: true},
+		{mode: WalRecoveryPointInTime, keys: []string{"key-1"}},
+		{mode: WalRecoverySkipAnyCorruptedRecord, keys: []string{"key-1", "key-3"}},
+	}
+
+	for _, test := range tests {
+		testDBDir := setupTestDBDir(t)
+		walDir := filepath.Join(testDBDir, "wal")
+		os.MkdirAll(walDir, 0700)
+
+		// two WAL files left behind, the second log of the first one is corrupted
+		older := NewBasicMemTable(walDir, false)
+		older.Write("key-1", []byte("value-1"), 1, nil)
+		info, _ := os.Stat(older.Wal().File().Name())
+		older.Write("key-2", []byte("value-2"), 2, nil)
+		corruptFile(t, older.Wal().File().Name(), info.Size()+walFragmentHeaderSize+2)
+		newer := NewBasicMemTable(walDir, false)
+		newer.Write("key-3", []byte("value-3"), 3, nil)
+
+		db, err := NewDatabase(ConfigDBDir(testDBDir), ConfigWalRecoveryMode(test.mode))
+		if test.failed {
+			if !errors.Is(err, ErrCorruption) {
+				t.Errorf("Mode %d - expected corruption error, got %v", test.mode, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Mode %d - failed to open database - Error: %s", test.mode, err.Error())
+		}
+
+		recovered := make([]string, 0)
+		for _, key := range []string{"key-1", "key-2", "key-3"} {
+			if value, _ := db.Get(key); value != nil {
+				recovered = append(recovered, key)
+			}
+		}
+		if fmt.Sprint(recovered) != fmt.Sprint(test.keys) {
+			t.Errorf("Mode %d - expected keys %v to be recovered, got %v", test.mode, test.keys, recovered)
+		}
+		db.Close()
+
+		dbLog, _ := ioutil.ReadFile(filepath.Join(testDBDir, "db.log"))
+		if !strings.Contains(string(dbLog), "Dropped damaged log while recovering WAL file "+older.Wal().File().Name()) {
+			t.Errorf("Mode %d - expected dropped log to be reported in db.log", test.mode)
+		}
+	}
+}
+
+func Test_dbShouldReleaseEverythingWhenFailingToOpen(t *testing.T) {
+	testDBDir := setupTestDBDir(t)
+	configs := []DBConfig{ConfigDBDir(testDBDir), ConfigWalRecoveryMode(WalRecoveryAbsoluteConsistency)}
+	db, err := NewDatabase(configs...)
+	if err != nil {
+		t.Fatalf("Failed to initialize database - Error: %s", err.Error())
+	}
+	db.Write("key-1", []byte("value-1"))
+	db.Write("key-2", []byte("value-2"))
+	segment := walSegmentFile(filepath.Join(testDBDir, "wal"), db.curMem.WalRange().Segment)
+	db.Close()
+	info, _ := os.Stat(segment)
+	corruptFile(t, segment, info.Size()-2)
+
+	goroutines := runtime.NumGoroutine()
+	fds, _ := ioutil.ReadDir("/proc/self/fd")
+	if _, err := NewDatabase(configs...); !errors.Is(err, ErrCorruption) {
+		t.Fatalf("Expected database with a damaged WAL to fail to open, got %v", err)
+	}
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if runtime.NumGoroutine() <= goroutines {
+			break
+		}
+	}
+	if runtime.NumGoroutine() > goroutines {
+		t.Errorf("Expected background services to be stopped, %d goroutines left running", runtime.NumGoroutine()-goroutines)
+	}
+	if left, _ := ioutil.ReadDir("/proc/self/fd"); len(left) > len(fds) {
+		t.Errorf("Expected all the files to be closed, %d left open", len(left)-len(fds))
+	}
+	if log.StandardLogger().Out != os.Stderr {
+		t.Error("Expected log output to be restored")
+	}
+}
+
+func Test_dbShouldKeepSerializedDataAfterReopen(t *testing.T) {
+	testDBDir := setupTestDBDir(t)
+
+	db, err := NewDatabase(
+		ConfigDBDir(testDBDir),
+		ConfigMemtableSizeByte(512),
+		ConfigSStableDatablockSizeByte(512/4),
+		ConfigLogLevel(log.InfoLevel),
+	)
+	if err != nil {
+		t.Errorf("Failed to initialize database - Error: %s", err.Error())
+	}
+
+	for i := 0; i < 1000; i++ {
+		db.Write(
+			fmt.Sprintf("key-%03d", i),
+			[]byte(fmt.Sprintf("value-%03d", i)),
+		)
+	}
+	waitForMemtableSerialization(t, db)
+	allMeta, _ := db.getAllSSTableFileMetadata()
+
+	reopened, err := NewDatabase(
+		ConfigDBDir(testDBDir),
+		ConfigMemtableSizeByte(512),
+		ConfigSStableDatablockSizeByte(512/4),
+		ConfigLogLevel(log.InfoLevel),
+	)
+	if err != nil {
+		t.Errorf("Failed to open existing database - Error: %s", err.Error())
+	}
+	waitForMemtableSerialization(t, reopened)
+
+	reopenedMeta, _ := reopened.getAllSSTableFileMetadata()
+	// the writes left in the last memtable are recovered from the WAL into one more sstable file
+	if len(reopenedMeta) != len(allMeta)+1 {
+		t.Errorf("Expected %d sstable files after reopen, got %d", len(allMeta)+1, len(reopenedMeta))
+	}
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		value, err := reopened.Get(key)
+		if err != nil {
+			t.Errorf("Failed to read key %s from db - Error: %s", key, err.Error())
+		}
+		if string(value) != fmt.Sprintf("value-%03d", i) {
+			t.Errorf("Value for key %s not found after reopen, got %s", key, string(value))
+		}
+	}
+}
+
+func Test_dbDeleteShouldShadowValuesInSSTables(t *testing.T) {
+	testDBDir := setupTestDBDir(t)
+
+	db, err := NewDatabase(
+		ConfigDBDir(testDBDir),
+		ConfigMemtableSizeByte(512),
+		ConfigSStableDatablockSizeByte(512/4),
+		ConfigLogLevel(log.InfoLevel),
+	)
+	if err != nil {
+		t.Errorf("Failed to initialize database - Error: %s", err.Error())
+	}
+
+	for i := 0; i < 100; i++ {
+		db.Write(
+			fmt.Sprintf("key-%03d", i),
+			[]byte(fmt.Sprintf("value-%03d", i)),
+		)
+	}
+	db.Write("key-tombstone", []byte("tombstone"))
+	// deletes are spread across memtables and sstables
+	for i := 0; i < 100; i += 2 {
+		db.Delete(fmt.Sprintf("key-%03d", i))
+	}
+	waitForMemtableSerialization(t, db)
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		value, err := db.Get(key)
+		if err != nil {
+			t.Errorf("Failed to read key %s from db - Error: %s", key, err.Error())
+		}
+		if i%2 == 0 && value != nil {
+			t.Errorf("Deleted key %s should not be found, got %s", key, string(value))
+		}
+		if i%2 == 1 && string(value) != fmt.Sprintf("value-%03d", i) {
+			t.Errorf("Value for key %s not found, got %s", key, string(value))
+		}
+	}
+
+	value, _ := db.Get("key-tombstone")
+	if string(value) != "tombstone" {
+		t.Errorf("Value that looks like a tombstone should be returned as is, got %s", string(value))
+	}
+}
+
+func Test_dbIteratorShouldScanLiveKeysInRange(t *testing.T) {
+	testDBDir := setupTestDBDir(t)
+
+	db, err := NewDatabase(
+		ConfigDBDir(testDBDir),
+		ConfigMemtableSizeByte(512),
+		ConfigSStableDatablockSizeByte(512/4),
+		ConfigLogLevel(log.InfoLevel),
+	)
+	if err != nil {
+		t.Fatalf("Failed to initialize database - Error: %s", err.Error())
+	}
+
+	for i := 0; i < 100; i++ {
+		db.Write(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%03d", i)))
+	}
+	// overwrites and deletes are spread across memtables and sstables
+	for i := 0; i < 100; i += 3 {
+		db.Write(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("new-value-%03d", i)))
+	}
+	for i := 0; i < 100; i += 2 {
+		db.Delete(fmt.Sprintf("key-%03d", i))
+	}
+	waitForMemtableSerialization(t, db)
+
+	it, err := db.NewIterator("key-010", "key-090")
+	if err != nil {
+		t.Fatalf("Failed to create iterator - Error: %s", err.Error())
+	}
+	defer it.Close()
+
+	expected := make([]string, 0)
+	for i := 11; i < 90; i += 2 {
+		expected = append(expected, fmt.Sprintf("key-%03d", i))
+	}
+
+	got := make([]string, 0)
+	for it.First(); it.Valid(); it.Next() {
+		got = append(got, it.Key())
+		i := 11 + 2*(len(got)-1)
+		value := fmt.Sprintf("value-%03d", i)
+		if i%3 == 0 {
+			value = "new-" + value
+		}
+		if string(it.Value()) != value {
+			t.Errorf("Expected %s for key %s, got %s", value, it.Key(), string(it.Value()))
+		}
+	}
+	if it.Error() != nil {
+		t.Errorf("Failed to iterate - Error: %s", it.Error().Error())
+	}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("Expected keys %v, got %v", expected, got)
+	}
+
+	got = got[:0]
+	for it.Last(); it.Valid(); it.Prev() {
+		got = append(got, it.Key())
+	}
+	if len(got) != len(expected) || got[0] != "key-089" || got[len(got)-1] != "key-011" {
+		t.Errorf("Unexpected keys in reverse - %v", got)
+	}
+}
+
+func Test_dbShouldBeSafeForConcurrentReadersAndWriters(t *testing.T) {
+	db := getTestCompactionDB(t, ConfigCompactionInterval(10*time.Millisecond))
+
+	const numWriters, numKeys, numRounds = 4, 50, 10
+	var writers, readers sync.WaitGroup
+	done := make(chan struct{})
+
+	for w := 0; w < numWriters; w++ {
+		writers.Add(1)
+		go func(w int) {
+			defer writers.Done()
+			for round := 0; round < numRounds; round++ {
+				for i := 0; i < numKeys; i++ {
+					key := fmt.Sprintf("key-%d-%03d", w, i)
+					if err := db.Write(key, []byte(fmt.Sprintf("%s-%d", key, round))); err != nil {
+						t.Errorf("Failed to write key %s - Error: %s", key, err.Error())
+					}
+				}
+			}
+		}(w)
+	}
+
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func(r int) {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				key := fmt.Sprintf("key-%d-%03d", r%numWriters, r*7%numKeys)
+				if value, err := db.Get(key); err != nil || (value != nil && !strings.HasPrefix(string(value), key+"-")) {
+					t.Errorf("Unexpected read of key %s - %s - %v", key, string(value), err)
+				}
+
+				snap := db.NewSnapshot()
+				first, _ := db.GetWithOptions(key, &ReadOptions{Snapshot: snap})
+				second, _ := db.GetWithOptions(key, &ReadOptions{Snapshot: snap})
+				snap.Release()
+				if string(first) != string(second) {
+					t.Errorf("Snapshot reads of key %s should be repeatable, got %s and %s", key, first, second)
+				}
+
+				it, err := db.NewIterator("", "")
+				if err != nil {
+					t.Errorf("Failed to create iterator - Error: %s", err.Error())
+					continue
+				}
+				prev := ""
+				for it.First(); it.Valid(); it.Next() {
+					if it.Key() <= prev {
+						t.Errorf("Iterator should return keys in order, got %s after %s", it.Key(), prev)
+					}
+					prev = it.Key()
+				}
+				if err := it.Close(); err != nil {
+					t.Errorf("Iterator failed - Error: %s", err.Error())
+				}
+			}
+		}(r)
+	}
+
+	writers.Wait()
+	close(done)
+	readers.Wait()
+
+	for w := 0; w < numWriters; w++ {
+		for i := 0; i < numKeys; i++ {
+			key := fmt.Sprintf("key-%d-%03d", w, i)
+			if value, _ := db.Get(key); string(value) != fmt.Sprintf("%s-%d", key, numRounds-1) {
+				t.Errorf("Expected the last write of key %s, got %s", key, string(value))
+			}
+		}
+	}
+}
+
+func Test_dbCloseShouldStopBackgroundServicesAndKeepData(t *testing.T) {
+	for _, flush := range []bool{false, true} {
+		testDBDir := setupTestDBDir(t)
+		configs := []DBConfig{
+			ConfigDBDir(testDBDir),
+			ConfigMemtableSizeByte(512),
+			ConfigSStableDatablockSizeByte(512 / 4),
+			ConfigFlushOnClose(flush),
+			ConfigLogLevel(log.InfoLevel),
+		}
+
+		db, err := NewDatabase(configs...)
+		if err != nil {
+			t.Fatalf("Failed to initialize database - Error: %s", err.Error())
+		}
+		for i := 0; i < 100; i++ {
+			db.Write(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%03d", i)))
+		}
+		if err := db.Close(); err != nil {
+			t.Fatalf("Failed to close database - Error: %s", err.Error())
+		}
+
+		select {
+		case <-db.memSvc.done:
+		default:
+			t.Error("Memtable serialization should be stopped after close")
+		}
+		select {
+		case <-db.compactSvc.done:
+		default:
+			t.Error("Compaction should be stopped after close")
+		}
+		if len(db.memSvc.getQueuedTables()) != 0 {
+			t.Error("All the enqueued memtables should be serialized before close")
+		}
+		if err := db.Write("key", []byte("value")); !errors.Is(err, ErrDBClosed) {
+			t.Errorf("Write after close should fail, got %v", err)
+		}
+		if _, err := db.Get("key-000"); !errors.Is(err, ErrDBClosed) {
+			t.Errorf("Read after close should fail, got %v", err)
+		}
+		if err := db.Close(); !errors.Is(err, ErrDBClosed) {
+			t.Errorf("Closing twice should fail, got %v", err)
+		}
+
+		segments, _ := listWalSegments(db.wal.dir)
+		if flush && len(segments) != 0 {
+			t.Errorf("Expected no WAL segment left after flushing on close, got %d", len(segments))
+		}
+		if !flush && len(segments) != 1 {
+			t.Errorf("Expected the WAL segment of the last memtable to be kept, got %d", len(segments))
+		}
+
+		reopened, err := NewDatabase(configs...)
+		if err != nil {
+			t.Fatalf("Failed to reopen database - Error: %s", err.Error())
+		}
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("key-%03d", i)
+			if value, _ := reopened.Get(key); string(value) != fmt.Sprintf("value-%03d", i) {
+				t.Errorf("Expected value of key %s after reopen (flush %t), got %s", key, flush, string(value))
+			}
+		}
+		if err := reopened.Close(); err != nil {
+			t.Errorf("Failed to close reopened database - Error: %s", err.Error())
+		}
+	}
+}
+
+func Test_dbCloseShouldReturnBackgroundError(t *testing.T) {
+	db, err := NewDatabase(
+		ConfigDBDir(setupTestDBDir(t)),
+		ConfigFlushOnClose(true),
+		ConfigLogLevel(log.InfoLevel),
+	)
+	if err != nil {
+		t.Fatalf("Failed to initialize database - Error: %s", err.Error())
+	}
+	db.Write("key", []byte("value"))
+
+	// serializing the memtable fails without the sstable directory
+	os.RemoveAll(db.sstableDir)
+	if err := db.Close(); err == nil {
+		t.Error("Failure to serialize memtable on close should be returned")
+	}
+}
+
+func Test_dbVerifyChecksumsShouldFindCorruptedBlocks(t *testing.T) {
+	db, err := NewDatabase(
+		ConfigDBDir(setupTestDBDir(t)),
+		ConfigMemtableSizeByte(512),
+		ConfigSStableDatablockSizeByte(512/4),
+	)
+	if err != nil {
+		t.Fatalf("Failed to initialize database - Error: %s", err.Error())
+	}
+	defer db.Close()
+
+	for i := 0; i < 200; i++ {
+		db.Write(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%03d", i)))
+	}
+	waitForMemtableSerialization(t, db)
+
+	if err := db.VerifyChecksums(); err != nil {
+		t.Fatalf("Expected no corruption - Error: %s", err.Error())
+	}
+
+	allMeta, _ := db.getAllSSTableFileMetadata()
+	file := filepath.Join(db.sstableDir, allMeta[0].filename)
+	sr, _ := NewBasicSSTableReader(file)
+	offset, size, _ := sr.Index().GetOffset(allMeta[0].smallestKey)
+	sr.Close()
+	corruptFile(t, file, int64(offset+size/2))
+
+	err = db.VerifyChecksums()
+	if !errors.Is(err, ErrCorruption) || !strings.Contains(err.Error(), file) {
+		t.Errorf("Expected corruption error naming file %s, got %v", file, err)
+	}
+}
+
 func Benchmark_dbWrite(b *testing.B) {
 	testDBDir := setupTestDBDir(b)
 	// use default setting
@@ -141,3 +808,114 @@ func Benchmark_dbWriteStrictModeOn(b *testing.B) {
 		)
 	}
 }
+
+func Benchmark_dbWriteStrictModeOnParallel(b *testing.B) {
+	testDBDir := setupTestDBDir(b)
+	db, err := NewDatabase(
+		ConfigWalStrictMode(true),
+		ConfigDBDir(testDBDir),
+		ConfigLogLevel(log.InfoLevel),
+	)
+	if err != nil {
+		b.Errorf("Failed to initialize database - Error: %s", err.Error())
+	}
+
+	// concurrent writers share WAL syncs
+	var i int64
+	b.SetParallelism(16)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			n := atomic.AddInt64(&i, 1)
+			db.Write(
+				fmt.Sprintf("key-%05d", n),
+				[]byte(fmt.Sprintf("value-%05d", n)),
+			)
+		}
+	})
+}
+
+func Test_dbShouldHonourPerWriteWalOptions(t *testing.T) {
+	testDBDir := setupTestDBDir(t)
+	db, err := NewDatabase(ConfigDBDir(testDBDir))
+	if err != nil {
+		t.Fatalf("Failed to initialize database - Error: %s", err.Error())
+	}
+	db.WriteWithOptions("synced", []byte("value"), &WriteOptions{Sync: true})
+	db.WriteWithOptions("unlogged", []byte("value"), &WriteOptions{DisableWAL: true})
+	db.DeleteWithOptions("synced-deleted", &WriteOptions{Sync: true})
+
+	// open the same directory again as if the previous process had crashed
+	recovered, err := NewDatabase(ConfigDBDir(testDBDir))
+	if err != nil {
+		t.Fatalf("Failed to open existing database - Error: %s", err.Error())
+	}
+	if value, _ := recovered.Get("synced"); string(value) != "value" {
+		t.Errorf("Expected synced write to be recovered, got %s", value)
+	}
+	if value, _ := recovered.Get("unlogged"); value != nil {
+		t.Errorf("Expected write skipping the WAL to be lost, got %s", value)
+	}
+
+	// writes skipping the WAL are serialized on close even without flushing on close
+	recovered.WriteWithOptions("unlogged", []byte("value"), &WriteOptions{DisableWAL: true})
+	if err := recovered.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewDatabase(ConfigDBDir(testDBDir))
+	if err != nil {
+		t.Fatalf("Failed to reopen database - Error: %s", err.Error())
+	}
+	defer reopened.Close()
+	if value, _ := reopened.Get("unlogged"); string(value) != "value" {
+		t.Errorf("Expected write skipping the WAL to be kept after close, got %s", value)
+	}
+}
+
+func Test_dbWriteWithNoSlowdownShouldFailInsteadOfWaiting(t *testing.T) {
+	testDBDir := setupTestDBDir(t)
+	db, err := NewDatabase(ConfigDBDir(testDBDir), ConfigMemtableSizeByte(64))
+	if err != nil {
+		t.Fatalf("Failed to initialize database - Error: %s", err.Error())
+	}
+	defer db.Close()
+
+	// pretend a memtable is being serialized
+	busy := NewBasicMemTable(os.TempDir(), false)
+	defer busy.Wal().Delete()
+	db.memSvc.lock.Lock()
+	db.memSvc.queue = append(db.memSvc.queue, busy)
+	db.memSvc.lock.Unlock()
+
+	noSlowdown := &WriteOptions{NoSlowdown: true}
+	if err := db.WriteWithOptions("key", []byte("value"), noSlowdown); err != nil {
+		t.Errorf("Expected write leaving room in the memtable to succeed - Error: %s", err.Error())
+	}
+	if err := db.WriteWithOptions("full", make([]byte, 64), noSlowdown); !errors.Is(err, ErrWriteStall) {
+		t.Errorf("Expected write filling up the memtable to stall, got %v", err)
+	}
+	if value, _ := db.Get("full"); value != nil {
+		t.Error("Expected stalled write not to be applied")
+	}
+
+	db.memSvc.lock.Lock()
+	db.memSvc.queue = db.memSvc.queue[:0]
+	db.memSvc.lock.Unlock()
+	if err := db.WriteWithOptions("full", make([]byte, 64), noSlowdown); err != nil {
+		t.Errorf("Expected write to succeed once no memtable is being serialized - Error: %s", err.Error())
+	}
+}
+
+func Test_dbShouldRefuseToOpenWithDifferentCompactionStrategy(t *testing.T) {
+	testDBDir := setupTestDBDir(t)
+	db, err := NewDatabase(ConfigDBDir(testDBDir))
+	if err != nil {
+		t.Fatalf("Failed to initialize database - Error: %s", err.Error())
+	}
+	db.Close()
+
+	_, err = NewDatabase(ConfigDBDir(testDBDir), ConfigCompactionStrategy(NewFIFOCompactionStrategy(0, 0)))
+	var mErr *ManifestError
+	if !errors.As(err, &mErr) || mErr.Op != OP_MANIFEST_CHECK_SETTING {
+		t.Errorf("Expected database with another compaction strategy to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package dbengine
+
+import "sync"
+
+// maxWriteGroupSizeByte - caps how much data (in bytes) a write group can take, so that a small write doesn't
+// wait for a huge group to be committed ahead of it. A single batch bigger than the cap is a group on its own.
+const maxWriteGroupSizeByte = 1024 * 1024
+
+// writeQueue - lines up concurrent writes so that they're committed in groups. The writer at the front of the
+// queue is the leader, it merges the batches queued behind it into a single batch so that the whole group is
+// recorded in the WAL as one log (written and synced once in strict mode) and applied at once. The others wait
+// for the leader to release them with the result of the group. Writes arriving while a group is committed
+// queue up for the next group, which is how the cost of a sync is shared among concurrent writers.
+type writeQueue struct {
+	lock    sync.Mutex
+	cond    *sync.Cond
+	writers []*queuedWrite
+}
+
+// queuedWrite - a batch waiting in the queue, done is set once the group it belongs to is committed
+type queuedWrite struct {
+	batch *WriteBatch
+	opts  WriteOptions
+	// alone - the write is committed in a group of its own, by the commitFn it's queued with
+	alone bool
+	done  bool
+	err   error
+}
+
+func newWriteQueue() *writeQueue {
+	q := &writeQueue{}
+	q.cond = sync.NewCond(&q.lock)
+	return q
+}
+
+// commit - queues the batch and waits until it's committed by commitFn, either in a group led by this writer
+// or by another one. Batches are committed in the order they're queued, returns the result of the group. Only
+// batches written with the same options are committed together.
+func (q *writeQueue) commit(
+	batch *WriteBatch, opts *WriteOptions, commitFn func(*WriteBatch, *WriteOptions) error,
+) error {
+	return q.commitWrite(&queuedWrite{batch: batch, opts: writeOptionsOf(opts)}, commitFn)
+}
+
+// commitAlone - same as `commit`, except that the batch is committed in a group of its own, for writes whose
+// commitFn does more than applying the batch, e.g. checking an optimistic transaction for conflicts
+func (q *writeQueue) commitAlone(
+	batch *WriteBatch, opts *WriteOptions, commitFn func(*WriteBatch, *WriteOptions) error,
+) error {
+	return q.commitWrite(&queuedWrite{batch: batch, opts: writeOptionsOf(opts), alone: true}, commitFn)
+}
+
+// commitWrite - queues the write and waits until it's committed, see `commit`
+func (q *writeQueue) commitWrite(w *queuedWrite, commitFn func(*WriteBatch, *WriteOptions) error) error {
+	q.lock.Lock()
+	q.writers = append(q.writers, w)
+	for !w.done && q.writers[0] != w {
+		q.cond.Wait()
+	}
+	if w.done {
+		q.lock.Unlock()
+		return w.err
+	}
+	group := q.groupLocked()
+	q.lock.Unlock()
+
+	// only the leader gets here, the writers behind it can't move until it's done
+	err := commitFn(mergeBatches(group), &w.opts)
+
+	q.lock.Lock()
+	for _, follower := range group {
+		follower.err = err
+		follower.done = true
+	}
+	q.writers = q.writers[len(group):]
+	q.cond.Broadcast()
+	q.lock.Unlock()
+	return err
+}
+
+// groupLocked - returns the writes from the front of the queue that are committed together, lock must be held
+func (q *writeQueue) groupLocked() []*queuedWrite {
+	leader := q.writers[0]
+	size := leader.batch.sizeBytes()
+	n := 1
+	for ; n < len(q.writers) && !leader.alone; n++ {
+		size += q.writers[n].batch.sizeBytes()
+		if size > maxWriteGroupSizeByte || q.writers[n].opts != leader.opts || q.writers[n].alone {
+			break
+		}
+	}
+	return q.writers[:n:n]
+}
+
+// mergeBatches - returns a batch with the operations of all the batches of the group in order
+func mergeBatches(group []*queuedWrite) *WriteBatch {
+	if len(group) == 1 {
+		return group[0].batch
+	}
+	merged := NewWriteBatch()
+	for _, w := range group {
+		merged.records = append(merged.records, w.batch.records...)
+	}
+	return merged
+}
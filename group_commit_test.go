@@ -0,0 +1,187 @@
+package dbengine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_writeQueueShouldCommitConcurrentBatchesInGroups(t *testing.T) {
+	q := newWriteQueue()
+
+	var lock sync.Mutex
+	committed := make([]string, 0)
+	groups := 0
+	first := make(chan struct{})
+	release := make(chan struct{})
+	commitFn := func(group *WriteBatch, _ *WriteOptions) error {
+		lock.Lock()
+		groups++
+		isFirst := groups == 1
+		for _, record := range group.records {
+			committed = append(committed, record.Key)
+		}
+		lock.Unlock()
+		if isFirst {
+			// hold the first group so that the other writers queue up behind it
+			close(first)
+			<-release
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		batch := NewWriteBatch()
+		batch.Put("key-leader", nil)
+		q.commit(batch, nil, commitFn)
+	}()
+	<-first
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			batch := NewWriteBatch()
+			batch.Put(fmt.Sprintf("key-%02d-a", i), nil)
+			batch.Put(fmt.Sprintf("key-%02d-b", i), nil)
+			if err := q.commit(batch, nil, commitFn); err != nil {
+				t.Errorf("Failed to commit batch - Error: %s", err.Error())
+			}
+		}(i)
+	}
+	// wait for the writers to queue up
+	for queued := 0; queued < 11; time.Sleep(time.Millisecond) {
+		q.lock.Lock()
+		queued = len(q.writers)
+		q.lock.Unlock()
+	}
+	close(release)
+	wg.Wait()
+
+	if len(committed) != 21 {
+		t.Fatalf("Expected 21 records committed, got %d", len(committed))
+	}
+	if groups != 2 {
+		t.Errorf("Expected the writers queued behind the leader to be committed as one group, got %d groups", groups)
+	}
+	// the operations of a batch stay together and in order
+	for i := 1; i < len(committed); i += 2 {
+		if committed[i][:len("key-00")] != committed[i+1][:len("key-00")] {
+			t.Errorf("Expected records of the same batch to be adjacent, got %s and %s", committed[i], committed[i+1])
+		}
+	}
+}
+
+func Test_writeQueueShouldReturnResultOfGroupToEveryWriter(t *testing.T) {
+	q := newWriteQueue()
+	errCommit := errors.New("commit failed")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			batch := NewWriteBatch()
+			batch.Put(fmt.Sprintf("key-%d", i), nil)
+			err := q.commit(batch, nil, func(*WriteBatch, *WriteOptions) error { return errCommit })
+			if err != errCommit {
+				t.Errorf("Expected error of the group, got %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(q.writers) != 0 {
+		t.Errorf("Expected queue to be empty, got %d writers", len(q.writers))
+	}
+}
+
+func Test_writeQueueShouldCapGroupSize(t *testing.T) {
+	q := newWriteQueue()
+	for i := 0; i < 3; i++ {
+		batch := NewWriteBatch()
+		batch.Put(fmt.Sprintf("key-%d", i), make([]byte, maxWriteGroupSizeByte/2))
+		q.writers = append(q.writers, &queuedWrite{batch: batch})
+	}
+
+	if group := q.groupLocked(); len(group) != 1 {
+		t.Errorf("Expected group to stop before it exceeds the size cap, got %d writes", len(group))
+	}
+}
+
+func Test_writeQueueShouldOnlyGroupWritesWithSameOptions(t *testing.T) {
+	q := newWriteQueue()
+	for _, opts := range []WriteOptions{{}, {}, {Sync: true}, {}} {
+		batch := NewWriteBatch()
+		batch.Put("key", nil)
+		q.writers = append(q.writers, &queuedWrite{batch: batch, opts: opts})
+	}
+
+	if group := q.groupLocked(); len(group) != 2 {
+		t.Errorf("Expected group to stop before a write with different options, got %d writes", len(group))
+	}
+}
+
+func Test_writeQueueShouldCommitWritesQueuedAloneInGroupsOfTheirOwn(t *testing.T) {
+	q := newWriteQueue()
+	for _, alone := range []bool{false, false, true, false} {
+		batch := NewWriteBatch()
+		batch.Put("key", nil)
+		q.writers = append(q.writers, &queuedWrite{batch: batch, alone: alone})
+	}
+
+	if group := q.groupLocked(); len(group) != 2 {
+		t.Errorf("Expected group to stop before a write queued alone, got %d writes", len(group))
+	}
+	q.writers = q.writers[2:]
+	if group := q.groupLocked(); len(group) != 1 {
+		t.Errorf("Expected write queued alone to be committed on its own, got %d writes", len(group))
+	}
+}
+
+func Test_dbShouldShareWalLogsAmongConcurrentWriters(t *testing.T) {
+	testDBDir := setupTestDBDir(t)
+	db, err := NewDatabase(ConfigDBDir(testDBDir), ConfigWalStrictMode(true))
+	if err != nil {
+		t.Fatalf("Failed to initialize database - Error: %s", err.Error())
+	}
+	defer db.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				key := fmt.Sprintf("key-%02d-%02d", g, i)
+				if err := db.Write(key, []byte("value-"+key)); err != nil {
+					t.Errorf("Failed to write key %s - Error: %s", key, err.Error())
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	for g := 0; g < 16; g++ {
+		for i := 0; i < 20; i++ {
+			key := fmt.Sprintf("key-%02d-%02d", g, i)
+			if value, err := db.Get(key); err != nil || string(value) != "value-"+key {
+				t.Errorf("Expected value of key %s, got %s - Error: %v", key, value, err)
+			}
+		}
+	}
+
+	logs := 0
+	if err := db.curMem.Wal().Replay(func([]byte) error { logs++; return nil }); err != nil {
+		t.Fatalf("Failed to replay WAL - Error: %s", err.Error())
+	}
+	if logs == 0 || logs > 16*20 {
+		t.Errorf("Expected at most one WAL log per write, got %d logs", logs)
+	}
+	t.Logf("%d writes recorded in %d WAL logs", 16*20, logs)
+}
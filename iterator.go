@@ -0,0 +1,173 @@
+package dbengine
+
+// RecordIterator - iterates through records in key order, in either direction. Versions of the same key are
+// ordered from the latest to the earliest
+type RecordIterator interface {
+	// First - moves to the first record
+	First()
+
+	// Last - moves to the last record
+	Last()
+
+	// Seek - moves to the latest version of the first key that is greater than or equal to key
+	Seek(key string)
+
+	// Valid - returns true if the iterator is positioned at a record
+	Valid() bool
+
+	// Next - moves to the next record, only valid to call when `Valid` is true
+	Next()
+
+	// Prev - moves to the previous record, only valid to call when `Valid` is true
+	Prev()
+
+	// Record - returns the current record, only valid to call when `Valid` is true
+	Record() *MemtableRecord
+
+	// Error - returns the error encountered during iteration, if any
+	Error() error
+}
+
+// compareRecords - compares the versions of two records, see `compareInternalKey`
+func compareRecords(r1, r2 *MemtableRecord) int {
+	return compareInternalKey(r1.Key, r1.Seq, r2.Key, r2.Seq)
+}
+
+// mergingIterator - merges multiple record iterators into one, every version of the records from all the
+// iterators is returned in order. When the exact same version exists in more than one iterator, the record
+// from the iterator that comes first is returned first
+type mergingIterator struct {
+	iters   []RecordIterator
+	cur     int  // cur - index of the iterator the current record comes from, -1 if there is none
+	forward bool // forward - true if the last move was forward, all iterators are positioned after the current record
+}
+
+func newMergingIterator(iters ...RecordIterator) *mergingIterator {
+	return &mergingIterator{
+		iters:   iters,
+		cur:     -1,
+		forward: true,
+	}
+}
+
+// First - moves to the first record
+func (it *mergingIterator) First() {
+	for _, iter := range it.iters {
+		iter.First()
+	}
+	it.forward = true
+	it.findSmallest()
+}
+
+// Last - moves to the last record
+func (it *mergingIterator) Last() {
+	for _, iter := range it.iters {
+		iter.Last()
+	}
+	it.forward = false
+	it.findLargest()
+}
+
+// Seek - moves to the latest version of the first key that is greater than or equal to key
+func (it *mergingIterator) Seek(key string) {
+	for _, iter := range it.iters {
+		iter.Seek(key)
+	}
+	it.forward = true
+	it.findSmallest()
+}
+
+// findSmallest - points cur to the iterator with the smallest record, the earlier iterator wins on a tie
+func (it *mergingIterator) findSmallest() {
+	it.cur = -1
+	var smallest *MemtableRecord
+	for i, iter := range it.iters {
+		if !iter.Valid() {
+			continue
+		}
+		if record := iter.Record(); it.cur == -1 || compareRecords(record, smallest) < 0 {
+			it.cur = i
+			smallest = record
+		}
+	}
+}
+
+// findLargest - points cur to the iterator with the largest record, the earlier iterator wins on a tie
+func (it *mergingIterator) findLargest() {
+	it.cur = -1
+	var largest *MemtableRecord
+	for i, iter := range it.iters {
+		if !iter.Valid() {
+			continue
+		}
+		if record := iter.Record(); it.cur == -1 || compareRecords(record, largest) > 0 {
+			it.cur = i
+			largest = record
+		}
+	}
+}
+
+// Valid - returns true if the iterator is positioned at a record
+func (it *mergingIterator) Valid() bool {
+	return it.cur != -1 && it.Error() == nil
+}
+
+// Next - moves to the next record
+func (it *mergingIterator) Next() {
+	if !it.forward {
+		// iterators other than cur are positioned before the current record, move them after it
+		record := it.Record()
+		for i, iter := range it.iters {
+			if i == it.cur {
+				continue
+			}
+			iter.Seek(record.Key)
+			for iter.Valid() && compareRecords(iter.Record(), record) <= 0 {
+				iter.Next()
+			}
+		}
+		it.forward = true
+	}
+	it.iters[it.cur].Next()
+	it.findSmallest()
+}
+
+// Prev - moves to the previous record
+func (it *mergingIterator) Prev() {
+	if it.forward {
+		// iterators other than cur are positioned after the current record, move them before it
+		record := it.Record()
+		for i, iter := range it.iters {
+			if i == it.cur {
+				continue
+			}
+			iter.Seek(record.Key)
+			for iter.Valid() && compareRecords(iter.Record(), record) < 0 {
+				iter.Next()
+			}
+			if iter.Valid() {
+				iter.Prev()
+			} else {
+				iter.Last()
+			}
+		}
+		it.forward = false
+	}
+	it.iters[it.cur].Prev()
+	it.findLargest()
+}
+
+// Record - returns the current record
+func (it *mergingIterator) Record() *MemtableRecord {
+	return it.iters[it.cur].Record()
+}
+
+// Error - returns the first error encountered by any of the merged iterators
+func (it *mergingIterator) Error() error {
+	for _, iter := range it.iters {
+		if err := iter.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package dbengine
+
+import (
+	"os"
+	"testing"
+)
+
+func getTestMergingIterator(t *testing.T) *mergingIterator {
+	t.Helper()
+
+	older := NewBasicMemTable(os.TempDir(), false)
+	older.Write("a", []byte("a-old"), 1, nil)
+	older.Write("b", []byte("b-old"), 2, nil)
+	older.Write("c", []byte("c-old"), 3, nil)
+	older.Write("d", []byte("d-old"), 4, nil)
+	newer := NewBasicMemTable(os.TempDir(), false)
+	newer.Write("b", []byte("b-new"), 5, nil)
+	newer.Write("d", []byte("d-new"), 6, nil)
+
+	return newMergingIterator(newer.NewIterator(), older.NewIterator())
+}
+
+func Test_mergingIteratorShouldReturnEveryVersionInOrder(t *testing.T) {
+	it := getTestMergingIterator(t)
+
+	got := ""
+	for it.First(); it.Valid(); it.Next() {
+		got += string(it.Record().Value) + " "
+	}
+	if got != "a-old b-new b-old c-old d-new d-old " {
+		t.Errorf("Unexpected records - %s", got)
+	}
+
+	got = ""
+	for it.Last(); it.Valid(); it.Prev() {
+		got += string(it.Record().Value) + " "
+	}
+	if got != "d-old d-new c-old b-old b-new a-old " {
+		t.Errorf("Unexpected records in reverse - %s", got)
+	}
+}
+
+func Test_mergingIteratorShouldSwitchDirection(t *testing.T) {
+	it := getTestMergingIterator(t)
+
+	it.Seek("b")
+	it.Next()
+	it.Prev()
+	if !it.Valid() || string(it.Record().Value) != "b-new" {
+		t.Fatalf("Prev after Next should go back to b-new")
+	}
+	it.Prev()
+	if !it.Valid() || string(it.Record().Value) != "a-old" {
+		t.Fatalf("Prev should move to a-old")
+	}
+	it.Next()
+	it.Next()
+	if !it.Valid() || string(it.Record().Value) != "b-old" {
+		t.Errorf("Next after Prev should move forward without repeating records")
+	}
+}
@@ -0,0 +1,139 @@
+package dbengine
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrLockTimeout - the key stayed locked by another transaction for longer than the lock timeout
+	ErrLockTimeout = errors.New("timed out waiting for key lock")
+	// ErrDeadlock - waiting for the key lock would cause a deadlock, the transaction was aborted
+	ErrDeadlock = errors.New("deadlock detected")
+)
+
+// lockManager - hands out exclusive key locks to lock owners (transactions and writes). An owner that asks
+// for a key locked by another owner waits until the key is released or the timeout expires.
+//
+// Owners waiting for each other form a wait-for graph. Since an owner only waits for one key at a time, each
+// owner has at most one outgoing edge, and a deadlock is a cycle in the graph. The cycle is detected when the
+// owner that would close it asks for the lock, and that owner is picked as the victim.
+type lockManager struct {
+	lock        sync.Mutex
+	locks       map[string]*keyLock
+	held        map[uint64][]string // held - keys locked by each owner
+	waitsFor    map[uint64]uint64   // waitsFor - edges of the wait-for graph, waiting owner to lock owner
+	nextOwnerID uint64
+}
+
+// keyLock - an exclusive lock on a key, released is closed once the owner releases the key
+type keyLock struct {
+	owner    uint64
+	released chan struct{}
+}
+
+func newLockManager() *lockManager {
+	return &lockManager{
+		locks:       make(map[string]*keyLock),
+		held:        make(map[uint64][]string),
+		waitsFor:    make(map[uint64]uint64),
+		nextOwnerID: 1,
+	}
+}
+
+// newOwner - returns a new unique lock owner id
+func (lm *lockManager) newOwner() uint64 {
+	lm.lock.Lock()
+	defer lm.lock.Unlock()
+
+	owner := lm.nextOwnerID
+	lm.nextOwnerID++
+	return owner
+}
+
+// acquire - locks key for owner, waits up to timeout if the key is locked by another owner. Returns
+// `ErrDeadlock` if waiting would cause a deadlock and `ErrLockTimeout` if the key is still locked after timeout
+func (lm *lockManager) acquire(owner uint64, key string, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		lm.lock.Lock()
+		kl, locked := lm.locks[key]
+		if !locked {
+			lm.locks[key] = &keyLock{owner: owner, released: make(chan struct{})}
+			lm.held[owner] = append(lm.held[owner], key)
+			lm.lock.Unlock()
+			return nil
+		}
+		if kl.owner == owner {
+			lm.lock.Unlock()
+			return nil
+		}
+		if lm.waitsOn(kl.owner, owner) {
+			lm.lock.Unlock()
+			return ErrDeadlock
+		}
+		lm.waitsFor[owner] = kl.owner
+		lm.lock.Unlock()
+
+		select {
+		case <-kl.released:
+			lm.lock.Lock()
+			delete(lm.waitsFor, owner)
+			lm.lock.Unlock()
+		case <-timer.C:
+			lm.lock.Lock()
+			delete(lm.waitsFor, owner)
+			lm.lock.Unlock()
+			return ErrLockTimeout
+		}
+	}
+}
+
+// acquireAll - locks all the keys for owner in key order, so that owners locking overlapping sets of keys this
+// way never deadlock with each other. Keys locked so far are released if any of them fails.
+func (lm *lockManager) acquireAll(owner uint64, keys []string, timeout time.Duration) error {
+	sorted := make([]string, len(keys))
+	copy(sorted, keys)
+	sort.Strings(sorted)
+
+	for _, key := range sorted {
+		if err := lm.acquire(owner, key, timeout); err != nil {
+			lm.releaseAll(owner)
+			return err
+		}
+	}
+	return nil
+}
+
+// waitsOn - returns true if owner is waiting for target, directly or through other owners. lock must be held
+func (lm *lockManager) waitsOn(owner, target uint64) bool {
+	for steps := 0; steps <= len(lm.waitsFor); steps++ {
+		if owner == target {
+			return true
+		}
+		next, waiting := lm.waitsFor[owner]
+		if !waiting {
+			return false
+		}
+		owner = next
+	}
+	return false
+}
+
+// releaseAll - releases all the keys locked by owner
+func (lm *lockManager) releaseAll(owner uint64) {
+	lm.lock.Lock()
+	defer lm.lock.Unlock()
+
+	for _, key := range lm.held[owner] {
+		if kl := lm.locks[key]; kl != nil && kl.owner == owner {
+			delete(lm.locks, key)
+			close(kl.released)
+		}
+	}
+	delete(lm.held, owner)
+}
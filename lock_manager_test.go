@@ -0,0 +1,51 @@
+package dbengine
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_lockManagerShouldGrantLockOnceReleased(t *testing.T) {
+	lm := newLockManager()
+	owner1, owner2 := lm.newOwner(), lm.newOwner()
+
+	if err := lm.acquire(owner1, "key", time.Second); err != nil {
+		t.Fatalf("Failed to lock free key - Error: %s", err.Error())
+	}
+	if err := lm.acquire(owner1, "key", time.Second); err != nil {
+		t.Errorf("Locking a key again by its owner should succeed, got %s", err.Error())
+	}
+	if err := lm.acquire(owner2, "key", 10*time.Millisecond); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("Expected lock timeout, got %v", err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		lm.releaseAll(owner1)
+	}()
+	if err := lm.acquire(owner2, "key", time.Second); err != nil {
+		t.Errorf("Lock should be granted once released, got %s", err.Error())
+	}
+	if len(lm.waitsFor) != 0 {
+		t.Errorf("Wait-for graph should be empty once no one is waiting, got %v", lm.waitsFor)
+	}
+}
+
+func Test_lockManagerShouldDetectDeadlockThroughWaitChain(t *testing.T) {
+	lm := newLockManager()
+	owner1, owner2, owner3 := lm.newOwner(), lm.newOwner(), lm.newOwner()
+	lm.acquire(owner1, "key-1", time.Second)
+	lm.acquire(owner2, "key-2", time.Second)
+	lm.acquire(owner3, "key-3", time.Second)
+	// owner1 waits for owner2, owner2 waits for owner3
+	lm.waitsFor[owner1] = owner2
+	lm.waitsFor[owner2] = owner3
+
+	if err := lm.acquire(owner3, "key-1", time.Second); !errors.Is(err, ErrDeadlock) {
+		t.Errorf("Expected deadlock, got %v", err)
+	}
+	if err := lm.acquire(owner3, "key-2", 10*time.Millisecond); !errors.Is(err, ErrDeadlock) {
+		t.Errorf("Expected deadlock, got %v", err)
+	}
+}
@@ -0,0 +1,713 @@
+package dbengine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DrakeW/go-db-engine/pb"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+)
+
+// Manifest layout:
+// - <DBDir>/CURRENT - a text file containing the name of the manifest file currently in use
+// - <DBDir>/MANIFEST-<number> - a log of version edits, each edit is a varint size prefixed serialized
+// protocol buffer (see `pb.VersionEdit`)
+//
+// The first edit of every manifest file is a snapshot of the whole database (settings + all the live sstable
+// files), every edit after that describes a change (e.g. sstable files added by a memtable serialization).
+// Replaying all the edits in order gives the latest set of live sstable files.
+//
+// A new manifest file is written every time the database is opened, the CURRENT file is then switched to it
+// by an atomic rename so a crash at any point leaves CURRENT pointing to a complete manifest.
+
+const (
+	// manifestFormatVersion - the version of the on-disk format of the database, databases created with a
+	// newer format version can't be opened
+	manifestFormatVersion = 1
+	currentFileName       = "CURRENT"
+	manifestFilePrefix    = "MANIFEST-"
+)
+
+const (
+	OP_MANIFEST_CREATE_FILE   = "OP_MANIFEST_CREATE_FILE"
+	OP_MANIFEST_READ_FILE     = "OP_MANIFEST_READ_FILE"
+	OP_MANIFEST_WRITE_EDIT    = "OP_MANIFEST_WRITE_EDIT"
+	OP_MANIFEST_SET_CURRENT   = "OP_MANIFEST_SET_CURRENT"
+	OP_MANIFEST_CHECK_SETTING = "OP_MANIFEST_CHECK_SETTING"
+	OP_MANIFEST_IMPORT_FILES  = "OP_MANIFEST_IMPORT_FILES"
+	OP_MANIFEST_CLOSE_FILE    = "OP_MANIFEST_CLOSE_FILE"
+)
+
+// ManifestError - includes error for specific manifest operation
+type ManifestError struct {
+	Op  string
+	Err error
+}
+
+func (mErr *ManifestError) Error() string {
+	return fmt.Sprintf("Manifest operation (code %s) failed - Error: %s", mErr.Op, mErr.Err.Error())
+}
+
+func (mErr *ManifestError) Unwrap() error {
+	return mErr.Err
+}
+
+// version - an immutable view of the live sstable files of the database, a new version is created for
+// every version edit applied
+type version struct {
+	// levels[0] is ordered from the latest file to the earliest
+	levels [][]*SSTableFileMetadata
+	// refs - number of readers still using the version, files of the version can't be deleted until
+	// it drops to 0
+	refs int
+}
+
+// manifest - keeps track of the current version of the database and persists every change to it
+type manifest struct {
+	lock           sync.Mutex
+	dbDir          string
+	sstableDir     string
+	file           *os.File
+	fileNumber     uint64
+	nextFileNumber uint64
+	// lastSequence - largest sequence number of the writes persisted in sstable files
+	lastSequence uint64
+	setting      *pb.DBSetting
+	current      *version
+	// oldVersions - versions that are no longer current but still in use
+	oldVersions []*version
+	// obsoleteFiles - files removed from the current version but not deleted from disk yet
+	obsoleteFiles []*SSTableFileMetadata
+	// tables - readers of obsolete files are evicted from the table cache before the files are deleted
+	tables *tableCache
+}
+
+// openManifest - loads the manifest of the database under `dbDir`, or creates a new one if the database
+// is new. sstable files that exist in `sstableDir` but aren't part of the manifest are removed.
+func openManifest(dbDir, sstableDir string, setting *DBSetting) (*manifest, error) {
+	m := &manifest{
+		dbDir:          dbDir,
+		sstableDir:     sstableDir,
+		nextFileNumber: 1,
+		setting:        settingToPb(setting),
+		current:        &version{levels: [][]*SSTableFileMetadata{{}}},
+	}
+
+	currentManifest, err := readCurrentFile(dbDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if currentManifest != "" {
+		if err := m.replay(filepath.Join(dbDir, currentManifest)); err != nil {
+			return nil, err
+		}
+		if err := m.checkSetting(setting); err != nil {
+			return nil, err
+		}
+		// the WAL segments left behind are all in the configured directory, the new ones go there too
+		m.setting.WalDir = setting.WalDir
+	} else if err := m.importSSTableFiles(sstableDir); err != nil {
+		// databases created before the manifest existed only have the sstable files
+		return nil, err
+	}
+
+	if err := m.writeNewManifestFile(); err != nil {
+		return nil, err
+	}
+	if currentManifest != "" {
+		if err := os.Remove(filepath.Join(dbDir, currentManifest)); err != nil {
+			log.Warnf("Failed to delete old manifest file %s - Error: %s", currentManifest, err.Error())
+		}
+	}
+
+	if err := m.removeUnknownSSTableFiles(sstableDir); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// readCurrentFile - returns the name of the manifest file that CURRENT points to, empty if there is none
+func readCurrentFile(dbDir string) (string, error) {
+	currentFile := filepath.Join(dbDir, currentFileName)
+	content, err := ioutil.ReadFile(currentFile)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", &ManifestError{
+			Op:  OP_MANIFEST_READ_FILE,
+			Err: err,
+		}
+	}
+	// a database with a CURRENT file has a manifest, it can't be taken for one created before the manifest
+	// existed whatever the content of the file
+	manifestFilename := strings.TrimSpace(string(content))
+	if !strings.HasPrefix(manifestFilename, manifestFilePrefix) || filepath.Base(manifestFilename) != manifestFilename {
+		return "", &ManifestError{
+			Op:  OP_MANIFEST_READ_FILE,
+			Err: &CorruptionError{File: currentFile, Reason: fmt.Sprintf("invalid manifest file name %q", manifestFilename)},
+		}
+	}
+	return manifestFilename, nil
+}
+
+// replay - applies all the version edits in the manifest file
+func (m *manifest) replay(manifestFile string) error {
+	f, err := os.Open(manifestFile)
+	if err != nil {
+		return &ManifestError{
+			Op:  OP_MANIFEST_READ_FILE,
+			Err: err,
+		}
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	for {
+		data, err := ReadDataWithVarintPrefix(reader, nil)
+		if err == io.EOF {
+			return nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			// the last edit was never fully written, therefore never took effect either
+			log.Warnf("Manifest file %s ends with a partially written edit, ignored it", manifestFile)
+			return nil
+		}
+		if err != nil {
+			return &ManifestError{
+				Op:  OP_MANIFEST_READ_FILE,
+				Err: err,
+			}
+		}
+
+		edit := &pb.VersionEdit{}
+		if err = proto.Unmarshal(data, edit); err != nil {
+			return &ManifestError{
+				Op:  OP_MANIFEST_READ_FILE,
+				Err: err,
+			}
+		}
+		m.apply(edit)
+	}
+}
+
+// checkSetting - verifies the database can be opened with the configured setting
+func (m *manifest) checkSetting(setting *DBSetting) error {
+	if m.setting == nil {
+		return &ManifestError{
+			Op:  OP_MANIFEST_CHECK_SETTING,
+			Err: fmt.Errorf("manifest doesn't contain database setting"),
+		}
+	}
+	if m.setting.FormatVersion > manifestFormatVersion {
+		return &ManifestError{
+			Op: OP_MANIFEST_CHECK_SETTING,
+			Err: fmt.Errorf(
+				"database was created with format version %d, only up to %d is supported",
+				m.setting.FormatVersion, manifestFormatVersion,
+			),
+		}
+	}
+
+	// files are laid out across levels by the compaction strategy, another strategy would leave the files it
+	// doesn't expect behind forever
+	configured := settingToPb(setting)
+	if recorded := recordedCompactionStrategy(m.setting); recorded != configured.CompactionStrategy {
+		return &ManifestError{
+			Op: OP_MANIFEST_CHECK_SETTING,
+			Err: fmt.Errorf(
+				"database was created with compaction strategy %s, can't be opened with %s",
+				recorded, configured.CompactionStrategy,
+			),
+		}
+	}
+
+	// WAL segments in another directory than the configured one would be left out of recovery, so the directory
+	// can only be changed once there are none left in it
+	recordedWalDir := filepath.Join(m.dbDir, "wal")
+	if m.setting.WalDir != "" {
+		recordedWalDir = m.setting.WalDir
+	}
+	if filepath.Clean(recordedWalDir) != filepath.Clean(setting.walSegmentDir()) {
+		segments, err := listWalSegments(recordedWalDir)
+		if err != nil && !os.IsNotExist(err) {
+			return &ManifestError{
+				Op:  OP_MANIFEST_CHECK_SETTING,
+				Err: err,
+			}
+		}
+		if len(segments) > 0 {
+			return &ManifestError{
+				Op: OP_MANIFEST_CHECK_SETTING,
+				Err: fmt.Errorf(
+					"database has WAL segments in %s, can't be opened with WAL directory %s",
+					recordedWalDir, setting.walSegmentDir(),
+				),
+			}
+		}
+	}
+
+	// the setting recorded is the one used to create the database, changes of the other fields are only tuning
+	// and get logged
+	if !proto.Equal(configured, m.setting) {
+		log.Infof("Database setting changed from %v to %v", m.setting, configured)
+	}
+	return nil
+}
+
+// recordedCompactionStrategy - returns the name of the compaction strategy recorded in the setting, databases
+// created before the strategy was recorded are all leveled
+func recordedCompactionStrategy(setting *pb.DBSetting) string {
+	if setting.CompactionStrategy == "" {
+		return NewLeveledCompactionStrategy().Name()
+	}
+	return setting.CompactionStrategy
+}
+
+// importSSTableFiles - adds the sstable files in `sstableDir` to level 0 ordered by their file name
+func (m *manifest) importSSTableFiles(sstableDir string) error {
+	files, err := ioutil.ReadDir(sstableDir)
+	if err != nil {
+		return &ManifestError{
+			Op:  OP_MANIFEST_IMPORT_FILES,
+			Err: err,
+		}
+	}
+
+	edit := &pb.VersionEdit{}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasPrefix(file.Name(), "sstable_") {
+			continue
+		}
+		reader, err := NewBasicSSTableReader(filepath.Join(sstableDir, file.Name()))
+		if err != nil {
+			return &ManifestError{
+				Op:  OP_MANIFEST_IMPORT_FILES,
+				Err: err,
+			}
+		}
+		smallest, largest, err := reader.(*BasicSSTable).keyRange()
+		if err != nil {
+			return &ManifestError{
+				Op:  OP_MANIFEST_IMPORT_FILES,
+				Err: err,
+			}
+		}
+		edit.AddedFiles = append(edit.AddedFiles, &pb.SSTableFileMeta{
+			Number:       m.newFileNumberLocked(),
+			Filename:     file.Name(),
+			Level:        0,
+			SmallestKey:  smallest,
+			LargestKey:   largest,
+			Size:         file.Size(),
+			CreationTime: file.ModTime().UnixNano(),
+		})
+		log.Infof("Imported sstable file %s into the manifest", file.Name())
+	}
+	m.apply(edit)
+	return nil
+}
+
+// removeUnknownSSTableFiles - deletes sstable files that are not part of the current version (e.g. a
+// memtable serialization that was interrupted before it's recorded in the manifest)
+func (m *manifest) removeUnknownSSTableFiles(sstableDir string) error {
+	files, err := ioutil.ReadDir(sstableDir)
+	if err != nil {
+		return err
+	}
+
+	live := make(map[string]bool)
+	for _, level := range m.current.levels {
+		for _, meta := range level {
+			live[meta.filename] = true
+		}
+	}
+	for _, file := range files {
+		if live[file.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(sstableDir, file.Name())); err != nil {
+			return err
+		}
+		log.Infof("Deleted sstable file %s that is not recorded in the manifest", file.Name())
+	}
+	return nil
+}
+
+// writeNewManifestFile - writes a snapshot of the current state into a new manifest file and points
+// CURRENT to it
+func (m *manifest) writeNewManifestFile() error {
+	m.fileNumber = m.newFileNumberLocked()
+	filename := fmt.Sprintf("%s%06d", manifestFilePrefix, m.fileNumber)
+	f, err := os.OpenFile(filepath.Join(m.dbDir, filename), os.O_APPEND|os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return &ManifestError{
+			Op:  OP_MANIFEST_CREATE_FILE,
+			Err: err,
+		}
+	}
+	m.file = f
+
+	snapshot := &pb.VersionEdit{
+		Setting:        m.setting,
+		NextFileNumber: m.nextFileNumber,
+		LastSequence:   m.lastSequence,
+	}
+	for _, level := range m.current.levels {
+		for _, meta := range level {
+			snapshot.AddedFiles = append(snapshot.AddedFiles, meta.toPb())
+		}
+	}
+	if err := m.writeEdit(snapshot); err != nil {
+		return err
+	}
+
+	return m.setCurrent(filename)
+}
+
+// setCurrent - atomically points the CURRENT file to the manifest file specified. The new CURRENT file is
+// flushed before it replaces the old one and the rename is flushed after, so that a crash leaves either of them
+// in place, never an empty one.
+func (m *manifest) setCurrent(manifestFilename string) error {
+	tmpFile := filepath.Join(m.dbDir, currentFileName+".tmp")
+	if err := writeFileSync(tmpFile, []byte(manifestFilename+"\n")); err != nil {
+		return &ManifestError{
+			Op:  OP_MANIFEST_SET_CURRENT,
+			Err: err,
+		}
+	}
+	if err := os.Rename(tmpFile, filepath.Join(m.dbDir, currentFileName)); err != nil {
+		return &ManifestError{
+			Op:  OP_MANIFEST_SET_CURRENT,
+			Err: err,
+		}
+	}
+	if err := syncDir(m.dbDir); err != nil {
+		return &ManifestError{
+			Op:  OP_MANIFEST_SET_CURRENT,
+			Err: err,
+		}
+	}
+	return nil
+}
+
+// writeFileSync - writes data to a new file named filename and flushes it to the storage device
+func writeFileSync(filename string, data []byte) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeEdit - appends a version edit to the manifest file and flushes it to the storage device
+func (m *manifest) writeEdit(edit *pb.VersionEdit) error {
+	data, err := proto.Marshal(edit)
+	if err != nil {
+		return &ManifestError{
+			Op:  OP_MANIFEST_WRITE_EDIT,
+			Err: err,
+		}
+	}
+	if _, err = WriteDataWithVarintSizePrefix(m.file, data); err != nil {
+		return &ManifestError{
+			Op:  OP_MANIFEST_WRITE_EDIT,
+			Err: err,
+		}
+	}
+	if err = m.file.Sync(); err != nil {
+		return &ManifestError{
+			Op:  OP_MANIFEST_WRITE_EDIT,
+			Err: err,
+		}
+	}
+	return nil
+}
+
+// logAndApply - persists the version edit into the manifest file and makes it the current version. Files
+// deleted by the edit are removed from disk once no version in use refers to them anymore
+func (m *manifest) logAndApply(edit *pb.VersionEdit) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	// allocating the number along with applying the edit makes sure a newly added file always has a
+	// bigger number than the files of any version acquired before
+	for _, file := range edit.AddedFiles {
+		if file.Number == 0 {
+			file.Number = m.newFileNumberLocked()
+		}
+	}
+	edit.NextFileNumber = m.nextFileNumber
+	if err := m.writeEdit(edit); err != nil {
+		return err
+	}
+
+	// a file that is both deleted and added only moves to another level
+	added := make(map[uint64]bool)
+	for _, file := range edit.AddedFiles {
+		added[file.Number] = true
+	}
+	for _, file := range edit.DeletedFiles {
+		if !added[file.Number] {
+			m.obsoleteFiles = append(m.obsoleteFiles, sstableFileMetadataFromPb(file))
+		}
+	}
+
+	prev := m.current
+	m.apply(edit)
+	if prev.refs > 0 {
+		m.oldVersions = append(m.oldVersions, prev)
+	}
+	m.deleteObsoleteFilesLocked()
+	return nil
+}
+
+// acquireVersion - returns the current version, files of the version are guaranteed to exist until the
+// version is released
+func (m *manifest) acquireVersion() *version {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.current.refs++
+	return m.current
+}
+
+// acquireVersionWithFileNumber - acquires the current version along with a newly allocated file number. Any
+// file added to the database afterwards is guaranteed to have a bigger number than the allocated one.
+func (m *manifest) acquireVersionWithFileNumber() (*version, uint64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.current.refs++
+	return m.current, m.newFileNumberLocked()
+}
+
+// releaseVersion - marks the version as no longer in use by the caller
+func (m *manifest) releaseVersion(v *version) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	v.refs--
+	if v.refs > 0 || v == m.current {
+		return
+	}
+	for i, old := range m.oldVersions {
+		if old == v {
+			m.oldVersions = append(m.oldVersions[:i], m.oldVersions[i+1:]...)
+			break
+		}
+	}
+	m.deleteObsoleteFilesLocked()
+}
+
+// deleteObsoleteFilesLocked - deletes obsolete files that are not referred to by any version in use
+func (m *manifest) deleteObsoleteFilesLocked() {
+	inUse := make(map[uint64]bool)
+	for _, v := range append([]*version{m.current}, m.oldVersions...) {
+		for _, level := range v.levels {
+			for _, meta := range level {
+				inUse[meta.number] = true
+			}
+		}
+	}
+
+	remaining := make([]*SSTableFileMetadata, 0)
+	for _, meta := range m.obsoleteFiles {
+		if inUse[meta.number] {
+			remaining = append(remaining, meta)
+			continue
+		}
+		if m.tables != nil {
+			m.tables.evict(meta.number)
+		}
+		if err := os.Remove(filepath.Join(m.sstableDir, meta.filename)); err != nil {
+			log.Warnf("Failed to delete obsolete sstable file %s - Error: %s", meta.filename, err.Error())
+			continue
+		}
+		log.Infof("Deleted obsolete sstable file %s", meta.filename)
+	}
+	m.obsoleteFiles = remaining
+}
+
+// close - closes the manifest file, no version edit can be applied afterwards
+func (m *manifest) close() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if err := m.file.Close(); err != nil {
+		return &ManifestError{
+			Op:  OP_MANIFEST_CLOSE_FILE,
+			Err: err,
+		}
+	}
+	return nil
+}
+
+// apply - applies the version edit on top of the current version
+func (m *manifest) apply(edit *pb.VersionEdit) {
+	if edit.Setting != nil {
+		m.setting = edit.Setting
+	}
+	if edit.NextFileNumber > m.nextFileNumber {
+		m.nextFileNumber = edit.NextFileNumber
+	}
+	if edit.LastSequence > m.lastSequence {
+		m.lastSequence = edit.LastSequence
+	}
+
+	deleted := make(map[uint64]bool)
+	for _, file := range edit.DeletedFiles {
+		deleted[file.Number] = true
+	}
+
+	next := &version{levels: make([][]*SSTableFileMetadata, len(m.current.levels))}
+	for lvl, files := range m.current.levels {
+		next.levels[lvl] = make([]*SSTableFileMetadata, 0, len(files))
+		for _, meta := range files {
+			if !deleted[meta.number] {
+				next.levels[lvl] = append(next.levels[lvl], meta)
+			}
+		}
+	}
+	for _, file := range edit.AddedFiles {
+		for int(file.Level) >= len(next.levels) {
+			next.levels = append(next.levels, make([]*SSTableFileMetadata, 0))
+		}
+		next.levels[file.Level] = append(next.levels[file.Level], sstableFileMetadataFromPb(file))
+		if file.Number >= m.nextFileNumber {
+			m.nextFileNumber = file.Number + 1
+		}
+	}
+
+	// level 0 files may overlap so the latest one has to be looked at first, files of other levels
+	// are ordered by key range
+	sort.Slice(next.levels[0], func(i, j int) bool { return next.levels[0][i].number > next.levels[0][j].number })
+	for lvl := 1; lvl < len(next.levels); lvl++ {
+		files := next.levels[lvl]
+		sort.Slice(files, func(i, j int) bool { return files[i].smallestKey < files[j].smallestKey })
+	}
+
+	m.current = next
+}
+
+// newFileNumber - allocates a new unique number for a sstable file
+func (m *manifest) newFileNumber() uint64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.newFileNumberLocked()
+}
+
+func (m *manifest) newFileNumberLocked() uint64 {
+	number := m.nextFileNumber
+	m.nextFileNumber++
+	return number
+}
+
+// currentVersion - returns the latest version of the database
+func (m *manifest) currentVersion() *version {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.current
+}
+
+// files - returns all the files in the version ordered from the latest to the earliest
+func (v *version) files() []*SSTableFileMetadata {
+	all := make([]*SSTableFileMetadata, 0)
+	for _, level := range v.levels {
+		all = append(all, level...)
+	}
+	return all
+}
+
+// numLevels - returns the number of levels that have ever had files
+func (v *version) numLevels() int {
+	return len(v.levels)
+}
+
+// filesAt - returns files at level, empty if the level doesn't exist yet
+func (v *version) filesAt(level int) []*SSTableFileMetadata {
+	if level >= len(v.levels) {
+		return nil
+	}
+	return v.levels[level]
+}
+
+// levelSize - returns the total size of files at level
+func (v *version) levelSize(level int) int64 {
+	var total int64
+	for _, meta := range v.filesAt(level) {
+		total += meta.size
+	}
+	return total
+}
+
+// overlappingFiles - returns files at level whose key range overlaps with [smallest, largest]
+func (v *version) overlappingFiles(level int, smallest, largest string) []*SSTableFileMetadata {
+	overlapping := make([]*SSTableFileMetadata, 0)
+	for _, meta := range v.filesAt(level) {
+		if meta.largestKey >= smallest && meta.smallestKey <= largest {
+			overlapping = append(overlapping, meta)
+		}
+	}
+	return overlapping
+}
+
+// containsKey - returns true if key falls into the key range of the file
+func (meta *SSTableFileMetadata) containsKey(key string) bool {
+	return key >= meta.smallestKey && key <= meta.largestKey
+}
+
+func (meta *SSTableFileMetadata) toPb() *pb.SSTableFileMeta {
+	return &pb.SSTableFileMeta{
+		Number:       meta.number,
+		Filename:     meta.filename,
+		Level:        uint32(meta.level),
+		SmallestKey:  meta.smallestKey,
+		LargestKey:   meta.largestKey,
+		Size:         meta.size,
+		CreationTime: meta.creationTime.UnixNano(),
+	}
+}
+
+func sstableFileMetadataFromPb(file *pb.SSTableFileMeta) *SSTableFileMetadata {
+	return &SSTableFileMetadata{
+		number:       file.Number,
+		filename:     file.Filename,
+		level:        int(file.Level),
+		smallestKey:  file.SmallestKey,
+		largestKey:   file.LargestKey,
+		size:         file.Size,
+		creationTime: time.Unix(0, file.CreationTime),
+	}
+}
+
+func settingToPb(setting *DBSetting) *pb.DBSetting {
+	return &pb.DBSetting{
+		FormatVersion:            manifestFormatVersion,
+		WalStrictModeOn:          setting.WalStrictModeOn,
+		MemtableSizeByte:         uint64(setting.MemtableSizeByte),
+		SstableDatablockSizeByte: uint64(setting.SStableDatablockSizeByte),
+		CompactionStrategy:       setting.CompactionStrategy.Name(),
+		WalDir:                   setting.WalDir,
+	}
+}
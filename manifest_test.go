@@ -0,0 +1,152 @@
+package dbengine
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DrakeW/go-db-engine/pb"
+)
+
+func getTestManifest(t *testing.T) (*manifest, string, string) {
+	t.Helper()
+
+	dbDir := setupTestDBDir(t)
+	sstableDir := filepath.Join(dbDir, "sstable")
+	if err := os.Mkdir(sstableDir, 0700); err != nil {
+		panic(err)
+	}
+
+	m, err := openManifest(dbDir, sstableDir, defaultDBSetting())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, dbDir, sstableDir
+}
+
+func addTestSSTableFile(t *testing.T, m *manifest, sstableDir, filename string, level uint32) {
+	t.Helper()
+
+	ioutil.WriteFile(filepath.Join(sstableDir, filename), []byte("content"), 0644)
+	err := m.logAndApply(&pb.VersionEdit{
+		AddedFiles: []*pb.SSTableFileMeta{
+			{Number: m.newFileNumber(), Filename: filename, Level: level, SmallestKey: "a", LargestKey: "z", Size: 7},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_ManifestShouldOrderLevel0FilesFromLatestToEarliest(t *testing.T) {
+	m, _, sstableDir := getTestManifest(t)
+
+	addTestSSTableFile(t, m, sstableDir, "sstable_1", 0)
+	addTestSSTableFile(t, m, sstableDir, "sstable_2", 0)
+
+	files := m.currentVersion().files()
+	if len(files) != 2 || files[0].filename != "sstable_2" || files[1].filename != "sstable_1" {
+		t.Errorf("Files are not ordered from latest to earliest - got %v", files)
+	}
+}
+
+func Test_ManifestShouldRestoreLiveFilesOnReopen(t *testing.T) {
+	m, dbDir, sstableDir := getTestManifest(t)
+
+	addTestSSTableFile(t, m, sstableDir, "sstable_1", 0)
+	addTestSSTableFile(t, m, sstableDir, "sstable_2", 0)
+	m.logAndApply(&pb.VersionEdit{
+		DeletedFiles: []*pb.SSTableFileMeta{m.currentVersion().files()[1].toPb()},
+	})
+
+	reopened, err := openManifest(dbDir, sstableDir, defaultDBSetting())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := reopened.currentVersion().files()
+	if len(files) != 1 || files[0].filename != "sstable_2" {
+		t.Errorf("Live files are not restored correctly - got %v", files)
+	}
+	if reopened.newFileNumber() <= files[0].number {
+		t.Error("File numbers should not be reused after reopen")
+	}
+}
+
+func Test_ManifestShouldRemoveSSTableFilesNotRecorded(t *testing.T) {
+	m, dbDir, sstableDir := getTestManifest(t)
+
+	addTestSSTableFile(t, m, sstableDir, "sstable_1", 0)
+	// an sstable file that was written but never recorded
+	ioutil.WriteFile(filepath.Join(sstableDir, "sstable_2"), []byte("content"), 0644)
+
+	if _, err := openManifest(dbDir, sstableDir, defaultDBSetting()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(sstableDir, "sstable_1")); err != nil {
+		t.Errorf("Recorded sstable file should be kept - Error: %s", err.Error())
+	}
+	if _, err := os.Stat(filepath.Join(sstableDir, "sstable_2")); !os.IsNotExist(err) {
+		t.Error("sstable file not recorded in the manifest should be removed")
+	}
+}
+
+func Test_ManifestShouldRefuseDamagedCurrentFile(t *testing.T) {
+	m, dbDir, sstableDir := getTestManifest(t)
+	addTestSSTableFile(t, m, sstableDir, "sstable_1", 0)
+	m.close()
+
+	for _, content := range []string{"", "\n", "garbage"} {
+		ioutil.WriteFile(filepath.Join(dbDir, currentFileName), []byte(content), 0644)
+
+		if _, err := openManifest(dbDir, sstableDir, defaultDBSetting()); !errors.Is(err, ErrCorruption) {
+			t.Errorf("Expected CURRENT file %q to be reported as corrupted - Error: %v", content, err)
+		}
+		if _, err := os.Stat(filepath.Join(sstableDir, "sstable_1")); err != nil {
+			t.Errorf("sstable file should be kept when CURRENT file is %q - Error: %s", content, err.Error())
+		}
+	}
+}
+
+func Test_ManifestShouldRefuseNewerFormatVersion(t *testing.T) {
+	m, dbDir, sstableDir := getTestManifest(t)
+
+	setting := settingToPb(defaultDBSetting())
+	setting.FormatVersion = manifestFormatVersion + 1
+	m.logAndApply(&pb.VersionEdit{Setting: setting})
+
+	_, err := openManifest(dbDir, sstableDir, defaultDBSetting())
+
+	var mErr *ManifestError
+	if !errors.As(err, &mErr) || mErr.Op != OP_MANIFEST_CHECK_SETTING {
+		t.Errorf("Unexpected error returned - Error: %v", err)
+	}
+}
+
+func Test_ManifestShouldRefuseDifferentCompactionStrategy(t *testing.T) {
+	_, dbDir, sstableDir := getTestManifest(t)
+
+	setting := defaultDBSetting()
+	setting.CompactionStrategy = NewFIFOCompactionStrategy(0, 0)
+	_, err := openManifest(dbDir, sstableDir, setting)
+
+	var mErr *ManifestError
+	if !errors.As(err, &mErr) || mErr.Op != OP_MANIFEST_CHECK_SETTING {
+		t.Errorf("Unexpected error returned - Error: %v", err)
+	}
+}
+
+func Test_ManifestShouldAllowTuningSetting(t *testing.T) {
+	_, dbDir, sstableDir := getTestManifest(t)
+
+	setting := defaultDBSetting()
+	setting.MemtableSizeByte *= 2
+	setting.SStableDatablockSizeByte *= 2
+	setting.WalStrictModeOn = true
+	if _, err := openManifest(dbDir, sstableDir, setting); err != nil {
+		t.Errorf("Expected tuned setting to be accepted - Error: %s", err.Error())
+	}
+}
@@ -1,6 +1,8 @@
 package dbengine
 
 import (
+	"sync"
+
 	"github.com/DrakeW/go-db-engine/pb"
 	"google.golang.org/protobuf/proto"
 )
@@ -8,22 +10,36 @@ import (
 // MemTable - A memtable handles the in-memory operatoins of the DB on data that
 // has not been persisted into the file system
 type MemTable interface {
-	// Get - retrieves the value saved with key
-	Get(key string) []byte
+	// Get - retrieves the latest record of key written at or before sequence number seq, nil if there is
+	// none. A deleted key returns a tombstone record
+	Get(key string, seq uint64) *MemtableRecord
 
-	// GetRange - retrieves all values from specified key range
+	// GetRange - retrieves the latest values from specified key range [start, end), deleted keys are skipped
 	GetRange(start, end string) [][]byte
 
-	// Write - write key with value into memtable
-	Write(key string, value []byte) error
+	// NewIterator - returns an iterator that goes through every version of the records in the memtable in
+	// key order, versions of the same key are ordered from the latest to the earliest
+	NewIterator() RecordIterator
+
+	// Write - write key with value into memtable as the version of sequence number seq, opts tells how the
+	// write is recorded in the WAL
+	Write(key string, value []byte, seq uint64, opts *WriteOptions) error
+
+	// Delete - delete a record with key as the version of sequence number seq, opts tells how the delete is
+	// recorded in the WAL
+	Delete(key string, seq uint64, opts *WriteOptions) error
 
-	// Delete - delete a record with key
-	Delete(key string) error
+	// ApplyBatch - applies all the operations of the batch atomically, operations get consecutive sequence
+	// numbers starting from firstSeq. opts tells how the batch is recorded in the WAL
+	ApplyBatch(batch *WriteBatch, firstSeq uint64, opts *WriteOptions) error
 
 	// Wal - returns the write-ahead-log instance for write ops recording
 	Wal() Wal
 
-	// GetAll - returns all records stored in the memtable
+	// WalRange - returns the part of the write-ahead-log holding the writes of the memtable
+	WalRange() WalRange
+
+	// GetAll - returns every version of all records stored in the memtable, in the same order as `NewIterator`
 	GetAll() []*MemtableRecord
 
 	// SizeBytes - returns the total size of data stored in this memtable
@@ -32,19 +48,34 @@ type MemTable interface {
 
 // MemtableRecord - represents a single inserted record
 type MemtableRecord struct {
-	Key   string
-	Value []byte
+	Key       string
+	Value     []byte
+	Tombstone bool   // Tombstone - the key is deleted, Value is always empty
+	Seq       uint64 // Seq - sequence number of the write that created the record
+}
+
+// WalRange - the part of the write-ahead-log holding the writes of a memtable
+type WalRange struct {
+	// Segment - number of the first `SegmentedWal` segment holding the writes, 0 if the memtable has a WAL file
+	// of its own
+	Segment  uint64
+	FirstSeq uint64 // FirstSeq - sequence number of the earliest write, 0 if there is none
+	LastSeq  uint64 // LastSeq - sequence number of the latest write, 0 if there is none
+	// Unlogged - true if some writes skipped the WAL (see `WriteOptions.DisableWAL`), they can't be recovered
+	Unlogged bool
 }
 
-// SkipListMemTable - A memtable implementation using the skip list data structure
+// SkipListMemTable - A memtable implementation using the skip list data structure. It's safe for concurrent
+// use, reads can run in parallel with each other while writes are applied one at a time.
 type SkipListMemTable struct {
+	lock           sync.RWMutex // lock - guards the skip list, the size and the WAL range
 	s              *skipList
 	wal            Wal
+	walRange       WalRange
 	TotalSizeBytes uint32 // total size of key, value data stored
 }
 
 // NewBasicMemTable - create a new memtable instance
-// TODO: (p3) make the memtable implementaion thread-safe
 func NewBasicMemTable(walDir string, walStrictModeOn bool) MemTable {
 	wal, err := NewBasicWal(walDir, walStrictModeOn)
 	if err != nil {
@@ -58,37 +89,166 @@ func NewBasicMemTable(walDir string, walStrictModeOn bool) MemTable {
 	}
 }
 
-// Get - retrieves the value saved with key
-func (m *SkipListMemTable) Get(key string) []byte {
-	node := m.s.search(key)
+// newSegmentedMemTable - create a new memtable instance whose writes are recorded in the shared WAL, starting
+// from the segment with number segment
+func newSegmentedMemTable(wal *SegmentedWal, segment uint64) *SkipListMemTable {
+	return &SkipListMemTable{
+		s:        newSkipList(),
+		wal:      wal,
+		walRange: WalRange{Segment: segment},
+	}
+}
+
+// NewBasicMemTableFromWal - rebuilds a memtable from the records of an existing WAL, the WAL stays
+// attached to the memtable so it can be deleted once the memtable is persisted. Damaged logs are handled
+// according to mode, the report tells which ones were dropped. Records logged before sequence numbers existed
+// are given the sequence numbers after lastSeq, in the order they were logged.
+func NewBasicMemTableFromWal(wal Wal, mode WalRecoveryMode, lastSeq uint64) (MemTable, *WalRecoveryReport, error) {
+	m := &SkipListMemTable{
+		s:              newSkipList(),
+		wal:            wal,
+		TotalSizeBytes: 0,
+	}
+
+	report, err := wal.Recover(mode, func(data []byte) error {
+		return m.applyWalLog(data, 0, &lastSeq)
+	})
+	return m, report, err
+}
+
+// applyWalLog - applies the records of a WAL log whose sequence number is greater than persistedSeq, the ones
+// at or before it are persisted elsewhere already. A record logged without a sequence number is given the one
+// after lastSeq, which is advanced past every record applied. lock must be held unless the memtable isn't
+// shared yet
+func (m *SkipListMemTable) applyWalLog(data []byte, persistedSeq uint64, lastSeq *uint64) error {
+	batch := &pb.MemtableWriteBatch{}
+	if err := proto.Unmarshal(data, batch); err != nil {
+		return err
+	}
+	// a batch is never empty, so no record means it's a single record logged before batches existed
+	if len(batch.Records) == 0 {
+		record := &pb.MemtableKeyValue{}
+		if err := proto.Unmarshal(data, record); err != nil {
+			return err
+		}
+		batch.Records = append(batch.Records, record)
+	}
+
+	for _, record := range batch.Records {
+		seq := record.Seq
+		if seq == 0 {
+			seq = *lastSeq + 1
+		} else if seq <= persistedSeq {
+			continue
+		}
+		m.apply(record.Key, record.Value, record.Type, seq)
+		if seq > *lastSeq {
+			*lastSeq = seq
+		}
+	}
+	return nil
+}
+
+// Get - retrieves the latest record of key written at or before sequence number seq, nil if there is
+// none. A deleted key returns a tombstone record
+func (m *SkipListMemTable) Get(key string, seq uint64) *MemtableRecord {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	node := m.s.search(key, seq)
 	if node != nil {
-		return node.value
+		return nodeToRecord(node)
 	}
 	return nil
 }
 
-// Write - write key with value into memtable
-func (m *SkipListMemTable) Write(key string, value []byte) error {
-	walLog, err := m.keyValueToWalLogBytes(key, value)
-	if err != nil {
-		return err
+// nodeToRecord - converts a skip list node into a memtable record
+func nodeToRecord(node *node) *MemtableRecord {
+	return &MemtableRecord{
+		Key:       node.key,
+		Value:     node.value,
+		Tombstone: node.tombstone,
+		Seq:       node.seq,
 	}
+}
 
-	if err = m.wal.Append(walLog); err != nil {
-		return err
+// Write - write key with value into memtable as the version of sequence number seq, opts tells how the write
+// is recorded in the WAL
+func (m *SkipListMemTable) Write(key string, value []byte, seq uint64, opts *WriteOptions) error {
+	batch := NewWriteBatch()
+	batch.Put(key, value)
+	return m.ApplyBatch(batch, seq, opts)
+}
+
+// ApplyBatch - records all the operations of the batch in the WAL as a single log first, then applies them
+// to the skip list. Operations get consecutive sequence numbers starting from firstSeq. The WAL is synced
+// afterwards if `WriteOptions.Sync` is set, or skipped altogether if `WriteOptions.DisableWAL` is set
+func (m *SkipListMemTable) ApplyBatch(batch *WriteBatch, firstSeq uint64, opts *WriteOptions) error {
+	if batch.Len() == 0 {
+		return nil
 	}
-	m.s.upsert(key, value)
+
+	options := writeOptionsOf(opts)
+	if !options.DisableWAL {
+		walLog, err := m.batchToWalLogBytes(batch, firstSeq)
+		if err != nil {
+			return err
+		}
+		if err = m.wal.Append(walLog); err != nil {
+			return err
+		}
+		if options.Sync {
+			if err := m.wal.Sync(); err != nil {
+				return err
+			}
+		}
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for i, record := range batch.records {
+		m.apply(record.Key, record.Value, recordTypeOf(record), firstSeq+uint64(i))
+	}
+	m.walRange.Unlogged = m.walRange.Unlogged || options.DisableWAL
+	return nil
+}
+
+// recordTypeOf - returns the type a record is stored as
+func recordTypeOf(record *MemtableRecord) pb.RecordType {
+	if record.Tombstone {
+		return pb.RecordType_TOMBSTONE
+	}
+	return pb.RecordType_VALUE
+}
+
+// apply - applies a write or delete operation to the skip list and keep track of the size of data. lock must
+// be held unless the memtable isn't shared yet
+func (m *SkipListMemTable) apply(key string, value []byte, recordType pb.RecordType, seq uint64) {
+	node := m.s.upsert(key, seq, value)
+	node.tombstone = recordType == pb.RecordType_TOMBSTONE
 
 	sizeWritten := len(key) + len(value)
 	m.TotalSizeBytes += uint32(sizeWritten)
-	return nil
+	if m.walRange.FirstSeq == 0 || seq < m.walRange.FirstSeq {
+		m.walRange.FirstSeq = seq
+	}
+	if seq > m.walRange.LastSeq {
+		m.walRange.LastSeq = seq
+	}
 }
 
-// keyValueToWalLogBytes - converts a key value pair into raw bytes for WAL insertion
-func (m *SkipListMemTable) keyValueToWalLogBytes(key string, value []byte) ([]byte, error) {
-	log := &pb.MemtableKeyValue{
-		Key:   key,
-		Value: value,
+// batchToWalLogBytes - converts a write batch into raw bytes for WAL insertion
+func (m *SkipListMemTable) batchToWalLogBytes(batch *WriteBatch, firstSeq uint64) ([]byte, error) {
+	log := &pb.MemtableWriteBatch{
+		Records: make([]*pb.MemtableKeyValue, batch.Len()),
+	}
+	for i, record := range batch.records {
+		log.Records[i] = &pb.MemtableKeyValue{
+			Key:   record.Key,
+			Value: record.Value,
+			Type:  recordTypeOf(record),
+			Seq:   firstSeq + uint64(i),
+		}
 	}
 	raw, err := proto.Marshal(log)
 	if err != nil {
@@ -99,6 +259,9 @@ func (m *SkipListMemTable) keyValueToWalLogBytes(key string, value []byte) ([]by
 
 // SizeBytes - returns the total size of data stored in this memtable
 func (m *SkipListMemTable) SizeBytes() uint32 {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
 	return m.TotalSizeBytes
 }
 
@@ -107,38 +270,121 @@ func (m *SkipListMemTable) Wal() Wal {
 	return m.wal
 }
 
-// Delete - delete a record with key
-func (m *SkipListMemTable) Delete(key string) error {
-	// upon deletion, insert a tombstone record instead of performing actual deletion
-	// TODO: (P3) figure out a way so that tombstone record doesn't conincide with custom value
-	tombstoneVal := []byte("tombstone")
-	walLog, err := m.keyValueToWalLogBytes(key, tombstoneVal)
-	if err != nil {
-		return err
-	}
+// WalRange - returns the part of the write-ahead-log holding the writes of the memtable
+func (m *SkipListMemTable) WalRange() WalRange {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
 
-	if err = m.wal.Append(walLog); err != nil {
-		return err
-	}
-	m.s.upsert(key, tombstoneVal)
-	return nil
+	return m.walRange
+}
+
+// Delete - delete a record with key as the version of sequence number seq, opts tells how the delete is recorded
+// in the WAL
+func (m *SkipListMemTable) Delete(key string, seq uint64, opts *WriteOptions) error {
+	// upon deletion, insert a tombstone record instead of performing actual deletion so the deletion
+	// shadows values of the key in older memtables and sstables
+	batch := NewWriteBatch()
+	batch.Delete(key)
+	return m.ApplyBatch(batch, seq, opts)
 }
 
-// GetRange - retrieves all values from specified key range
-// TODO: (p2) implement GetRange
+// GetRange - retrieves the latest values from specified key range [start, end), deleted keys are skipped
 func (m *SkipListMemTable) GetRange(start, end string) [][]byte {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	values := make([][]byte, 0)
+	var prev *node
+	for node := m.s.findGreaterOrEqual(start, maxSequence); node != nil && node.key < end; node = node.forwardNodeAtLevel[0] {
+		// only the first node of a key is the latest version
+		if (prev == nil || prev.key != node.key) && !node.tombstone {
+			values = append(values, node.value)
+		}
+		prev = node
+	}
+	return values
+}
+
+// NewIterator - returns an iterator that goes through all the records in the memtable in key order. Records
+// written after the iterator is created may or may not be seen
+func (m *SkipListMemTable) NewIterator() RecordIterator {
+	return &memtableIterator{m: m}
+}
+
+// memtableIterator - iterates through records of a memtable by walking through the skip list, the memtable
+// is locked for reading on every move so the iterator can be used while the memtable is being written
+type memtableIterator struct {
+	m    *SkipListMemTable
+	node *node
+}
+
+// First - moves to the first record
+func (it *memtableIterator) First() {
+	it.m.lock.RLock()
+	defer it.m.lock.RUnlock()
+
+	it.node = it.m.s.head.forwardNodeAtLevel[0]
+}
+
+// Last - moves to the last record
+func (it *memtableIterator) Last() {
+	it.m.lock.RLock()
+	defer it.m.lock.RUnlock()
+
+	it.node = it.m.s.findLast()
+}
+
+// Seek - moves to the latest version of the first key that is greater than or equal to key
+func (it *memtableIterator) Seek(key string) {
+	it.m.lock.RLock()
+	defer it.m.lock.RUnlock()
+
+	it.node = it.m.s.findGreaterOrEqual(key, maxSequence)
+}
+
+// Valid - returns true if the iterator is positioned at a record
+func (it *memtableIterator) Valid() bool {
+	return it.node != nil
+}
+
+// Next - moves to the next record
+func (it *memtableIterator) Next() {
+	it.m.lock.RLock()
+	defer it.m.lock.RUnlock()
+
+	it.node = it.node.forwardNodeAtLevel[0]
+}
+
+// Prev - moves to the previous record, the skip list only links forward so it's searched again
+func (it *memtableIterator) Prev() {
+	it.m.lock.RLock()
+	defer it.m.lock.RUnlock()
+
+	it.node = it.m.s.findLessThan(it.node.key, it.node.seq)
+}
+
+// Record - returns the current record
+func (it *memtableIterator) Record() *MemtableRecord {
+	it.m.lock.RLock()
+	defer it.m.lock.RUnlock()
+
+	return nodeToRecord(it.node)
+}
+
+// Error - always nil since iterating in memory doesn't fail
+func (it *memtableIterator) Error() error {
 	return nil
 }
 
-// GetAll - returns all records stored in the memtable
+// GetAll - returns every version of all records stored in the memtable, in the same order as `NewIterator`
 func (m *SkipListMemTable) GetAll() []*MemtableRecord {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
 	records := make([]*MemtableRecord, m.s.size, m.s.size)
 	i := 0
 	for node := m.s.head.forwardNodeAtLevel[0]; node != nil; node = node.forwardNodeAtLevel[0] {
-		records[i] = &MemtableRecord{
-			Key:   node.key,
-			Value: node.value,
-		}
+		records[i] = nodeToRecord(node)
 		i++
 	}
 	return records
@@ -1,11 +1,135 @@
 package dbengine
 
-import "testing"
+import (
+	"os"
+	"testing"
+
+	"github.com/DrakeW/go-db-engine/pb"
+	"google.golang.org/protobuf/proto"
+)
 
 func Test_memtableShouldWriteToWal(t *testing.T) {}
 
 func Test_memtableShouldNotUpdateSkipListIfWalWriteFailed(t *testing.T) {}
 
-func Test_memtableDeleteShouldInsertTombstoneRecord(t *testing.T) {}
+func Test_memtableDeleteShouldInsertTombstoneRecord(t *testing.T) {
+	m := NewBasicMemTable(os.TempDir(), false)
+	m.Write("key", []byte("value"), 1, nil)
+	m.Delete("key", 2, nil)
+
+	record := m.Get("key", maxSequence)
+	if record == nil || !record.Tombstone || len(record.Value) != 0 {
+		t.Errorf("Expected a tombstone record, got %v instead", record)
+	}
+}
+
+func Test_memtableShouldNotTreatTombstoneLikeValueAsDeletion(t *testing.T) {
+	m := NewBasicMemTable(os.TempDir(), false)
+	m.Write("key", []byte("tombstone"), 1, nil)
+
+	record := m.Get("key", maxSequence)
+	if record == nil || record.Tombstone || string(record.Value) != "tombstone" {
+		t.Errorf("Expected the value written, got %v instead", record)
+	}
+}
+
+func Test_memtableWriteAfterDeleteShouldRemoveTombstone(t *testing.T) {
+	m := NewBasicMemTable(os.TempDir(), false)
+	m.Delete("key", 1, nil)
+	m.Write("key", []byte("value"), 2, nil)
+
+	record := m.Get("key", maxSequence)
+	if record == nil || record.Tombstone || string(record.Value) != "value" {
+		t.Errorf("Expected the value written, got %v instead", record)
+	}
+}
+
+func Test_memtableShouldRestoreTombstonesFromWal(t *testing.T) {
+	m := NewBasicMemTable(os.TempDir(), false)
+	m.Write("key", []byte("value"), 1, nil)
+	m.Delete("key", 2, nil)
+
+	wal, _ := OpenBasicWal(m.Wal().File().Name(), false)
+	restored, _, err := NewBasicMemTableFromWal(wal, WalRecoveryAbsoluteConsistency, 0)
+	if err != nil {
+		t.Error(err)
+	}
+
+	record := restored.Get("key", maxSequence)
+	if record == nil || !record.Tombstone {
+		t.Errorf("Expected a tombstone record, got %v instead", record)
+	}
+}
 
 func Test_memtableSizeShouldKeepTrackOfDataInserted(t *testing.T) {}
+
+func Test_memtableGetRangeShouldSkipDeletedKeys(t *testing.T) {
+	m := getTestMemtable(t, 10)
+	m.Delete("key-004", 11, nil)
+
+	values := m.GetRange("key-002", "key-006")
+	if len(values) != 3 || string(values[0]) != "value-002" || string(values[2]) != "value-005" {
+		t.Errorf("Unexpected values returned - %q", values)
+	}
+}
+
+func Test_memtableIteratorShouldMoveInBothDirections(t *testing.T) {
+	m := getTestMemtable(t, 10)
+	it := m.NewIterator()
+
+	it.Seek("key-0045")
+	if !it.Valid() || it.Record().Key != "key-005" {
+		t.Fatalf("Seek should move to the next key")
+	}
+	it.Prev()
+	if !it.Valid() || it.Record().Key != "key-004" {
+		t.Errorf("Prev should move to the previous key")
+	}
+	it.Last()
+	if !it.Valid() || it.Record().Key != "key-009" {
+		t.Errorf("Last should move to the last key")
+	}
+	it.Next()
+	if it.Valid() {
+		t.Errorf("Iterator should be exhausted after the last key")
+	}
+}
+
+func Test_memtableShouldRestoreSingleRecordsLoggedBeforeBatches(t *testing.T) {
+	m := NewBasicMemTable(os.TempDir(), false)
+	raw, _ := proto.Marshal(&pb.MemtableKeyValue{Key: "key", Value: []byte("value"), Seq: 1})
+	m.Wal().Append(raw)
+
+	wal, _ := OpenBasicWal(m.Wal().File().Name(), false)
+	restored, _, err := NewBasicMemTableFromWal(wal, WalRecoveryAbsoluteConsistency, 0)
+	if err != nil {
+		t.Error(err)
+	}
+
+	record := restored.Get("key", maxSequence)
+	if record == nil || string(record.Value) != "value" || record.Seq != 1 {
+		t.Errorf("Expected the record logged, got %v instead", record)
+	}
+}
+
+func Test_memtableShouldHonourWriteOptions(t *testing.T) {
+	m := NewBasicMemTable(os.TempDir(), false)
+	defer m.Wal().Delete()
+	if err := m.Write("synced", []byte("value"), 1, &WriteOptions{Sync: true}); err != nil {
+		t.Fatalf("Failed to write with sync - Error: %s", err.Error())
+	}
+	m.Write("unlogged", []byte("value"), 2, &WriteOptions{DisableWAL: true})
+
+	if record := m.Get("unlogged", maxSequence); record == nil || string(record.Value) != "value" {
+		t.Errorf("Expected the write skipping the WAL to be readable, got %v instead", record)
+	}
+	if !m.WalRange().Unlogged {
+		t.Error("Expected the memtable to be marked as holding writes that skipped the WAL")
+	}
+
+	logs := 0
+	m.Wal().Replay(func([]byte) error { logs++; return nil })
+	if logs != 1 {
+		t.Errorf("Expected only the synced write in the WAL, got %d logs", logs)
+	}
+}
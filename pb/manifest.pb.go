@@ -0,0 +1,433 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.24.0-devel
+// 	protoc        v3.13.0
+// source: manifest.proto
+
+package pb
+
+import (
+	proto "github.com/golang/protobuf/proto"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// This is a compile-time assertion that a sufficiently up-to-date version
+// of the legacy proto package is being used.
+const _ = proto.ProtoPackageIsVersion4
+
+type VersionEdit struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Setting        *DBSetting         `protobuf:"bytes,1,opt,name=setting,proto3" json:"setting,omitempty"` // only present in the first edit of a manifest file
+	NextFileNumber uint64             `protobuf:"varint,2,opt,name=next_file_number,json=nextFileNumber,proto3" json:"next_file_number,omitempty"`
+	AddedFiles     []*SSTableFileMeta `protobuf:"bytes,3,rep,name=added_files,json=addedFiles,proto3" json:"added_files,omitempty"`
+	DeletedFiles   []*SSTableFileMeta `protobuf:"bytes,4,rep,name=deleted_files,json=deletedFiles,proto3" json:"deleted_files,omitempty"`
+	LastSequence   uint64             `protobuf:"varint,5,opt,name=last_sequence,json=lastSequence,proto3" json:"last_sequence,omitempty"` // last_sequence - largest sequence number of the writes persisted in sstable files
+}
+
+func (x *VersionEdit) Reset() {
+	*x = VersionEdit{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_manifest_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *VersionEdit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VersionEdit) ProtoMessage() {}
+
+func (x *VersionEdit) ProtoReflect() protoreflect.Message {
+	mi := &file_manifest_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VersionEdit.ProtoReflect.Descriptor instead.
+func (*VersionEdit) Descriptor() ([]byte, []int) {
+	return file_manifest_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *VersionEdit) GetSetting() *DBSetting {
+	if x != nil {
+		return x.Setting
+	}
+	return nil
+}
+
+func (x *VersionEdit) GetNextFileNumber() uint64 {
+	if x != nil {
+		return x.NextFileNumber
+	}
+	return 0
+}
+
+func (x *VersionEdit) GetAddedFiles() []*SSTableFileMeta {
+	if x != nil {
+		return x.AddedFiles
+	}
+	return nil
+}
+
+func (x *VersionEdit) GetDeletedFiles() []*SSTableFileMeta {
+	if x != nil {
+		return x.DeletedFiles
+	}
+	return nil
+}
+
+func (x *VersionEdit) GetLastSequence() uint64 {
+	if x != nil {
+		return x.LastSequence
+	}
+	return 0
+}
+
+type SSTableFileMeta struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Number       uint64 `protobuf:"varint,1,opt,name=number,proto3" json:"number,omitempty"`
+	Filename     string `protobuf:"bytes,2,opt,name=filename,proto3" json:"filename,omitempty"`
+	Level        uint32 `protobuf:"varint,3,opt,name=level,proto3" json:"level,omitempty"`
+	SmallestKey  string `protobuf:"bytes,4,opt,name=smallest_key,json=smallestKey,proto3" json:"smallest_key,omitempty"`
+	LargestKey   string `protobuf:"bytes,5,opt,name=largest_key,json=largestKey,proto3" json:"largest_key,omitempty"`
+	Size         int64  `protobuf:"varint,6,opt,name=size,proto3" json:"size,omitempty"`
+	CreationTime int64  `protobuf:"varint,7,opt,name=creation_time,json=creationTime,proto3" json:"creation_time,omitempty"` // unix timestamp in nanoseconds
+}
+
+func (x *SSTableFileMeta) Reset() {
+	*x = SSTableFileMeta{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_manifest_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SSTableFileMeta) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SSTableFileMeta) ProtoMessage() {}
+
+func (x *SSTableFileMeta) ProtoReflect() protoreflect.Message {
+	mi := &file_manifest_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SSTableFileMeta.ProtoReflect.Descriptor instead.
+func (*SSTableFileMeta) Descriptor() ([]byte, []int) {
+	return file_manifest_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SSTableFileMeta) GetNumber() uint64 {
+	if x != nil {
+		return x.Number
+	}
+	return 0
+}
+
+func (x *SSTableFileMeta) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *SSTableFileMeta) GetLevel() uint32 {
+	if x != nil {
+		return x.Level
+	}
+	return 0
+}
+
+func (x *SSTableFileMeta) GetSmallestKey() string {
+	if x != nil {
+		return x.SmallestKey
+	}
+	return ""
+}
+
+func (x *SSTableFileMeta) GetLargestKey() string {
+	if x != nil {
+		return x.LargestKey
+	}
+	return ""
+}
+
+func (x *SSTableFileMeta) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *SSTableFileMeta) GetCreationTime() int64 {
+	if x != nil {
+		return x.CreationTime
+	}
+	return 0
+}
+
+type DBSetting struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	FormatVersion            uint32 `protobuf:"varint,1,opt,name=format_version,json=formatVersion,proto3" json:"format_version,omitempty"`
+	WalStrictModeOn          bool   `protobuf:"varint,2,opt,name=wal_strict_mode_on,json=walStrictModeOn,proto3" json:"wal_strict_mode_on,omitempty"`
+	MemtableSizeByte         uint64 `protobuf:"varint,3,opt,name=memtable_size_byte,json=memtableSizeByte,proto3" json:"memtable_size_byte,omitempty"`
+	SstableDatablockSizeByte uint64 `protobuf:"varint,4,opt,name=sstable_datablock_size_byte,json=sstableDatablockSizeByte,proto3" json:"sstable_datablock_size_byte,omitempty"`
+	CompactionStrategy       string `protobuf:"bytes,5,opt,name=compaction_strategy,json=compactionStrategy,proto3" json:"compaction_strategy,omitempty"`
+	WalDir                   string `protobuf:"bytes,6,opt,name=wal_dir,json=walDir,proto3" json:"wal_dir,omitempty"` // wal_dir - directory of the WAL segments, the "wal" directory under the DB directory if empty
+}
+
+func (x *DBSetting) Reset() {
+	*x = DBSetting{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_manifest_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DBSetting) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DBSetting) ProtoMessage() {}
+
+func (x *DBSetting) ProtoReflect() protoreflect.Message {
+	mi := &file_manifest_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DBSetting.ProtoReflect.Descriptor instead.
+func (*DBSetting) Descriptor() ([]byte, []int) {
+	return file_manifest_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *DBSetting) GetFormatVersion() uint32 {
+	if x != nil {
+		return x.FormatVersion
+	}
+	return 0
+}
+
+func (x *DBSetting) GetWalStrictModeOn() bool {
+	if x != nil {
+		return x.WalStrictModeOn
+	}
+	return false
+}
+
+func (x *DBSetting) GetMemtableSizeByte() uint64 {
+	if x != nil {
+		return x.MemtableSizeByte
+	}
+	return 0
+}
+
+func (x *DBSetting) GetSstableDatablockSizeByte() uint64 {
+	if x != nil {
+		return x.SstableDatablockSizeByte
+	}
+	return 0
+}
+
+func (x *DBSetting) GetCompactionStrategy() string {
+	if x != nil {
+		return x.CompactionStrategy
+	}
+	return ""
+}
+
+func (x *DBSetting) GetWalDir() string {
+	if x != nil {
+		return x.WalDir
+	}
+	return ""
+}
+
+var File_manifest_proto protoreflect.FileDescriptor
+
+var file_manifest_proto_rawDesc = []byte{
+	0x0a, 0x0e, 0x6d, 0x61, 0x6e, 0x69, 0x66, 0x65, 0x73, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x22, 0xec, 0x01, 0x0a, 0x0b, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x45, 0x64, 0x69, 0x74,
+	0x12, 0x24, 0x0a, 0x07, 0x73, 0x65, 0x74, 0x74, 0x69, 0x6e, 0x67, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x0a, 0x2e, 0x44, 0x42, 0x53, 0x65, 0x74, 0x74, 0x69, 0x6e, 0x67, 0x52, 0x07, 0x73,
+	0x65, 0x74, 0x74, 0x69, 0x6e, 0x67, 0x12, 0x28, 0x0a, 0x10, 0x6e, 0x65, 0x78, 0x74, 0x5f, 0x66,
+	0x69, 0x6c, 0x65, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x0e, 0x6e, 0x65, 0x78, 0x74, 0x46, 0x69, 0x6c, 0x65, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72,
+	0x12, 0x31, 0x0a, 0x0b, 0x61, 0x64, 0x64, 0x65, 0x64, 0x5f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x18,
+	0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x53, 0x53, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x46,
+	0x69, 0x6c, 0x65, 0x4d, 0x65, 0x74, 0x61, 0x52, 0x0a, 0x61, 0x64, 0x64, 0x65, 0x64, 0x46, 0x69,
+	0x6c, 0x65, 0x73, 0x12, 0x35, 0x0a, 0x0d, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x5f, 0x66,
+	0x69, 0x6c, 0x65, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x53, 0x53, 0x54,
+	0x61, 0x62, 0x6c, 0x65, 0x46, 0x69, 0x6c, 0x65, 0x4d, 0x65, 0x74, 0x61, 0x52, 0x0c, 0x64, 0x65,
+	0x6c, 0x65, 0x74, 0x65, 0x64, 0x46, 0x69, 0x6c, 0x65, 0x73, 0x12, 0x23, 0x0a, 0x0d, 0x6c, 0x61,
+	0x73, 0x74, 0x5f, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x0c, 0x6c, 0x61, 0x73, 0x74, 0x53, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x22,
+	0xd8, 0x01, 0x0a, 0x0f, 0x53, 0x53, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x46, 0x69, 0x6c, 0x65, 0x4d,
+	0x65, 0x74, 0x61, 0x12, 0x16, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x04, 0x52, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x1a, 0x0a, 0x08, 0x66,
+	0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x66,
+	0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x65, 0x76, 0x65, 0x6c,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x6c, 0x65, 0x76, 0x65, 0x6c, 0x12, 0x21, 0x0a,
+	0x0c, 0x73, 0x6d, 0x61, 0x6c, 0x6c, 0x65, 0x73, 0x74, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0b, 0x73, 0x6d, 0x61, 0x6c, 0x6c, 0x65, 0x73, 0x74, 0x4b, 0x65, 0x79,
+	0x12, 0x1f, 0x0a, 0x0b, 0x6c, 0x61, 0x72, 0x67, 0x65, 0x73, 0x74, 0x5f, 0x6b, 0x65, 0x79, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x6c, 0x61, 0x72, 0x67, 0x65, 0x73, 0x74, 0x4b, 0x65,
+	0x79, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x04, 0x73, 0x69, 0x7a, 0x65, 0x12, 0x23, 0x0a, 0x0d, 0x63, 0x72, 0x65, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0c, 0x63, 0x72,
+	0x65, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x54, 0x69, 0x6d, 0x65, 0x22, 0x96, 0x02, 0x0a, 0x09, 0x44,
+	0x42, 0x53, 0x65, 0x74, 0x74, 0x69, 0x6e, 0x67, 0x12, 0x25, 0x0a, 0x0e, 0x66, 0x6f, 0x72, 0x6d,
+	0x61, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x0d, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12,
+	0x2b, 0x0a, 0x12, 0x77, 0x61, 0x6c, 0x5f, 0x73, 0x74, 0x72, 0x69, 0x63, 0x74, 0x5f, 0x6d, 0x6f,
+	0x64, 0x65, 0x5f, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0f, 0x77, 0x61, 0x6c,
+	0x53, 0x74, 0x72, 0x69, 0x63, 0x74, 0x4d, 0x6f, 0x64, 0x65, 0x4f, 0x6e, 0x12, 0x2c, 0x0a, 0x12,
+	0x6d, 0x65, 0x6d, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x5f, 0x62, 0x79,
+	0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x10, 0x6d, 0x65, 0x6d, 0x74, 0x61, 0x62,
+	0x6c, 0x65, 0x53, 0x69, 0x7a, 0x65, 0x42, 0x79, 0x74, 0x65, 0x12, 0x3d, 0x0a, 0x1b, 0x73, 0x73,
+	0x74, 0x61, 0x62, 0x6c, 0x65, 0x5f, 0x64, 0x61, 0x74, 0x61, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x5f,
+	0x73, 0x69, 0x7a, 0x65, 0x5f, 0x62, 0x79, 0x74, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x18, 0x73, 0x73, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x44, 0x61, 0x74, 0x61, 0x62, 0x6c, 0x6f, 0x63,
+	0x6b, 0x53, 0x69, 0x7a, 0x65, 0x42, 0x79, 0x74, 0x65, 0x12, 0x2f, 0x0a, 0x13, 0x63, 0x6f, 0x6d,
+	0x70, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x73, 0x74, 0x72, 0x61, 0x74, 0x65, 0x67, 0x79,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x12, 0x63, 0x6f, 0x6d, 0x70, 0x61, 0x63, 0x74, 0x69,
+	0x6f, 0x6e, 0x53, 0x74, 0x72, 0x61, 0x74, 0x65, 0x67, 0x79, 0x12, 0x17, 0x0a, 0x07, 0x77, 0x61,
+	0x6c, 0x5f, 0x64, 0x69, 0x72, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x77, 0x61, 0x6c,
+	0x44, 0x69, 0x72, 0x42, 0x04, 0x5a, 0x02, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x33,
+}
+
+var (
+	file_manifest_proto_rawDescOnce sync.Once
+	file_manifest_proto_rawDescData = file_manifest_proto_rawDesc
+)
+
+func file_manifest_proto_rawDescGZIP() []byte {
+	file_manifest_proto_rawDescOnce.Do(func() {
+		file_manifest_proto_rawDescData = protoimpl.X.CompressGZIP(file_manifest_proto_rawDescData)
+	})
+	return file_manifest_proto_rawDescData
+}
+
+var file_manifest_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_manifest_proto_goTypes = []interface{}{
+	(*VersionEdit)(nil),     // 0: VersionEdit
+	(*SSTableFileMeta)(nil), // 1: SSTableFileMeta
+	(*DBSetting)(nil),       // 2: DBSetting
+}
+var file_manifest_proto_depIdxs = []int32{
+	2, // 0: VersionEdit.setting:type_name -> DBSetting
+	1, // 1: VersionEdit.added_files:type_name -> SSTableFileMeta
+	1, // 2: VersionEdit.deleted_files:type_name -> SSTableFileMeta
+	3, // [3:3] is the sub-list for method output_type
+	3, // [3:3] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_manifest_proto_init() }
+func file_manifest_proto_init() {
+	if File_manifest_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_manifest_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*VersionEdit); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_manifest_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SSTableFileMeta); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_manifest_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DBSetting); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_manifest_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_manifest_proto_goTypes,
+		DependencyIndexes: file_manifest_proto_depIdxs,
+		MessageInfos:      file_manifest_proto_msgTypes,
+	}.Build()
+	File_manifest_proto = out.File
+	file_manifest_proto_rawDesc = nil
+	file_manifest_proto_goTypes = nil
+	file_manifest_proto_depIdxs = nil
+}
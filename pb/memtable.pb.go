@@ -30,8 +30,10 @@ type MemtableKeyValue struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Key   string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
-	Value []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	Key   string     `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value []byte     `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	Type  RecordType `protobuf:"varint,3,opt,name=type,proto3,enum=RecordType" json:"type,omitempty"`
+	Seq   uint64     `protobuf:"varint,4,opt,name=seq,proto3" json:"seq,omitempty"` // seq - sequence number of the write, unique across the database
 }
 
 func (x *MemtableKeyValue) Reset() {
@@ -80,15 +82,87 @@ func (x *MemtableKeyValue) GetValue() []byte {
 	return nil
 }
 
+func (x *MemtableKeyValue) GetType() RecordType {
+	if x != nil {
+		return x.Type
+	}
+	return RecordType_VALUE
+}
+
+func (x *MemtableKeyValue) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+// MemtableWriteBatch - writes that are recorded in the WAL as a single log so that they are applied
+// all-or-nothing. Records use field number 5 so that a log written before batches existed (a single
+// MemtableKeyValue) can be told apart from a batch
+type MemtableWriteBatch struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Records []*MemtableKeyValue `protobuf:"bytes,5,rep,name=records,proto3" json:"records,omitempty"`
+}
+
+func (x *MemtableWriteBatch) Reset() {
+	*x = MemtableWriteBatch{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_memtable_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MemtableWriteBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MemtableWriteBatch) ProtoMessage() {}
+
+func (x *MemtableWriteBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_memtable_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MemtableWriteBatch.ProtoReflect.Descriptor instead.
+func (*MemtableWriteBatch) Descriptor() ([]byte, []int) {
+	return file_memtable_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *MemtableWriteBatch) GetRecords() []*MemtableKeyValue {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
 var File_memtable_proto protoreflect.FileDescriptor
 
 var file_memtable_proto_rawDesc = []byte{
 	0x0a, 0x0e, 0x6d, 0x65, 0x6d, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x22, 0x3a, 0x0a, 0x10, 0x4d, 0x65, 0x6d, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x4b, 0x65, 0x79, 0x56,
-	0x61, 0x6c, 0x75, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18,
-	0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x42, 0x04, 0x5a, 0x02,
-	0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x1a, 0x0c, 0x72, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x6d,
+	0x0a, 0x10, 0x4d, 0x65, 0x6d, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x4b, 0x65, 0x79, 0x56, 0x61, 0x6c,
+	0x75, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x1f, 0x0a, 0x04, 0x74, 0x79,
+	0x70, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0b, 0x2e, 0x52, 0x65, 0x63, 0x6f, 0x72,
+	0x64, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x73,
+	0x65, 0x71, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x73, 0x65, 0x71, 0x22, 0x41, 0x0a,
+	0x12, 0x4d, 0x65, 0x6d, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x57, 0x72, 0x69, 0x74, 0x65, 0x42, 0x61,
+	0x74, 0x63, 0x68, 0x12, 0x2b, 0x0a, 0x07, 0x72, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x73, 0x18, 0x05,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x4d, 0x65, 0x6d, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x4b,
+	0x65, 0x79, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x52, 0x07, 0x72, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x73,
+	0x42, 0x04, 0x5a, 0x02, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -103,16 +177,20 @@ func file_memtable_proto_rawDescGZIP() []byte {
 	return file_memtable_proto_rawDescData
 }
 
-var file_memtable_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_memtable_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
 var file_memtable_proto_goTypes = []interface{}{
-	(*MemtableKeyValue)(nil), // 0: MemtableKeyValue
+	(*MemtableKeyValue)(nil),   // 0: MemtableKeyValue
+	(*MemtableWriteBatch)(nil), // 1: MemtableWriteBatch
+	(RecordType)(0),            // 2: RecordType
 }
 var file_memtable_proto_depIdxs = []int32{
-	0, // [0:0] is the sub-list for method output_type
-	0, // [0:0] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	2, // 0: MemtableKeyValue.type:type_name -> RecordType
+	0, // 1: MemtableWriteBatch.records:type_name -> MemtableKeyValue
+	2, // [2:2] is the sub-list for method output_type
+	2, // [2:2] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
 }
 
 func init() { file_memtable_proto_init() }
@@ -120,6 +198,7 @@ func file_memtable_proto_init() {
 	if File_memtable_proto != nil {
 		return
 	}
+	file_record_proto_init()
 	if !protoimpl.UnsafeEnabled {
 		file_memtable_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*MemtableKeyValue); i {
@@ -133,6 +212,18 @@ func file_memtable_proto_init() {
 				return nil
 			}
 		}
+		file_memtable_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MemtableWriteBatch); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -140,7 +231,7 @@ func file_memtable_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_memtable_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   1,
+			NumMessages:   2,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -77,8 +77,10 @@ type SSTableKeyValue struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Key   string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
-	Value []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	Key   string     `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value []byte     `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	Type  RecordType `protobuf:"varint,3,opt,name=type,proto3,enum=RecordType" json:"type,omitempty"`
+	Seq   uint64     `protobuf:"varint,4,opt,name=seq,proto3" json:"seq,omitempty"`
 }
 
 func (x *SSTableKeyValue) Reset() {
@@ -127,12 +129,33 @@ func (x *SSTableKeyValue) GetValue() []byte {
 	return nil
 }
 
+func (x *SSTableKeyValue) GetType() RecordType {
+	if x != nil {
+		return x.Type
+	}
+	return RecordType_VALUE
+}
+
+func (x *SSTableKeyValue) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
 type SSTableIndex struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
 	Data []*SSTableIndexEntry `protobuf:"bytes,1,rep,name=data,proto3" json:"data,omitempty"`
+	// filter_offset, filter_size - location of the bloom filter block in files written in the legacy format, newer
+	// files record it in the footer. filter_offset is only valid when filter_size > 0
+	FilterOffset uint64 `protobuf:"varint,2,opt,name=filter_offset,json=filterOffset,proto3" json:"filter_offset,omitempty"`
+	FilterSize   uint64 `protobuf:"varint,3,opt,name=filter_size,json=filterSize,proto3" json:"filter_size,omitempty"`
+	// partitioned - true if the entries point to index partitions, each of them an index of data blocks, instead
+	// of data blocks
+	Partitioned bool `protobuf:"varint,4,opt,name=partitioned,proto3" json:"partitioned,omitempty"`
 }
 
 func (x *SSTableIndex) Reset() {
@@ -174,6 +197,27 @@ func (x *SSTableIndex) GetData() []*SSTableIndexEntry {
 	return nil
 }
 
+func (x *SSTableIndex) GetFilterOffset() uint64 {
+	if x != nil {
+		return x.FilterOffset
+	}
+	return 0
+}
+
+func (x *SSTableIndex) GetFilterSize() uint64 {
+	if x != nil {
+		return x.FilterSize
+	}
+	return 0
+}
+
+func (x *SSTableIndex) GetPartitioned() bool {
+	if x != nil {
+		return x.Partitioned
+	}
+	return false
+}
+
 type SSTableIndexEntry struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -245,29 +289,151 @@ func (x *SSTableIndexEntry) GetSize() uint64 {
 	return 0
 }
 
+type SSTableProperties struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	NumRecords    uint64 `protobuf:"varint,1,opt,name=num_records,json=numRecords,proto3" json:"num_records,omitempty"`
+	NumDeletions  uint64 `protobuf:"varint,2,opt,name=num_deletions,json=numDeletions,proto3" json:"num_deletions,omitempty"`
+	NumDataBlocks uint64 `protobuf:"varint,3,opt,name=num_data_blocks,json=numDataBlocks,proto3" json:"num_data_blocks,omitempty"`
+	DataSize      uint64 `protobuf:"varint,4,opt,name=data_size,json=dataSize,proto3" json:"data_size,omitempty"`
+	SmallestKey   string `protobuf:"bytes,5,opt,name=smallest_key,json=smallestKey,proto3" json:"smallest_key,omitempty"`
+	LargestKey    string `protobuf:"bytes,6,opt,name=largest_key,json=largestKey,proto3" json:"largest_key,omitempty"`
+	Compression   string `protobuf:"bytes,7,opt,name=compression,proto3" json:"compression,omitempty"` // compression - name of the compressor the file is written with
+}
+
+func (x *SSTableProperties) Reset() {
+	*x = SSTableProperties{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sstable_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SSTableProperties) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SSTableProperties) ProtoMessage() {}
+
+func (x *SSTableProperties) ProtoReflect() protoreflect.Message {
+	mi := &file_sstable_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SSTableProperties.ProtoReflect.Descriptor instead.
+func (*SSTableProperties) Descriptor() ([]byte, []int) {
+	return file_sstable_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SSTableProperties) GetNumRecords() uint64 {
+	if x != nil {
+		return x.NumRecords
+	}
+	return 0
+}
+
+func (x *SSTableProperties) GetNumDeletions() uint64 {
+	if x != nil {
+		return x.NumDeletions
+	}
+	return 0
+}
+
+func (x *SSTableProperties) GetNumDataBlocks() uint64 {
+	if x != nil {
+		return x.NumDataBlocks
+	}
+	return 0
+}
+
+func (x *SSTableProperties) GetDataSize() uint64 {
+	if x != nil {
+		return x.DataSize
+	}
+	return 0
+}
+
+func (x *SSTableProperties) GetSmallestKey() string {
+	if x != nil {
+		return x.SmallestKey
+	}
+	return ""
+}
+
+func (x *SSTableProperties) GetLargestKey() string {
+	if x != nil {
+		return x.LargestKey
+	}
+	return ""
+}
+
+func (x *SSTableProperties) GetCompression() string {
+	if x != nil {
+		return x.Compression
+	}
+	return ""
+}
+
 var File_sstable_proto protoreflect.FileDescriptor
 
 var file_sstable_proto_rawDesc = []byte{
-	0x0a, 0x0d, 0x73, 0x73, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22,
-	0x34, 0x0a, 0x0c, 0x53, 0x53, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x12,
-	0x24, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e,
-	0x53, 0x53, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x4b, 0x65, 0x79, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x52,
-	0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0x39, 0x0a, 0x0f, 0x53, 0x53, 0x54, 0x61, 0x62, 0x6c, 0x65,
-	0x4b, 0x65, 0x79, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61,
-	0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
-	0x22, 0x36, 0x0a, 0x0c, 0x53, 0x53, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x49, 0x6e, 0x64, 0x65, 0x78,
-	0x12, 0x26, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12,
-	0x2e, 0x53, 0x53, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x45, 0x6e, 0x74,
-	0x72, 0x79, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0x75, 0x0a, 0x11, 0x53, 0x53, 0x54, 0x61,
-	0x62, 0x6c, 0x65, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x1b, 0x0a,
-	0x09, 0x73, 0x74, 0x61, 0x72, 0x74, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x08, 0x73, 0x74, 0x61, 0x72, 0x74, 0x4b, 0x65, 0x79, 0x12, 0x17, 0x0a, 0x07, 0x65, 0x6e,
-	0x64, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x65, 0x6e, 0x64,
-	0x4b, 0x65, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x03, 0x20,
-	0x01, 0x28, 0x04, 0x52, 0x06, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x73,
-	0x69, 0x7a, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x42,
-	0x04, 0x5a, 0x02, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x0a, 0x0d, 0x73, 0x73, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a,
+	0x0c, 0x72, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x34, 0x0a,
+	0x0c, 0x53, 0x53, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x24, 0x0a,
+	0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x53, 0x53,
+	0x54, 0x61, 0x62, 0x6c, 0x65, 0x4b, 0x65, 0x79, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x52, 0x04, 0x64,
+	0x61, 0x74, 0x61, 0x22, 0x6c, 0x0a, 0x0f, 0x53, 0x53, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x4b, 0x65,
+	0x79, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x1f,
+	0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0b, 0x2e, 0x52,
+	0x65, 0x63, 0x6f, 0x72, 0x64, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12,
+	0x10, 0x0a, 0x03, 0x73, 0x65, 0x71, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x73, 0x65,
+	0x71, 0x22, 0x9e, 0x01, 0x0a, 0x0c, 0x53, 0x53, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x49, 0x6e, 0x64,
+	0x65, 0x78, 0x12, 0x26, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x12, 0x2e, 0x53, 0x53, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x45,
+	0x6e, 0x74, 0x72, 0x79, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x23, 0x0a, 0x0d, 0x66, 0x69,
+	0x6c, 0x74, 0x65, 0x72, 0x5f, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x0c, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x12,
+	0x1f, 0x0a, 0x0b, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x0a, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x53, 0x69, 0x7a, 0x65,
+	0x12, 0x20, 0x0a, 0x0b, 0x70, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x65, 0x64, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0b, 0x70, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e,
+	0x65, 0x64, 0x22, 0x75, 0x0a, 0x11, 0x53, 0x53, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x49, 0x6e, 0x64,
+	0x65, 0x78, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x74, 0x61, 0x72, 0x74,
+	0x5f, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x74, 0x61, 0x72,
+	0x74, 0x4b, 0x65, 0x79, 0x12, 0x17, 0x0a, 0x07, 0x65, 0x6e, 0x64, 0x5f, 0x6b, 0x65, 0x79, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x65, 0x6e, 0x64, 0x4b, 0x65, 0x79, 0x12, 0x16, 0x0a,
+	0x06, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x6f,
+	0x66, 0x66, 0x73, 0x65, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x04, 0x52, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x22, 0x84, 0x02, 0x0a, 0x11, 0x53, 0x53,
+	0x54, 0x61, 0x62, 0x6c, 0x65, 0x50, 0x72, 0x6f, 0x70, 0x65, 0x72, 0x74, 0x69, 0x65, 0x73, 0x12,
+	0x1f, 0x0a, 0x0b, 0x6e, 0x75, 0x6d, 0x5f, 0x72, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x73, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x0a, 0x6e, 0x75, 0x6d, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x73,
+	0x12, 0x23, 0x0a, 0x0d, 0x6e, 0x75, 0x6d, 0x5f, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x69, 0x6f, 0x6e,
+	0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0c, 0x6e, 0x75, 0x6d, 0x44, 0x65, 0x6c, 0x65,
+	0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x26, 0x0a, 0x0f, 0x6e, 0x75, 0x6d, 0x5f, 0x64, 0x61, 0x74,
+	0x61, 0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0d,
+	0x6e, 0x75, 0x6d, 0x44, 0x61, 0x74, 0x61, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x73, 0x12, 0x1b, 0x0a,
+	0x09, 0x64, 0x61, 0x74, 0x61, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x08, 0x64, 0x61, 0x74, 0x61, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x73, 0x6d,
+	0x61, 0x6c, 0x6c, 0x65, 0x73, 0x74, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0b, 0x73, 0x6d, 0x61, 0x6c, 0x6c, 0x65, 0x73, 0x74, 0x4b, 0x65, 0x79, 0x12, 0x1f, 0x0a,
+	0x0b, 0x6c, 0x61, 0x72, 0x67, 0x65, 0x73, 0x74, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0a, 0x6c, 0x61, 0x72, 0x67, 0x65, 0x73, 0x74, 0x4b, 0x65, 0x79, 0x12, 0x20,
+	0x0a, 0x0b, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x07, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e,
+	0x42, 0x04, 0x5a, 0x02, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -282,21 +448,24 @@ func file_sstable_proto_rawDescGZIP() []byte {
 	return file_sstable_proto_rawDescData
 }
 
-var file_sstable_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_sstable_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
 var file_sstable_proto_goTypes = []interface{}{
 	(*SSTableBlock)(nil),      // 0: SSTableBlock
 	(*SSTableKeyValue)(nil),   // 1: SSTableKeyValue
 	(*SSTableIndex)(nil),      // 2: SSTableIndex
 	(*SSTableIndexEntry)(nil), // 3: SSTableIndexEntry
+	(*SSTableProperties)(nil), // 4: SSTableProperties
+	(RecordType)(0),           // 5: RecordType
 }
 var file_sstable_proto_depIdxs = []int32{
 	1, // 0: SSTableBlock.data:type_name -> SSTableKeyValue
-	3, // 1: SSTableIndex.data:type_name -> SSTableIndexEntry
-	2, // [2:2] is the sub-list for method output_type
-	2, // [2:2] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	5, // 1: SSTableKeyValue.type:type_name -> RecordType
+	3, // 2: SSTableIndex.data:type_name -> SSTableIndexEntry
+	3, // [3:3] is the sub-list for method output_type
+	3, // [3:3] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
 }
 
 func init() { file_sstable_proto_init() }
@@ -304,6 +473,7 @@ func file_sstable_proto_init() {
 	if File_sstable_proto != nil {
 		return
 	}
+	file_record_proto_init()
 	if !protoimpl.UnsafeEnabled {
 		file_sstable_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*SSTableBlock); i {
@@ -353,6 +523,18 @@ func file_sstable_proto_init() {
 				return nil
 			}
 		}
+		file_sstable_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SSTableProperties); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -360,7 +542,7 @@ func file_sstable_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_sstable_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   4,
+			NumMessages:   5,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
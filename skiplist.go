@@ -14,14 +14,18 @@ type skipList struct {
 	sentinel *node
 }
 
+// node - a version of a key. Nodes are ordered by key, and versions of the same key are ordered from the
+// latest (largest sequence number) to the earliest
 type node struct {
 	key                string
+	seq                uint64 // sequence number of the write that created this version
 	value              []byte
+	tombstone          bool          // marks the key as deleted
 	forwardNodeAtLevel map[int]*node // tracks the next node of this node at different levels
 }
 
 func newSkipList() *skipList {
-	sentinel := newNode("", []byte{})
+	sentinel := newNode("", 0, []byte{})
 	return &skipList{
 		head:     sentinel,
 		height:   1,
@@ -31,14 +35,32 @@ func newSkipList() *skipList {
 	}
 }
 
-func newNode(key string, value []byte) *node {
+func newNode(key string, seq uint64, value []byte) *node {
 	return &node{
 		key:                key,
+		seq:                seq,
 		value:              value,
 		forwardNodeAtLevel: make(map[int]*node),
 	}
 }
 
+// compareInternalKey - compares two versions of keys, returns -1 if (key1, seq1) goes before (key2, seq2),
+// 0 if they are the same version and 1 otherwise. Keys are in increasing order, versions of the same key are
+// in decreasing sequence order so the latest version comes first.
+func compareInternalKey(key1 string, seq1 uint64, key2 string, seq2 uint64) int {
+	switch {
+	case key1 < key2:
+		return -1
+	case key1 > key2:
+		return 1
+	case seq1 > seq2:
+		return -1
+	case seq1 < seq2:
+		return 1
+	}
+	return 0
+}
+
 func (s *skipList) randomLevel() int {
 	lvl := 0
 	source := rand.NewSource(time.Now().UnixNano())
@@ -52,48 +74,69 @@ func (s *skipList) randomLevel() int {
 	return lvl
 }
 
-func (s *skipList) search(key string) *node {
-	curLevel := s.height - 1
-	curNode := s.head
-
-	// empty skip list OR direct match
-	if curNode == nil || curNode.key == key {
-		return curNode
+// search - returns the latest version of key whose sequence number is less than or equal to seq, nil if
+// there is none
+func (s *skipList) search(key string, seq uint64) *node {
+	n := s.findGreaterOrEqual(key, seq)
+	if n == nil || n.key != key {
+		return nil
 	}
+	return n
+}
 
-	for {
-		nextNode, found := curNode.forwardNodeAtLevel[curLevel]
-		if !found {
-			// if it's the last node
-			if curLevel == 0 {
-				return nil
+// lastNodeBefore - returns the last node that goes before version (key, seq), the head node if there is none
+func (s *skipList) lastNodeBefore(key string, seq uint64) *node {
+	curNode := s.head
+	for level := s.height - 1; level >= 0; level-- {
+		for {
+			nextNode, found := curNode.forwardNodeAtLevel[level]
+			if !found || compareInternalKey(nextNode.key, nextNode.seq, key, seq) >= 0 {
+				break
 			}
-			// if there's no next node, scan down
-			curLevel--
-			continue
+			curNode = nextNode
 		}
+	}
+	return curNode
+}
 
-		if nextNode.key >= key {
-			if nextNode.key == key {
-				return nextNode
-			}
-			if curLevel == 0 {
-				return nil
+// findGreaterOrEqual - returns the first node at or after version (key, seq), nil if there is none
+func (s *skipList) findGreaterOrEqual(key string, seq uint64) *node {
+	return s.lastNodeBefore(key, seq).forwardNodeAtLevel[0]
+}
+
+// findLessThan - returns the last node before version (key, seq), nil if there is none
+func (s *skipList) findLessThan(key string, seq uint64) *node {
+	n := s.lastNodeBefore(key, seq)
+	if n == s.head {
+		return nil
+	}
+	return n
+}
+
+// findLast - returns the last node, nil if the skip list is empty
+func (s *skipList) findLast() *node {
+	curNode := s.head
+	for level := s.height - 1; level >= 0; level-- {
+		for {
+			nextNode, found := curNode.forwardNodeAtLevel[level]
+			if !found {
+				break
 			}
-			// when next node's key is greater than key to search for
-			// going down one level
-			curLevel--
-		} else {
-			// scan forward
 			curNode = nextNode
 		}
 	}
+	if curNode == s.head {
+		return nil
+	}
+	return curNode
 }
 
-func (s *skipList) upsert(key string, value []byte) *node {
+// upsert - inserts version (key, seq) of the key, other versions of the key are kept. The value is updated
+// in place if the version already exists
+func (s *skipList) upsert(key string, seq uint64, value []byte) *node {
 	curNode := s.head
 	curLevel := s.height - 1
-	newNode := newNode(key, value)
+	newNode := newNode(key, seq, value)
 	// tracks the last node we search through at each level, since when we add the new node to those levels
 	// the anchor nodes will be the one that connects to it
 	updateAnchors := make([]*node, s.height, s.height)
@@ -113,9 +156,10 @@ func (s *skipList) upsert(key string, value []byte) *node {
 			continue
 		}
 
-		if nextNode.key >= key {
-			if nextNode.key == key {
+		if cmp := compareInternalKey(nextNode.key, nextNode.seq, key, seq); cmp >= 0 {
+			if cmp == 0 {
 				nextNode.value = value
+				nextNode.tombstone = false
 				return nextNode
 			}
 
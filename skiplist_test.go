@@ -10,9 +10,9 @@ import (
 
 func Test_InsertOneWhenListIsEmpty(t *testing.T) {
 	s := newSkipList()
-	s.upsert("hello", []byte("world"))
+	s.upsert("hello", 0, []byte("world"))
 
-	n := s.search("hello")
+	n := s.search("hello", maxSequence)
 	if string(n.value) != "world" {
 		t.Errorf("got %s instead", string(n.value))
 	}
@@ -23,7 +23,7 @@ func Test_InOrderInsert(t *testing.T) {
 
 	keyList := makeRange(t, 10, false)
 	for _, key := range keyList {
-		s.upsert(strconv.Itoa(key), []byte(fmt.Sprintf("hello world %s", strconv.Itoa(key))))
+		s.upsert(strconv.Itoa(key), 0, []byte(fmt.Sprintf("hello world %s", strconv.Itoa(key))))
 	}
 
 	resultKeys := getSkipListKeys(t, s)
@@ -40,7 +40,7 @@ func Test_ReverseOrderInsert(t *testing.T) {
 	keyList := makeRange(t, 10, false)
 	for idx := len(keyList) - 1; idx >= 0; idx-- {
 		key := keyList[idx]
-		s.upsert(strconv.Itoa(key), []byte(fmt.Sprintf("hello world %s", strconv.Itoa(key))))
+		s.upsert(strconv.Itoa(key), 0, []byte(fmt.Sprintf("hello world %s", strconv.Itoa(key))))
 	}
 
 	resultKeys := getSkipListKeys(t, s)
@@ -56,7 +56,7 @@ func Test_RandomInsert(t *testing.T) {
 
 	keyList := makeRange(t, 10, true)
 	for _, key := range keyList {
-		s.upsert(strconv.Itoa(key), []byte(fmt.Sprintf("hello world %s", strconv.Itoa(key))))
+		s.upsert(strconv.Itoa(key), 0, []byte(fmt.Sprintf("hello world %s", strconv.Itoa(key))))
 	}
 
 	resultKeys := getSkipListKeys(t, s)
@@ -76,12 +76,12 @@ func Test_UpdateShouldUpdateExistingElement(t *testing.T) {
 
 	keyList := makeRange(t, 10, true)
 	for _, key := range keyList {
-		s.upsert(strconv.Itoa(key), []byte(fmt.Sprintf("hello world %s", strconv.Itoa(key))))
+		s.upsert(strconv.Itoa(key), 0, []byte(fmt.Sprintf("hello world %s", strconv.Itoa(key))))
 	}
 
-	oldNode := s.search("5")
-	s.upsert("5", []byte("updated"))
-	newNode := s.search("5")
+	oldNode := s.search("5", maxSequence)
+	s.upsert("5", 0, []byte("updated"))
+	newNode := s.search("5", maxSequence)
 
 	val := string(newNode.value)
 
@@ -98,12 +98,12 @@ func Test_SearchExist(t *testing.T) {
 
 	keyList := makeRange(t, 10, true)
 	for _, key := range keyList {
-		s.upsert(strconv.Itoa(key), []byte(fmt.Sprintf("hello world %s", strconv.Itoa(key))))
+		s.upsert(strconv.Itoa(key), 0, []byte(fmt.Sprintf("hello world %s", strconv.Itoa(key))))
 	}
 
 	for _, key := range keyList {
 		expected := fmt.Sprintf("hello world %d", key)
-		val := string(s.search(strconv.Itoa(key)).value)
+		val := string(s.search(strconv.Itoa(key), maxSequence).value)
 		if val != expected {
 			t.Errorf("expected: %s, got %s instead", expected, val)
 		}
@@ -112,9 +112,9 @@ func Test_SearchExist(t *testing.T) {
 
 func Test_SearchNonExistInSingleElementList(t *testing.T) {
 	s := newSkipList()
-	s.upsert("hello", []byte("world"))
+	s.upsert("hello", 0, []byte("world"))
 
-	n := s.search("hello not exist")
+	n := s.search("hello not exist", maxSequence)
 	if n != nil {
 		t.Error("Should be nil")
 	}
@@ -125,10 +125,10 @@ func Test_SearchNonExistInMultiElementsList(t *testing.T) {
 
 	keyList := makeRange(t, 10, true)
 	for _, key := range keyList {
-		s.upsert(strconv.Itoa(key), []byte(fmt.Sprintf("hello world %s", strconv.Itoa(key))))
+		s.upsert(strconv.Itoa(key), 0, []byte(fmt.Sprintf("hello world %s", strconv.Itoa(key))))
 	}
 
-	n := s.search("hello")
+	n := s.search("hello", maxSequence)
 	if n != nil {
 		t.Error("Should be nil")
 	}
@@ -139,7 +139,7 @@ func Test_skipListShouldTrackSize(t *testing.T) {
 
 	keyList := makeRange(t, 10, true)
 	for _, key := range keyList {
-		s.upsert(strconv.Itoa(key), []byte(fmt.Sprintf("hello world %s", strconv.Itoa(key))))
+		s.upsert(strconv.Itoa(key), 0, []byte(fmt.Sprintf("hello world %s", strconv.Itoa(key))))
 	}
 
 	res := s.size
@@ -148,10 +148,60 @@ func Test_skipListShouldTrackSize(t *testing.T) {
 	}
 }
 
+func Test_FindGreaterOrEqualAndLessThan(t *testing.T) {
+	s := newSkipList()
+
+	keyList := makeRange(t, 10, true)
+	for _, key := range keyList {
+		s.upsert(strconv.Itoa(key*2), 0, []byte("hello world"))
+	}
+
+	if n := s.findGreaterOrEqual("3", maxSequence); n == nil || n.key != "4" {
+		t.Errorf("Expected node 4, got %v", n)
+	}
+	if n := s.findGreaterOrEqual("4", maxSequence); n == nil || n.key != "4" {
+		t.Errorf("Expected node 4, got %v", n)
+	}
+	if n := s.findGreaterOrEqual("9", maxSequence); n != nil {
+		t.Errorf("Expected no node, got %s", n.key)
+	}
+	if n := s.findLessThan("4", maxSequence); n == nil || n.key != "2" {
+		t.Errorf("Expected node 2, got %v", n)
+	}
+	if n := s.findLessThan("0", maxSequence); n != nil {
+		t.Errorf("Expected no node, got %s", n.key)
+	}
+	if n := s.findLast(); n == nil || n.key != "8" {
+		t.Errorf("Expected node 8, got %v", n)
+	}
+}
+
+func Test_UpsertShouldKeepEveryVersionOfKey(t *testing.T) {
+	s := newSkipList()
+	s.upsert("key", 1, []byte("v1"))
+	s.upsert("other", 2, []byte("other"))
+	s.upsert("key", 3, []byte("v3"))
+
+	if n := s.search("key", maxSequence); n == nil || string(n.value) != "v3" {
+		t.Errorf("Expected the latest version, got %v", n)
+	}
+	if n := s.search("key", 2); n == nil || string(n.value) != "v1" {
+		t.Errorf("Expected the version at sequence 1, got %v", n)
+	}
+	if n := s.search("key", 0); n != nil {
+		t.Errorf("Expected no version, got %v", n)
+	}
+
+	resultKeys := getSkipListKeys(t, s)
+	if !compareStringSlices(t, resultKeys, []string{"key", "key", "other"}) {
+		t.Errorf("got %v instead", resultKeys)
+	}
+}
+
 func Benchmark_InsertInOrder(b *testing.B) {
 	s := newSkipList()
 	for i := 0; i < b.N; i++ {
-		s.upsert(strconv.Itoa(i), []byte("hello world"))
+		s.upsert(strconv.Itoa(i), 0, []byte("hello world"))
 	}
 }
 
@@ -161,7 +211,7 @@ func Benchmark_InsertRandom(b *testing.B) {
 	keyList := makeRange(b, b.N, true)
 
 	for _, key := range keyList {
-		s.upsert(strconv.Itoa(key), []byte("hello world"))
+		s.upsert(strconv.Itoa(key), 0, []byte("hello world"))
 	}
 }
 
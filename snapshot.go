@@ -0,0 +1,100 @@
+package dbengine
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// maxSequence - sequence number that is greater than or equal to the sequence number of any write, reading
+// at maxSequence returns the latest version of a key
+const maxSequence = math.MaxUint64
+
+// Snapshot - a frozen point-in-time view of the database. Reads done with a snapshot (see `ReadOptions`) only
+// see writes made before the snapshot was taken, no matter what's written or compacted afterwards. A snapshot
+// must be released once it's not needed anymore, otherwise compaction has to keep the old versions it needs
+// forever.
+type Snapshot struct {
+	list *snapshotList
+	seq  uint64
+}
+
+// Sequence - returns the sequence number of the last write visible to the snapshot
+func (snap *Snapshot) Sequence() uint64 {
+	return snap.seq
+}
+
+// Release - releases the snapshot, it shouldn't be used for reading afterwards
+func (snap *Snapshot) Release() {
+	snap.list.remove(snap)
+}
+
+// snapshotList - keeps track of the live snapshots of a database
+type snapshotList struct {
+	lock      sync.Mutex
+	snapshots map[*Snapshot]bool
+}
+
+func newSnapshotList() *snapshotList {
+	return &snapshotList{
+		snapshots: make(map[*Snapshot]bool),
+	}
+}
+
+// add - creates a live snapshot at sequence number seq
+func (l *snapshotList) add(seq uint64) *Snapshot {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	snap := &Snapshot{list: l, seq: seq}
+	l.snapshots[snap] = true
+	return snap
+}
+
+// remove - removes the snapshot from live snapshots, it's fine to remove a snapshot more than once
+func (l *snapshotList) remove(snap *Snapshot) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	delete(l.snapshots, snap)
+}
+
+// sequences - returns the distinct sequence numbers of live snapshots in increasing order
+func (l *snapshotList) sequences() []uint64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	seen := make(map[uint64]bool)
+	seqs := make([]uint64, 0, len(l.snapshots))
+	for snap := range l.snapshots {
+		if !seen[snap.seq] {
+			seen[snap.seq] = true
+			seqs = append(seqs, snap.seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs
+}
+
+// ReadOptions - options of a read operation
+type ReadOptions struct {
+	// Snapshot - read from the snapshot instead of the latest state of the database
+	Snapshot *Snapshot
+	// VerifyChecksums - verify the data blocks read from sstable files against their checksum even if the
+	// database isn't configured to (see `ConfigVerifyChecksums`)
+	VerifyChecksums bool
+}
+
+// sequence - returns the sequence number reads should be done at, lastSeq if the read isn't from a snapshot
+func (opts *ReadOptions) sequence(lastSeq uint64) uint64 {
+	if opts == nil || opts.Snapshot == nil {
+		return lastSeq
+	}
+	return opts.Snapshot.seq
+}
+
+// verifyChecksums - returns if data blocks read should be verified against their checksum, defaultVerify is
+// what the database is configured to do
+func (opts *ReadOptions) verifyChecksums(defaultVerify bool) bool {
+	return defaultVerify || (opts != nil && opts.VerifyChecksums)
+}
@@ -0,0 +1,196 @@
+package dbengine
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func Test_snapshotShouldSeeValuesAtTheTimeItIsTaken(t *testing.T) {
+	db := getTestCompactionDB(t)
+
+	for i := 0; i < 100; i++ {
+		db.Write(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%03d", i)))
+	}
+	snap := db.NewSnapshot()
+	defer snap.Release()
+
+	// overwrites and deletes are spread across memtables and sstables
+	for i := 0; i < 100; i++ {
+		db.Write(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("new-value-%03d", i)))
+	}
+	for i := 0; i < 100; i += 2 {
+		db.Delete(fmt.Sprintf("key-%03d", i))
+	}
+	db.Write("key-new", []byte("value-new"))
+	waitForMemtableSerialization(t, db)
+
+	opts := &ReadOptions{Snapshot: snap}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		value, err := db.GetWithOptions(key, opts)
+		if err != nil {
+			t.Errorf("Failed to read key %s from snapshot - Error: %s", key, err.Error())
+		}
+		if string(value) != fmt.Sprintf("value-%03d", i) {
+			t.Errorf("Snapshot should see the value of key %s before it's overwritten, got %s", key, string(value))
+		}
+	}
+	if value, _ := db.GetWithOptions("key-new", opts); value != nil {
+		t.Errorf("Snapshot should not see keys written after it, got %s", string(value))
+	}
+	if value, _ := db.Get("key-001"); string(value) != "new-value-001" {
+		t.Errorf("Latest value should be returned without snapshot, got %s", string(value))
+	}
+	if value, _ := db.Get("key-000"); value != nil {
+		t.Errorf("Deleted key should not be found without snapshot, got %s", string(value))
+	}
+}
+
+func Test_snapshotShouldKeepVersionsItNeedsThroughCompaction(t *testing.T) {
+	db := getTestCompactionDB(t)
+
+	for i := 0; i < 500; i++ {
+		db.Write(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%03d-0", i)))
+	}
+	snap := db.NewSnapshot()
+	for round := 1; round < 3; round++ {
+		for i := 0; i < 500; i++ {
+			db.Write(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%03d-%d", i, round)))
+		}
+	}
+	for i := 0; i < 500; i += 5 {
+		db.Delete(fmt.Sprintf("key-%03d", i))
+	}
+	// make sure the last tombstones are serialized too
+	for i := 0; i < 100; i++ {
+		db.Write(fmt.Sprintf("other-%03d", i), []byte(fmt.Sprintf("value-%03d", i)))
+	}
+	waitForMemtableSerialization(t, db)
+
+	if err := db.compactSvc.compact(); err != nil {
+		t.Fatalf("Failed to compact - Error: %s", err.Error())
+	}
+
+	for i := 0; i < 500; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		value, _ := db.GetWithOptions(key, &ReadOptions{Snapshot: snap})
+		if string(value) != fmt.Sprintf("value-%03d-0", i) {
+			t.Errorf("Snapshot should see the first value of key %s, got %s", key, string(value))
+		}
+		value, _ = db.Get(key)
+		if i%5 == 0 && value != nil {
+			t.Errorf("Deleted key %s should not be found, got %s", key, string(value))
+		}
+		if i%5 != 0 && string(value) != fmt.Sprintf("value-%03d-2", i) {
+			t.Errorf("Latest value for key %s not found, got %s", key, string(value))
+		}
+	}
+
+	// once the snapshot is released, versions only it could see are dropped when all the files are compacted
+	// into the last level
+	snap.Release()
+	db.compactSvc.lock.Lock()
+	v := db.manifest.acquireVersion()
+	c := &compaction{level: 0, outputLevel: maxLevels - 1}
+	c.inputs[0] = v.files()
+	err := db.compactSvc.runCompaction(v, c)
+	db.manifest.releaseVersion(v)
+	db.compactSvc.lock.Unlock()
+	if err != nil {
+		t.Fatalf("Failed to compact - Error: %s", err.Error())
+	}
+
+	numRecords := 0
+	for _, record := range readAllRecords(t, db) {
+		if !strings.HasPrefix(record.Key, "key-") {
+			continue
+		}
+		numRecords++
+		if record.Tombstone || string(record.Value) != fmt.Sprintf("value-%s-2", record.Key[len("key-"):]) {
+			t.Errorf("Versions no longer visible should be dropped, got %s - %s", record.Key, string(record.Value))
+		}
+	}
+	if numRecords != 400 {
+		t.Errorf("Expected only the latest version of 400 keys, got %d records", numRecords)
+	}
+}
+
+func Test_iteratorShouldReadFromSnapshot(t *testing.T) {
+	db := getTestCompactionDB(t)
+
+	for i := 0; i < 100; i++ {
+		db.Write(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%03d", i)))
+	}
+	snap := db.NewSnapshot()
+	defer snap.Release()
+	for i := 0; i < 100; i += 2 {
+		db.Delete(fmt.Sprintf("key-%03d", i))
+	}
+	for i := 100; i < 200; i++ {
+		db.Write(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%03d", i)))
+	}
+
+	it, err := db.NewIteratorWithOptions("", "", &ReadOptions{Snapshot: snap})
+	if err != nil {
+		t.Fatalf("Failed to create iterator - Error: %s", err.Error())
+	}
+	defer it.Close()
+
+	count := 0
+	for it.First(); it.Valid(); it.Next() {
+		if expected := fmt.Sprintf("key-%03d", count); it.Key() != expected {
+			t.Fatalf("Expected key %s, got %s", expected, it.Key())
+		}
+		count++
+	}
+	if count != 100 {
+		t.Errorf("Expected 100 keys in snapshot, got %d", count)
+	}
+
+	count = 0
+	for it.Last(); it.Valid(); it.Prev() {
+		if expected := fmt.Sprintf("key-%03d", 99-count); it.Key() != expected {
+			t.Fatalf("Expected key %s in reverse, got %s", expected, it.Key())
+		}
+		count++
+	}
+	if count != 100 {
+		t.Errorf("Expected 100 keys in snapshot in reverse, got %d", count)
+	}
+}
+
+func Test_dbShouldNotReuseSequenceNumbersAfterReopen(t *testing.T) {
+	testDBDir := setupTestDBDir(t)
+	configs := []DBConfig{
+		ConfigDBDir(testDBDir),
+		ConfigMemtableSizeByte(512),
+		ConfigSStableDatablockSizeByte(512 / 4),
+		ConfigLogLevel(log.InfoLevel),
+	}
+
+	db, err := NewDatabase(configs...)
+	if err != nil {
+		t.Fatalf("Failed to initialize database - Error: %s", err.Error())
+	}
+	for i := 0; i < 100; i++ {
+		db.Write(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%03d", i)))
+	}
+	waitForMemtableSerialization(t, db)
+	lastSeq := db.lastSequence()
+
+	reopened, err := NewDatabase(configs...)
+	if err != nil {
+		t.Fatalf("Failed to reopen database - Error: %s", err.Error())
+	}
+	if reopened.lastSequence() != lastSeq {
+		t.Errorf("Expected last sequence %d after reopen, got %d", lastSeq, reopened.lastSequence())
+	}
+
+	reopened.Write("key-000", []byte("new-value"))
+	if value, _ := reopened.Get("key-000"); string(value) != "new-value" {
+		t.Errorf("Writes after reopen should shadow older values, got %s", string(value))
+	}
+}
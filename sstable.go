@@ -2,12 +2,13 @@ package dbengine
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/DrakeW/go-db-engine/pb"
@@ -15,30 +16,49 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-// TODO: (p3) add bloomfilter for quick key non-exist check
-
 // SSTable file layout:
-// - <data size (varint, fixed size)><data_blocks><index size (varint)><index>
+// - <data_blocks><filter><properties><index><footer>
 //
 // NOTE:
-// <data size> --> reserved number of bytes required for max 64-bit varint (binary.MaxVarintLen64), so the actual
-// data blocks always start at offset `binary.MaxVarintLen64`
-// the reason that we reserve a fixed numebr of bytes to record data size is because we don't know the size of
-// data blocks until we've written all the data blocks, and holding all the data in-memory is not efficient. Therefore
-// to improve write efficiency, we:
-// 	1. write empty size header at the beginning
-//	2. write data blocks one-by-one sequentially
-// 	3. seek to the beginning and record the total size (and seek back)
+// <footer> --> fixed-size footer at the end of the file (see `sstableFooter`) that records the format version and
+// the location of filter, properties and index, followed by a magic number so that a truncated or foreign file
+// is never mistaken for a valid one. Since the footer is written last, data blocks can be written one-by-one
+// sequentially without knowing their total size up front.
+//
+// Files written before the footer existed (format version 0) have a different layout, which is still readable:
+// - <data size (varint, fixed size)><data_blocks><filter><index>
+// where <data size> is a header of `binary.MaxVarintLen64` bytes recording the size of data blocks and filter,
+// and the location of the filter is recorded in the index.
 //
 // data_blocks layout
 // - what is it? - data blocks are concatenation of data block (see below) with each data block prefixed by their size
-// - <block_1 size (varint)><block_1>...<block_N size (varint)><block_N>
+// and followed by their CRC32C checksum
+// - <block_1 size (varint)><block_1><block_1 checksum (fixed 32-bit)>...<block_N size (varint)><block_N><block_N checksum (fixed 32-bit)>
 //
 // data block:
 // - What is it? - a data block is a block of bytes that contains key-value pairs of size roughly equal
-// to the block size configured. Optionally the bytes might be after compression so reading the data requires
-// decompression first.
-// - layout: (compressed, optionally) serialized protocol buffer
+// to the block size configured. The bytes are compressed by the compressor the writer is configured with (see
+// `Compressor`), unless compression doesn't shrink them enough, so reading the data requires decompression first.
+// - layout: <(compressed, optionally) block (see `block`)><compressor id (1 byte)>
+// - files of format version 2 and before store the records of a block as a serialized protocol buffer instead
+// - files of format version 1 and 0 have no compressor id, their blocks are always compressed by snappy
+//
+// filter:
+// - What is it? - a bloom filter of all the keys in the sstable file (see `bloomFilter`), so that a lookup of a
+// key that is not in the file can skip it without reading any data block. It's omitted if the writer has bloom
+// filter disabled.
+//
+// properties:
+// - What is it? - serialized protocol buffer of the `SSTableProperties` of the file
+//
+// index:
+// - What is it? - serialized protocol buffer of the start key, end key, offset and size of every data block.
+// - If the index grows bigger than the partition size the writer is configured with, it's partitioned: the index
+// is split into partitions written right before a top-level index, whose entries locate the partitions the same
+// way. Only the top-level index has to be kept in memory, partitions are read through the block cache.
+//
+// filter, properties and index are size prefixed and followed by their checksum as well. Files written before
+// checksums existed have none, they are read without verification.
 
 // SSTableWriter - represents a writer that dump content into a sstable file
 type SSTableWriter interface {
@@ -47,6 +67,15 @@ type SSTableWriter interface {
 
 	// Dump - dumps the memtable into the sstable file
 	Dump(MemTable) error
+
+	// Add - appends a record to the sstable file, records have to be added in increasing key order
+	Add(*MemtableRecord) error
+
+	// Finish - writes all the added records and the index into the sstable file, no record can be added after
+	Finish() error
+
+	// Size - returns roughly how many bytes have been added into the sstable file so far
+	Size() uint64
 }
 
 // SSTableReader - represents a reader that reads data from a sstable file
@@ -54,14 +83,25 @@ type SSTableReader interface {
 	// Index - returns the index of the sstable, if there is one
 	Index() SSTableIndex
 
+	// Properties - returns the properties recorded when the sstable file was written
+	Properties() *SSTableProperties
+
 	// File - returns the file path of the sstable file
 	File() string
 
-	// Get - returns the value of key specified
-	Get(key string) ([]byte, error)
+	// Get - returns the latest record of key written at or before sequence number seq, nil if there is none
+	// in the sstable. A deleted key returns a tombstone record
+	Get(key string, seq uint64) (*MemtableRecord, error)
 
-	// GetRange - returns the values of key range specified
+	// GetRange - returns the latest values of key range specified [start, end), deleted keys are skipped
 	GetRange(start, end string) ([][]byte, error)
+
+	// NewIterator - returns an iterator that goes through every version of the records in the sstable file
+	// in key order, versions of the same key are ordered from the latest to the earliest
+	NewIterator() RecordIterator
+
+	// Close - closes the underlying sstable file
+	Close() error
 }
 
 // SSTableIndex - represents an index for a SSTable file
@@ -78,17 +118,57 @@ type SSTableIndex interface {
 
 // BasicSSTable - a basic implementation of the `SSTableReader` and `SSTableWriter` interface
 type BasicSSTable struct {
-	file        *os.File
-	idx         *BasicSSTableIndex
-	BlockSize   uint                        // BlockSize - controls roughly how big each block should be (in bytes)
-	rBlockCache map[uint64]*pb.SSTableBlock // reader cache for block that has been read before, key is offset of data block
+	file *os.File
+	// idx - index of the data blocks being written for a writer. For a reader it's the top-level index if the
+	// index is partitioned, nil if it's kept in the block cache
+	idx       *BasicSSTableIndex
+	BlockSize uint        // BlockSize - controls roughly how big each block should be (in bytes)
+	filter    bloomFilter // filter - bloom filter of the keys held by reader, nil if there is none or it's in the block cache
+	r         sstableReaderState
+	w         sstableWriterState
+}
+
+// sstableReaderState - where a reader finds its blocks
+type sstableReaderState struct {
+	cache  *blockCache // cache - block cache shared by the readers of the database, nil if blocks aren't cached
+	number uint64      // number - number of the sstable file, identifies its blocks in the block cache
+	// pinned - true if index and filter are held by the reader for its lifetime instead of being evictable
+	// from the block cache
+	pinned bool
+	// verifyChecksums - if data blocks are verified against their checksum when read by default. Index and
+	// filter are always verified
+	verifyChecksums bool
+	// idx, filter - location of index and filter in the file, the size of the index is 0 for files written in
+	// the legacy format
+	idx        blockHandle
+	filter     blockHandle
+	properties *SSTableProperties
+	// pinnedEntries - index and filter pinned in the block cache, removed once the reader is closed
+	pinnedEntries []*blockCacheEntry
+}
+
+// sstableWriterState - keeps track of the data written so far by a writer
+type sstableWriterState struct {
+	block      *blockBuilder       // block - data block being filled, written to file once it reaches BlockSize
+	dataSize   int                 // dataSize - total size of data blocks written to file
+	filter     *bloomFilterBuilder // filter - collects the keys added, nil if bloom filter is disabled
+	compressor Compressor          // compressor - compresses data blocks, nil if they're stored uncompressed
+	// indexPartitionSize - roughly how big (in bytes) each index partition should be, 0 if the index is never
+	// partitioned
+	indexPartitionSize uint
+	props              SSTableProperties
+	footer             sstableFooter
 }
 
 // BasicSSTableIndex - a basic implementation of the `SSTableIndex` interface
 type BasicSSTableIndex struct {
-	entries []*indexEntry
-	// map start key to index entry
-	meta map[string]*indexEntry
+	entries []*indexEntry // entries - sorted by key, lookups binary search over them
+	// partitioned - true if entries locate index partitions instead of data blocks
+	partitioned bool
+	// filterOffset, filterSize - location of the bloom filter in an sstable file written in the legacy format,
+	// filterSize is 0 if there is none
+	filterOffset uint64
+	filterSize   uint64
 }
 
 type indexEntry struct {
@@ -102,9 +182,15 @@ const (
 	OP_SSTABLE_READ_FILE      = "OP_SSTABLE_READ_FILE"
 	OP_SSTABLE_LOAD_INDEX     = "OP_SSTABLE_LOAD_INDEX"
 	OP_SSTABLE_LOAD_DATABLOCK = "OP_SSTABLE_LOAD_DATABLOCK"
+	OP_SSTABLE_LOAD_FILTER    = "OP_SSTABLE_LOAD_FILTER"
+	OP_SSTABLE_LOAD_FOOTER    = "OP_SSTABLE_LOAD_FOOTER"
+	OP_SSTABLE_LOAD_PROPS     = "OP_SSTABLE_LOAD_PROPS"
 	OP_SSTABLE_CREATE_FILE    = "OP_SSTABLE_CREATE_FILE"
 	OP_SSTABLE_WRITE_DATA     = "OP_SSTABLE_WRITE_DATA"
+	OP_SSTABLE_WRITE_FILTER   = "OP_SSTABLE_WRITE_FILTER"
 	OP_SSTABLE_WRITE_INDEX    = "OP_SSTABLE_WRITE_INDEX"
+	OP_SSTABLE_WRITE_PROPS    = "OP_SSTABLE_WRITE_PROPS"
+	OP_SSTABLE_WRITE_FOOTER   = "OP_SSTABLE_WRITE_FOOTER"
 )
 
 // SSTableError - includes error for specifc sstable operation
@@ -121,8 +207,14 @@ func (stErr *SSTableError) Unwrap() error {
 	return stErr.Err
 }
 
-// NewBasicSSTableWriter - creates a new `SSTableWriter` instance along with newly created sstable file
-func NewBasicSSTableWriter(sstableDir string, blockSize uint) (SSTableWriter, error) {
+// NewBasicSSTableWriter - creates a new `SSTableWriter` instance along with newly created sstable file. A bloom
+// filter with bloomBitsPerKey bits for each key is written into the file, no filter if bloomBitsPerKey is 0.
+// Data blocks are compressed by compressor, they're stored uncompressed if compressor is nil. The index is
+// partitioned into partitions of roughly indexPartitionSize bytes once it's bigger than that, it's never
+// partitioned if indexPartitionSize is 0
+func NewBasicSSTableWriter(
+	sstableDir string, blockSize uint, bloomBitsPerKey int, compressor Compressor, indexPartitionSize uint,
+) (SSTableWriter, error) {
 	sstableFile, err := newSSTableFile(sstableDir)
 	if err != nil {
 		return nil, &SSTableError{
@@ -130,15 +222,34 @@ func NewBasicSSTableWriter(sstableDir string, blockSize uint) (SSTableWriter, er
 			Err: err,
 		}
 	}
+	var filter *bloomFilterBuilder
+	if bloomBitsPerKey > 0 {
+		filter = newBloomFilterBuilder(bloomBitsPerKey)
+	}
 	return &BasicSSTable{
 		file:      sstableFile,
 		idx:       NewBasicSSTableIndex(),
 		BlockSize: blockSize,
+		w: sstableWriterState{
+			block:              newBlockBuilder(),
+			filter:             filter,
+			compressor:         compressor,
+			indexPartitionSize: indexPartitionSize,
+			props:              SSTableProperties{Compression: compressorName(compressor)},
+		},
 	}, nil
 }
 
 // NewBasicSSTableReader - creates a new `SSTableReader` instance that handles reading data from sstable file
 func NewBasicSSTableReader(sstableFile string) (SSTableReader, error) {
+	return newCachedSSTableReader(sstableFile, 0, nil, true, true)
+}
+
+// newCachedSSTableReader - creates a new `SSTableReader` that reads data blocks of sstable file with number
+// through the block cache. Index and filter are put into the block cache as well, pinned there for the
+// lifetime of the reader if pinIndexAndFilter is true, otherwise they're evicted like data blocks and loaded
+// again when needed. Data blocks are verified against their checksum when read if verifyChecksums is true
+func newCachedSSTableReader(sstableFile string, number uint64, cache *blockCache, pinIndexAndFilter, verifyChecksums bool) (*BasicSSTable, error) {
 	// open file in read-only mode since reader shouldn't be writing to sstable file
 	f, err := os.OpenFile(sstableFile, os.O_RDONLY, 0444)
 	if err != nil {
@@ -148,52 +259,154 @@ func NewBasicSSTableReader(sstableFile string) (SSTableReader, error) {
 		}
 	}
 
-	idx, err := loadIndexFromFile(f)
+	footer, err := readSSTableFooter(f)
+	if err == nil && footer == nil {
+		// a file written in the legacy format, its index starts right after the data blocks section
+		footer = &sstableFooter{version: sstableLegacyFormatVersion}
+		footer.index.offset, err = readLegacyIndexOffset(f)
+	}
 	if err != nil {
+		f.Close()
 		return nil, &SSTableError{
-			Op:  OP_SSTABLE_LOAD_INDEX,
+			Op:  OP_SSTABLE_LOAD_FOOTER,
 			Err: err,
 		}
 	}
 
-	return &BasicSSTable{
-		file:        f,
-		idx:         idx,
-		BlockSize:   0, // BlockSize - set to 0 since for reader this doesn't matter
-		rBlockCache: make(map[uint64]*pb.SSTableBlock),
-	}, nil
-}
+	idx, idxSize, err := loadIndexFromFile(f, footer.index)
+	if err != nil {
+		f.Close()
+		return nil, &SSTableError{
+			Op:  OP_SSTABLE_LOAD_INDEX,
+			Err: err,
+		}
+	}
 
-// loadIndexFromFile - load sstable index from the sstable file
-func loadIndexFromFile(f *os.File) (*BasicSSTableIndex, error) {
-	reader := bufio.NewReader(f)
+	props := &SSTableProperties{FormatVersion: footer.version}
+	if footer.version == sstableLegacyFormatVersion {
+		footer.filter = blockHandle{offset: idx.filterOffset, size: idx.filterSize}
+	} else if footer.properties.size > 0 {
+		if props, err = loadPropertiesFromFile(f, footer.properties, footer.version); err != nil {
+			f.Close()
+			return nil, &SSTableError{
+				Op:  OP_SSTABLE_LOAD_PROPS,
+				Err: err,
+			}
+		}
+	}
 
-	dataSize, err := binary.ReadUvarint(reader)
+	filter, err := loadFilterFromFile(f, footer.filter, footer.version)
 	if err != nil {
-		return nil, err
+		f.Close()
+		return nil, &SSTableError{
+			Op:  OP_SSTABLE_LOAD_FILTER,
+			Err: err,
+		}
 	}
 
-	// move to the end of the data blocks section
-	f.Seek(int64(dataSize+binary.MaxVarintLen64), io.SeekStart)
-	// discard any buffered data so the previous seek is effective
-	reader.Reset(f)
+	s := &BasicSSTable{
+		file:      f,
+		idx:       idx,
+		BlockSize: 0, // BlockSize - set to 0 since for reader this doesn't matter
+		filter:    filter,
+		r: sstableReaderState{
+			cache:           cache,
+			number:          number,
+			pinned:          pinIndexAndFilter || cache == nil,
+			verifyChecksums: verifyChecksums,
+			idx:             footer.index,
+			filter:          footer.filter,
+			properties:      props,
+		},
+	}
+	if cache != nil {
+		entries := []*blockCacheEntry{cache.insert(s.blockCacheKey(footer.index.offset), idx, idxSize, s.r.pinned)}
+		if filter != nil {
+			entries = append(entries, cache.insert(s.blockCacheKey(footer.filter.offset), filter, len(filter), s.r.pinned))
+		}
+		if s.r.pinned {
+			s.r.pinnedEntries = entries
+		} else {
+			s.idx, s.filter = nil, nil
+		}
+	}
+	return s, nil
+}
 
-	buf, err := ReadDataWithVarintPrefix(reader, nil)
-	if err != nil {
-		return nil, err
+// loadIndexFromFile - load sstable index of the handle from the sstable file, along with its size in bytes. The
+// index is verified against its checksum. The size of the handle is 0 for files written in the legacy format,
+// where the index takes up the rest of the file
+func loadIndexFromFile(f *os.File, handle blockHandle) (*BasicSSTableIndex, int, error) {
+	corrupted := func(reason string) error {
+		return &CorruptionError{File: f.Name(), Offset: int64(handle.offset), Reason: "index - " + reason}
+	}
+
+	var buf []byte
+	if handle.size > 0 {
+		raw := make([]byte, handle.size)
+		if _, err := f.ReadAt(raw, int64(handle.offset)); err != nil {
+			return nil, 0, err
+		}
+		var reason string
+		// only files with a footer have a handle with a size, all their blocks are written with a checksum
+		if buf, reason = readChecksummedData(raw, true, true); reason != "" {
+			return nil, 0, corrupted(reason)
+		}
+	} else {
+		offset := int64(handle.offset)
+		reader := bufio.NewReader(io.NewSectionReader(f, offset, math.MaxInt64-offset))
+		var err error
+		buf, err = ReadDataWithVarintPrefix(reader, nil)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, 0, corrupted("truncated")
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		// the index is the end of the file, unless it's followed by its checksum
+		sum := make([]byte, checksumSize)
+		if _, err := io.ReadFull(reader, sum); err == nil {
+			if binary.LittleEndian.Uint32(sum) != checksum(buf) {
+				return nil, 0, corrupted("checksum mismatch")
+			}
+		} else if err != io.EOF {
+			return nil, 0, corrupted("truncated checksum")
+		}
 	}
 
 	idx := &pb.SSTableIndex{}
-	if err = proto.Unmarshal(buf, idx); err != nil {
-		return nil, err
+	if err := proto.Unmarshal(buf, idx); err != nil {
+		return nil, 0, corrupted(err.Error())
 	}
 
 	sstableIdx := NewBasicSSTableIndex()
 	for _, entry := range idx.Data {
 		sstableIdx.update(entry.StartKey, entry.EndKey, entry.Offset, entry.Size)
 	}
+	sstableIdx.filterOffset = idx.FilterOffset
+	sstableIdx.filterSize = idx.FilterSize
+	sstableIdx.partitioned = idx.Partitioned
 
-	return sstableIdx, nil
+	return sstableIdx, len(buf), nil
+}
+
+// loadFilterFromFile - load the bloom filter of the handle from the sstable file of format version, nil if there
+// is none
+func loadFilterFromFile(f *os.File, handle blockHandle, version uint32) (bloomFilter, error) {
+	if handle.size == 0 {
+		return nil, nil
+	}
+
+	buf := make([]byte, handle.size)
+	if _, err := f.ReadAt(buf, int64(handle.offset)); err != nil {
+		return nil, err
+	}
+	filter, reason := readChecksummedData(buf, true, version != sstableLegacyFormatVersion)
+	if reason != "" {
+		return nil, &CorruptionError{File: f.Name(), Offset: int64(handle.offset), Reason: "filter - " + reason}
+	}
+	return filter, nil
 }
 
 func newSSTableFile(sstableDir string) (*os.File, error) {
@@ -208,9 +421,126 @@ func newSSTableFile(sstableDir string) (*os.File, error) {
 	return f, nil
 }
 
-// Index - returns the index of the sstable, if there is one
+// Index - returns the index of the sstable, nil if it can't be loaded
 func (s *BasicSSTable) Index() SSTableIndex {
-	return s.idx
+	idx, err := s.index()
+	if err != nil {
+		return nil
+	}
+	if idx.partitioned {
+		return &partitionedSSTableIndex{s: s, top: idx}
+	}
+	return idx
+}
+
+// keyRange - returns the smallest and the largest key in the sstable file
+func (s *BasicSSTable) keyRange() (smallest, largest string, err error) {
+	idx, err := s.index()
+	if err != nil {
+		return "", "", err
+	}
+	smallest, largest = idx.keyRange()
+	return smallest, largest, nil
+}
+
+// Properties - returns the properties recorded when the sstable file was written
+func (s *BasicSSTable) Properties() *SSTableProperties {
+	return s.r.properties
+}
+
+// index - returns the index held by the reader, or the one in the block cache (loaded again if evicted)
+func (s *BasicSSTable) index() (*BasicSSTableIndex, error) {
+	if s.idx != nil {
+		return s.idx, nil
+	}
+
+	key := s.blockCacheKey(s.r.idx.offset)
+	if cached, ok := s.r.cache.get(key); ok {
+		return cached.(*BasicSSTableIndex), nil
+	}
+	idx, idxSize, err := loadIndexFromFile(s.file, s.r.idx)
+	if err != nil {
+		return nil, &SSTableError{
+			Op:  OP_SSTABLE_LOAD_INDEX,
+			Err: err,
+		}
+	}
+	s.r.cache.insert(key, idx, idxSize, false)
+	return idx, nil
+}
+
+// indexPartition - returns the index partition located by the entry of the top-level index from the block
+// cache, reads it from the sstable file (and caches it) if it's not cached
+func (s *BasicSSTable) indexPartition(entry *indexEntry) (*BasicSSTableIndex, error) {
+	handle := blockHandle{offset: entry.offset, size: entry.size}
+	if s.r.cache == nil {
+		partition, _, err := loadIndexFromFile(s.file, handle)
+		if err != nil {
+			return nil, &SSTableError{Op: OP_SSTABLE_LOAD_INDEX, Err: err}
+		}
+		return partition, nil
+	}
+
+	key := s.blockCacheKey(entry.offset)
+	if cached, ok := s.r.cache.get(key); ok {
+		return cached.(*BasicSSTableIndex), nil
+	}
+	partition, size, err := loadIndexFromFile(s.file, handle)
+	if err != nil {
+		return nil, &SSTableError{Op: OP_SSTABLE_LOAD_INDEX, Err: err}
+	}
+	s.r.cache.insert(key, partition, size, false)
+	return partition, nil
+}
+
+// findBlock - returns the location of the data block that may contain key
+func (s *BasicSSTable) findBlock(key string) (offset, size uint64, exist bool, err error) {
+	idx, err := s.index()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if !idx.partitioned {
+		offset, size, exist = idx.GetOffset(key)
+		return offset, size, exist, nil
+	}
+
+	i := idx.seekBlock(key)
+	if i == len(idx.entries) || key < idx.entries[i].startKey {
+		return 0, 0, false, nil
+	}
+	partition, err := s.indexPartition(idx.entries[i])
+	if err != nil {
+		return 0, 0, false, err
+	}
+	offset, size, exist = partition.GetOffset(key)
+	return offset, size, exist, nil
+}
+
+// bloom - returns the bloom filter held by the reader, or the one in the block cache (loaded again if
+// evicted). nil if the sstable file has no filter
+func (s *BasicSSTable) bloom() (bloomFilter, error) {
+	if s.filter != nil || s.r.pinned || s.r.filter.size == 0 {
+		return s.filter, nil
+	}
+
+	key := s.blockCacheKey(s.r.filter.offset)
+	if cached, ok := s.r.cache.get(key); ok {
+		return cached.(bloomFilter), nil
+	}
+	filter, err := loadFilterFromFile(s.file, s.r.filter, s.r.properties.FormatVersion)
+	if err != nil {
+		return nil, &SSTableError{
+			Op:  OP_SSTABLE_LOAD_FILTER,
+			Err: err,
+		}
+	}
+	s.r.cache.insert(key, filter, len(filter), false)
+	return filter, nil
+}
+
+// blockCacheKey - returns the key of the block at offset in the block cache
+func (s *BasicSSTable) blockCacheKey(offset uint64) blockCacheKey {
+	return blockCacheKey{fileNumber: s.r.number, offset: offset}
 }
 
 // File - returns the file path of the sstable file
@@ -220,15 +550,50 @@ func (s *BasicSSTable) File() string {
 
 // Dump - dumps the memtable into the sstable file
 func (s *BasicSSTable) Dump(m MemTable) error {
-	records := m.GetAll()
+	for _, record := range m.GetAll() {
+		if err := s.Add(record); err != nil {
+			return err
+		}
+	}
+	return s.Finish()
+}
+
+// Add - appends a record to the sstable file, records have to be added in increasing key order and versions
+// of the same key from the latest to the earliest. Records are buffered until they fill up a data block
+func (s *BasicSSTable) Add(record *MemtableRecord) error {
+	if err := s.addRecord(record); err != nil {
+		return &SSTableError{
+			Op:  OP_SSTABLE_WRITE_DATA,
+			Err: err,
+		}
+	}
+	return nil
+}
 
+// Finish - writes the buffered records and the index into the sstable file, then flushes and closes the file.
+// No record can be added after.
+func (s *BasicSSTable) Finish() error {
 	// write data
-	if err := s.writeDataAndBuildIndex(records); err != nil {
+	if err := s.finishData(); err != nil {
 		return &SSTableError{
 			Op:  OP_SSTABLE_WRITE_DATA,
 			Err: err,
 		}
 	}
+	// write filter
+	if err := s.writeFilter(); err != nil {
+		return &SSTableError{
+			Op:  OP_SSTABLE_WRITE_FILTER,
+			Err: err,
+		}
+	}
+	// write properties
+	if err := s.writeProperties(); err != nil {
+		return &SSTableError{
+			Op:  OP_SSTABLE_WRITE_PROPS,
+			Err: err,
+		}
+	}
 	// write index
 	if err := s.writeIndex(); err != nil {
 		return &SSTableError{
@@ -236,263 +601,661 @@ func (s *BasicSSTable) Dump(m MemTable) error {
 			Err: err,
 		}
 	}
+	// write footer
+	if err := s.writeFooter(); err != nil {
+		return &SSTableError{
+			Op:  OP_SSTABLE_WRITE_FOOTER,
+			Err: err,
+		}
+	}
 
+	if err := s.file.Sync(); err != nil {
+		return &SSTableError{
+			Op:  OP_SSTABLE_WRITE_INDEX,
+			Err: err,
+		}
+	}
+	return s.file.Close()
+}
+
+// Size - returns roughly how many bytes have been added into the sstable file so far
+func (s *BasicSSTable) Size() uint64 {
+	return uint64(s.w.dataSize + s.w.block.size())
+}
+
+// addRecord - add record to the current data block. Once the block size reaches the configured block size, the
+// block is written to the sstable file (and index updated correspondingly) before the next key is added, so
+// all the versions of a key always end up in the same data block
+func (s *BasicSSTable) addRecord(record *MemtableRecord) error {
+	// a block is only flushed before a new key, so only the last key added could be the same key
+	newKey := s.w.props.NumRecords == 0 || s.w.props.LargestKey != record.Key
+	if newKey && s.w.filter != nil {
+		s.w.filter.add(record.Key)
+	}
+	if newKey && uint(s.w.block.size()) >= s.BlockSize && !s.w.block.empty() {
+		if err := s.flushBlock(); err != nil {
+			return err
+		}
+	}
+
+	s.w.block.add(record)
+
+	if s.w.props.NumRecords == 0 {
+		s.w.props.SmallestKey = record.Key
+	}
+	s.w.props.LargestKey = record.Key
+	s.w.props.NumRecords++
+	if record.Tombstone {
+		s.w.props.NumDeletions++
+	}
 	return nil
 }
 
-// writeDataAndBuildIndex - write data to the sstable file and build the index based on the data
-func (s *BasicSSTable) writeDataAndBuildIndex(records []*MemtableRecord) error {
-	// record current pos for data size header
-	sizeHeaderOffset, err := s.file.Seek(0, io.SeekCurrent)
+// flushBlock - write the current data block to the sstable file and update index
+func (s *BasicSSTable) flushBlock() error {
+	block := s.w.block
+	written, err := s.writeBlock(block.finish())
 	if err != nil {
 		return err
 	}
+	// update index, offset is previous total data size (data blocks start at the beginning of the file)
+	s.idx.update(block.firstKey, block.lastKey, uint64(s.w.dataSize), uint64(written))
 
-	// write data size header placeholder
-	sizeBuf := make([]byte, binary.MaxVarintLen64)
-	if _, err := s.file.Write(sizeBuf); err != nil {
-		return err
+	// update tracker states
+	s.w.dataSize += written
+	s.w.props.NumDataBlocks++
+	s.w.block = newBlockBuilder()
+	return nil
+}
+
+// finishData - write the last (partially filled) data block
+func (s *BasicSSTable) finishData() error {
+	if !s.w.block.empty() {
+		if err := s.flushBlock(); err != nil {
+			return err
+		}
+	}
+	s.w.props.DataSize = uint64(s.w.dataSize)
+	return nil
+}
+
+// writeFilter - write the bloom filter of the keys added right after the data blocks and record its location
+// in the footer
+func (s *BasicSSTable) writeFilter() error {
+	if s.w.filter == nil {
+		return nil
 	}
 
-	// write data blocks
-	totalWritten, err := s.writeDataBlocksAndUpdateIndex(binary.MaxVarintLen64, records)
+	handle, err := s.writeMetaBlock(s.w.filter.build())
 	if err != nil {
 		return err
 	}
+	s.w.footer.filter = handle
+	return nil
+}
 
-	// write data size to the header
-	binary.PutUvarint(sizeBuf, uint64(totalWritten))
-	if _, err = s.file.WriteAt(sizeBuf, sizeHeaderOffset); err != nil {
+// writeProperties - write the properties of the file and record their location in the footer
+func (s *BasicSSTable) writeProperties() error {
+	data, err := s.w.props.serialize()
+	if err != nil {
 		return err
 	}
 
+	handle, err := s.writeMetaBlock(data)
+	if err != nil {
+		return err
+	}
+	s.w.footer.properties = handle
 	return nil
 }
 
-// writeDataBlocksAndUpdateIndex - write memtable records to sstable file starting at offset `startOffset` (to
-// account for data size header) and update index corespondingly and return total bytes written
-func (s *BasicSSTable) writeDataBlocksAndUpdateIndex(startOffset int, records []*MemtableRecord) (int, error) {
-	accBlockKeyValueSize := 0
-	totalDataSize := 0
-
-	block := &pb.SSTableBlock{
-		Data: make([]*pb.SSTableKeyValue, 0),
+// writeMetaBlock - write a block that isn't a data block after everything written so far and return its location
+func (s *BasicSSTable) writeMetaBlock(data []byte) (blockHandle, error) {
+	offset, err := s.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return blockHandle{}, err
 	}
+	written, err := writeChecksummedData(s.file, data)
+	if err != nil {
+		return blockHandle{}, err
+	}
+	return blockHandle{offset: uint64(offset), size: uint64(written)}, nil
+}
 
-	// write data blocks
-	for i := 0; i < len(records); i++ {
-		record := records[i]
-		block.Data = append(block.Data, &pb.SSTableKeyValue{
-			Key:   record.Key,
-			Value: record.Value,
-		})
-		accBlockKeyValueSize += len(record.Key) + len(record.Value)
+// writeBlock - compress a data block and write it to the sstable file
+func (s *BasicSSTable) writeBlock(raw []byte) (int, error) {
+	compressed, err := compressBlock(s.w.compressor, raw)
+	if err != nil {
+		return 0, err
+	}
 
-		if uint(accBlockKeyValueSize) >= s.BlockSize {
-			// write block to disk once size reaches configured block size
-			written, err := s.writeBlock(block)
-			if err != nil {
-				return totalDataSize, err
-			}
-			// update index, offset is previous total data size
-			startKey := block.Data[0].Key
-			endKey := block.Data[len(block.Data)-1].Key
-			s.idx.update(startKey, endKey, uint64(startOffset+totalDataSize), uint64(written))
+	written, err := writeChecksummedData(s.file, compressed)
+	if err != nil {
+		return written, err
+	}
+	return written, nil
+}
 
-			// update tracker states
-			totalDataSize += written
-			accBlockKeyValueSize = 0
-			block.Data = make([]*pb.SSTableKeyValue, 0)
+// writeIndex - write sstable index to sstable file and record its location in the footer. The index is written
+// as partitions followed by a top-level index if it's bigger than the partition size
+func (s *BasicSSTable) writeIndex() error {
+	data, err := s.idx.Serialize()
+	if err != nil {
+		return err
+	}
+	if s.w.indexPartitionSize > 0 && uint(len(data)) > s.w.indexPartitionSize {
+		if data, err = s.writeIndexPartitions(); err != nil {
+			return err
 		}
 	}
 
-	if accBlockKeyValueSize > 0 {
-		// write block to disk once size reaches configured block size
-		written, err := s.writeBlock(block)
+	handle, err := s.writeMetaBlock(data)
+	if err != nil {
+		return err
+	}
+	s.w.footer.index = handle
+	return nil
+}
+
+// writeIndexPartitions - write the index as partitions of roughly the partition size, returns the serialized
+// top-level index that locates them
+func (s *BasicSSTable) writeIndexPartitions() ([]byte, error) {
+	top := NewBasicSSTableIndex()
+	top.partitioned = true
+
+	partition := NewBasicSSTableIndex()
+	partitionSize := 0
+	flush := func() error {
+		data, err := partition.Serialize()
 		if err != nil {
-			return totalDataSize, err
+			return err
 		}
-		// update index, offset is previous total data size
-		startKey := block.Data[0].Key
-		endKey := block.Data[len(block.Data)-1].Key
-		s.idx.update(startKey, endKey, uint64(startOffset+totalDataSize), uint64(written))
+		handle, err := s.writeMetaBlock(data)
+		if err != nil {
+			return err
+		}
+		smallest, largest := partition.keyRange()
+		top.update(smallest, largest, handle.offset, handle.size)
+		partition, partitionSize = NewBasicSSTableIndex(), 0
+		return nil
+	}
 
-		totalDataSize += written
+	for _, entry := range s.idx.entries {
+		partition.update(entry.startKey, entry.endKey, entry.offset, entry.size)
+		// keys plus roughly what offset, size and protobuf framing take
+		partitionSize += len(entry.startKey) + len(entry.endKey) + 24
+		if uint(partitionSize) >= s.w.indexPartitionSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(partition.entries) > 0 {
+		if err := flush(); err != nil {
+			return nil, err
+		}
 	}
+	return top.Serialize()
+}
+
+// writeFooter - write the footer at the end of the sstable file
+func (s *BasicSSTable) writeFooter() error {
+	s.w.footer.version = sstableFormatVersion
+	_, err := s.file.Write(s.w.footer.encode())
+	return err
+}
 
-	return totalDataSize, nil
+// decompress - decompresses a data block read from the sstable file
+func (s *BasicSSTable) decompress(compressed []byte) ([]byte, error) {
+	if s.r.properties.FormatVersion < sstableCompressorIDFormatVersion {
+		return snappy.Decode(nil, compressed)
+	}
+	return decompressBlock(compressed)
 }
 
-// writeBlock - write a data block to the sstable file
-func (s *BasicSSTable) writeBlock(block *pb.SSTableBlock) (int, error) {
-	raw, err := s.serializeBlock(block)
+// Get - returns the latest record of key written at or before sequence number seq, nil if there is none
+func (s *BasicSSTable) Get(key string, seq uint64) (*MemtableRecord, error) {
+	return s.get(key, seq, s.r.verifyChecksums)
+}
+
+// get - same as `Get`, the data block read from the file is verified against its checksum if verify is true
+func (s *BasicSSTable) get(key string, seq uint64, verify bool) (*MemtableRecord, error) {
+	filter, err := s.bloom()
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	if !filter.mayContain(key) {
+		return nil, nil
 	}
 
-	written, err := WriteDataWithVarintSizePrefix(s.file, raw)
+	// read data block into memory
+	offset, size, exist, err := s.findBlock(key)
+	if err != nil || !exist {
+		return nil, err
+	}
+
+	block, err := s.getBlock(offset, size, verify)
 	if err != nil {
-		return written, err
+		return nil, err
 	}
-	return written, nil
+
+	// find the key in the data block, versions of the key are ordered from the latest
+	it := block.newIterator()
+	for it.seek(key); it.valid() && string(it.key) == key; it.next() {
+		if it.seq <= seq {
+			return it.record(), nil
+		}
+	}
+	if it.err != "" {
+		return nil, s.blockCorruption(offset, it.err)
+	}
+	return nil, nil
 }
 
-// serializeBlock - serialize a data block into bytes
-func (s *BasicSSTable) serializeBlock(block *pb.SSTableBlock) ([]byte, error) {
-	data, err := proto.Marshal(block)
+// getBlock - returns the data block at offset from the block cache, reads it from the sstable file (and
+// caches it) if it's not cached. A cached block that wasn't verified is read again if verify is true
+func (s *BasicSSTable) getBlock(offset, size uint64, verify bool) (*block, error) {
+	if s.r.cache == nil {
+		return s.readBlock(offset, size, verify)
+	}
+
+	key := s.blockCacheKey(offset)
+	if cached, ok := s.r.cache.get(key); ok && (cached.(*block).verified || !verify) {
+		return cached.(*block), nil
+	}
+	block, err := s.readBlock(offset, size, verify)
 	if err != nil {
 		return nil, err
 	}
+	s.r.cache.insert(key, block, block.size(), false)
+	return block, nil
+}
 
-	compressed, err := s.compress(data)
+// readBlock - reads the data block at offset from the sstable file, the block is verified against its checksum
+// if verify is true. Returns `ErrCorruption` if the block is corrupted.
+func (s *BasicSSTable) readBlock(offset, size uint64, verify bool) (*block, error) {
+	buf := make([]byte, size, size)
+	if _, err := s.file.ReadAt(buf, int64(offset)); err != nil {
+		return nil, &SSTableError{
+			Op:  OP_SSTABLE_LOAD_DATABLOCK,
+			Err: err,
+		}
+	}
+
+	dataBuf, reason := readChecksummedData(buf, verify, s.r.properties.FormatVersion != sstableLegacyFormatVersion)
+	if reason != "" {
+		return nil, s.blockCorruption(offset, reason)
+	}
+
+	data, err := s.decompress(dataBuf)
 	if err != nil {
+		return nil, s.blockCorruption(offset, err.Error())
+	}
+
+	if s.r.properties.FormatVersion < sstableRestartBlockFormatVersion {
+		if data, err = convertProtoBlock(data); err != nil {
+			return nil, s.blockCorruption(offset, err.Error())
+		}
+	}
+	block, reason := newBlock(data)
+	if reason != "" {
+		return nil, s.blockCorruption(offset, reason)
+	}
+	block.verified = verify
+	return block, nil
+}
+
+// blockCorruption - returns the error of a corrupted data block at offset
+func (s *BasicSSTable) blockCorruption(offset uint64, reason string) error {
+	return &SSTableError{
+		Op:  OP_SSTABLE_LOAD_DATABLOCK,
+		Err: &CorruptionError{File: s.file.Name(), Offset: int64(offset), Reason: "data block - " + reason},
+	}
+}
+
+// convertProtoBlock - converts a data block serialized as a protocol buffer, which is how files of format
+// version 2 and before store data blocks, into a block of the current format
+func convertProtoBlock(data []byte) ([]byte, error) {
+	pbBlock := &pb.SSTableBlock{}
+	if err := proto.Unmarshal(data, pbBlock); err != nil {
 		return nil, err
 	}
 
-	return compressed, nil
+	builder := newBlockBuilder()
+	for _, entry := range pbBlock.Data {
+		builder.add(&MemtableRecord{
+			Key:       entry.Key,
+			Value:     entry.Value,
+			Tombstone: entry.Type == pb.RecordType_TOMBSTONE,
+			Seq:       entry.Seq,
+		})
+	}
+	return builder.finish(), nil
 }
 
-// writeIndex - write sstable index to sstable file and return total bytes written
-func (s *BasicSSTable) writeIndex() error {
-	data, err := s.idx.Serialize()
+// verifyChecksums - reads every data block (and index partition) in the sstable file from disk and verifies it
+// against its checksum, returns `ErrCorruption` at the first block corrupted
+func (s *BasicSSTable) verifyChecksums() error {
+	idx, err := s.index()
 	if err != nil {
 		return err
 	}
+	partitions := []*BasicSSTableIndex{idx}
+	if idx.partitioned {
+		partitions = partitions[:0]
+		for _, entry := range idx.entries {
+			partition, _, err := loadIndexFromFile(s.file, blockHandle{offset: entry.offset, size: entry.size})
+			if err != nil {
+				return &SSTableError{Op: OP_SSTABLE_LOAD_INDEX, Err: err}
+			}
+			partitions = append(partitions, partition)
+		}
+	}
 
-	_, err = WriteDataWithVarintSizePrefix(s.file, data)
-	if err != nil {
-		return err
+	for _, partition := range partitions {
+		for _, entry := range partition.entries {
+			if _, err := s.readBlock(entry.offset, entry.size, true); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
-// compress - compresses a data block
-func (s *BasicSSTable) compress(raw []byte) ([]byte, error) {
-	return snappy.Encode(nil, raw), nil
+// Close - closes the underlying sstable file
+func (s *BasicSSTable) Close() error {
+	for _, entry := range s.r.pinnedEntries {
+		s.r.cache.remove(entry)
+	}
+	s.r.pinnedEntries = nil
+	return s.file.Close()
 }
 
-// decompress - decompresses a data block
-func (s *BasicSSTable) decompress(compressed []byte) ([]byte, error) {
-	raw, err := snappy.Decode(nil, compressed)
-	if err != nil {
-		return nil, err
+// NewIterator - returns an iterator that goes through every version of the records in the sstable file
+// in key order
+func (s *BasicSSTable) NewIterator() RecordIterator {
+	return s.newIterator(s.r.verifyChecksums)
+}
+
+// newIterator - same as `NewIterator`, data blocks are verified against their checksum if verify is true
+func (s *BasicSSTable) newIterator(verify bool) *sstableIterator {
+	return &sstableIterator{s: s, verify: verify}
+}
+
+// sstableIterator - iterates through records of a sstable file block by block. Blocks read by the iterator
+// don't go into the block cache since they are usually read only once (e.g. during compaction)
+type sstableIterator struct {
+	s   *BasicSSTable
+	idx *BasicSSTableIndex
+	// partIdx, part - index of the entry in the top-level index that points to the current index partition, and
+	// the partition. part is idx itself if the index isn't partitioned
+	partIdx  int
+	part     *BasicSSTableIndex
+	blockIdx int            // blockIdx - index of the entry in the current index partition that points to the current block
+	block    *blockIterator // block - iterator of the current block
+	verify   bool
+	err      error
+}
+
+// First - moves to the first record of the sstable file
+func (it *sstableIterator) First() {
+	if it.reset() {
+		it.loadBlock(0, 0)
 	}
-	return raw, nil
 }
 
-// Get - returns the value of key specified if exist
-func (s *BasicSSTable) Get(key string) ([]byte, error) {
-	// read data block into memory
-	offset, size, exist := s.idx.GetOffset(key)
-	if !exist {
-		return nil, nil
+// Last - moves to the last record of the sstable file
+func (it *sstableIterator) Last() {
+	if it.reset() {
+		it.loadBlockBackward(it.numPartitions()-1, lastBlock)
 	}
+}
 
-	block, exist := s.rBlockCache[offset]
-	if !exist {
-		buf := make([]byte, size, size)
-		if _, err := s.file.ReadAt(buf, int64(offset)); err != nil {
-			return nil, &SSTableError{
-				Op:  OP_SSTABLE_LOAD_DATABLOCK,
-				Err: err,
-			}
+// Seek - moves to the latest version of the first key that is greater than or equal to key
+func (it *sstableIterator) Seek(key string) {
+	if !it.reset() {
+		return
+	}
+	partIdx := 0
+	if it.idx.partitioned {
+		partIdx = it.idx.seekBlock(key)
+	}
+	if partIdx == it.numPartitions() || !it.loadPartition(partIdx) {
+		return
+	}
+	it.loadBlock(partIdx, it.part.seekBlock(key))
+	if it.Valid() {
+		it.block.seek(key)
+		it.checkBlock()
+		// all the keys of the block are smaller than key
+		if it.err == nil && !it.block.valid() {
+			it.loadBlock(it.partIdx, it.blockIdx+1)
 		}
+	}
+}
 
-		dataBuf, err := ReadDataWithVarintPrefix(bytes.NewReader(buf), buf)
-		if err != nil {
-			return nil, &SSTableError{
-				Op:  OP_SSTABLE_LOAD_DATABLOCK,
-				Err: err,
-			}
-		}
+// reset - clears the position and the error of the iterator, returns false if the index can't be loaded
+func (it *sstableIterator) reset() bool {
+	it.block, it.err = nil, nil
+	if it.idx == nil {
+		it.idx, it.err = it.s.index()
+	}
+	return it.err == nil
+}
 
-		data, err := s.decompress(dataBuf)
-		if err != nil {
-			return nil, &SSTableError{
-				Op:  OP_SSTABLE_LOAD_DATABLOCK,
-				Err: err,
+// numPartitions - returns the number of index partitions, an index that isn't partitioned is a single one
+func (it *sstableIterator) numPartitions() int {
+	if !it.idx.partitioned {
+		return 1
+	}
+	return len(it.idx.entries)
+}
+
+// loadPartition - loads the index partition at partIdx, returns false if it can't be loaded
+func (it *sstableIterator) loadPartition(partIdx int) bool {
+	if it.part != nil && it.partIdx == partIdx {
+		return true
+	}
+	it.partIdx = partIdx
+	if !it.idx.partitioned {
+		it.part = it.idx
+		return true
+	}
+	it.part, it.err = it.s.indexPartition(it.idx.entries[partIdx])
+	return it.err == nil
+}
+
+// lastBlock - passed to `loadBlockBackward` for the last block of an index partition
+const lastBlock = math.MaxInt32
+
+// loadBlock - loads the block at blockIdx of the index partition at partIdx (or the next non-empty one) and
+// moves to its first record
+func (it *sstableIterator) loadBlock(partIdx, blockIdx int) {
+	it.block = nil
+	for ; partIdx < it.numPartitions(); partIdx, blockIdx = partIdx+1, 0 {
+		if !it.loadPartition(partIdx) {
+			return
+		}
+		for it.blockIdx = blockIdx; it.blockIdx < len(it.part.entries); it.blockIdx++ {
+			if it.readBlock() {
+				it.block.first()
+				if it.checkBlock() || it.block.valid() {
+					return
+				}
+			}
+			if it.err != nil {
+				return
 			}
 		}
+	}
+	it.block = nil
+}
 
-		// iterate through data block to find key match
-		block = &pb.SSTableBlock{}
-		if err = proto.Unmarshal(data, block); err != nil {
-			return nil, &SSTableError{
-				Op:  OP_SSTABLE_LOAD_DATABLOCK,
-				Err: err,
+// loadBlockBackward - loads the block at blockIdx of the index partition at partIdx (or the previous non-empty
+// one) and moves to its last record
+func (it *sstableIterator) loadBlockBackward(partIdx, blockIdx int) {
+	it.block = nil
+	for ; partIdx >= 0; partIdx, blockIdx = partIdx-1, lastBlock {
+		if !it.loadPartition(partIdx) {
+			return
+		}
+		if blockIdx >= len(it.part.entries) {
+			blockIdx = len(it.part.entries) - 1
+		}
+		for it.blockIdx = blockIdx; it.blockIdx >= 0; it.blockIdx-- {
+			if it.readBlock() {
+				it.block.last()
+				if it.checkBlock() || it.block.valid() {
+					return
+				}
+			}
+			if it.err != nil {
+				return
 			}
 		}
+	}
+	it.block = nil
+}
 
-		// update reader cache
-		s.rBlockCache[offset] = block
+// readBlock - reads the block at blockIdx, returns false if it can't be read
+func (it *sstableIterator) readBlock() bool {
+	entry := it.part.entries[it.blockIdx]
+	block, err := it.s.readBlock(entry.offset, entry.size, it.verify)
+	if err != nil {
+		it.err = err
+		return false
 	}
+	it.block = block.newIterator()
+	return true
+}
 
-	for _, entry := range block.Data {
-		if entry.Key == key {
-			return entry.Value, nil
-		}
+// checkBlock - records the error if the current block is found malformed, returns true if it is
+func (it *sstableIterator) checkBlock() bool {
+	if it.block.err == "" {
+		return false
 	}
+	it.err = it.s.blockCorruption(it.part.entries[it.blockIdx].offset, it.block.err)
+	return true
+}
 
-	return nil, nil
+// Valid - returns true if the iterator is positioned at a record
+func (it *sstableIterator) Valid() bool {
+	return it.err == nil && it.block != nil && it.block.valid()
+}
+
+// Next - moves to the next record
+func (it *sstableIterator) Next() {
+	it.block.next()
+	if !it.checkBlock() && !it.block.valid() {
+		it.loadBlock(it.partIdx, it.blockIdx+1)
+	}
+}
+
+// Prev - moves to the previous record
+func (it *sstableIterator) Prev() {
+	it.block.prev()
+	if !it.checkBlock() && !it.block.valid() {
+		it.loadBlockBackward(it.partIdx, it.blockIdx-1)
+	}
+}
+
+// Record - returns the current record
+func (it *sstableIterator) Record() *MemtableRecord {
+	return it.block.record()
 }
 
-// GetRange - returns the values of key range specified
-// TODO: (p2) Implement getRange
+// Error - returns the error encountered during iteration, if any
+func (it *sstableIterator) Error() error {
+	return it.err
+}
+
+// GetRange - returns the latest values of key range specified [start, end), deleted keys are skipped
 func (s *BasicSSTable) GetRange(start, end string) ([][]byte, error) {
-	return nil, nil
+	values := make([][]byte, 0)
+	if _, err := s.index(); err != nil {
+		return nil, err
+	}
+	if _, _, exist := s.Index().GetOffsetRange(start, end); !exist {
+		return values, nil
+	}
+
+	var prev *MemtableRecord
+	it := s.NewIterator()
+	for it.Seek(start); it.Valid() && it.Record().Key < end; it.Next() {
+		record := it.Record()
+		// only the first record of a key is the latest version
+		if (prev == nil || prev.Key != record.Key) && !record.Tombstone {
+			values = append(values, record.Value)
+		}
+		prev = record
+	}
+	return values, it.Error()
 }
 
 // NewBasicSSTableIndex - creates a new basic sstable index
 func NewBasicSSTableIndex() *BasicSSTableIndex {
 	return &BasicSSTableIndex{
 		entries: make([]*indexEntry, 0),
-		meta:    make(map[string]*indexEntry),
 	}
 }
 
 // update - if key exists, update an existing index entry. If key is new, it's assumed that the
 // input key is greater than all the existing keys in the index
 func (idx *BasicSSTableIndex) update(startKey, endKey string, offset, size uint64) {
-	entry, ok := idx.meta[startKey]
-	if ok {
+	i := sort.Search(len(idx.entries), func(i int) bool { return idx.entries[i].startKey >= startKey })
+	if i < len(idx.entries) && idx.entries[i].startKey == startKey {
+		entry := idx.entries[i]
 		entry.endKey = endKey
-		entry.offset = uint64(offset)
+		entry.offset = offset
 		entry.size = size
 	} else {
-		newEntry := &indexEntry{
+		idx.entries = append(idx.entries, &indexEntry{
 			startKey: startKey,
 			endKey:   endKey,
 			offset:   offset,
 			size:     size,
-		}
-		idx.entries = append(idx.entries, newEntry)
-		idx.meta[startKey] = newEntry
+		})
 	}
 }
 
+// keyRange - returns the smallest and the largest key covered by the index
+func (idx *BasicSSTableIndex) keyRange() (smallest, largest string) {
+	if len(idx.entries) == 0 {
+		return "", ""
+	}
+	return idx.entries[0].startKey, idx.entries[len(idx.entries)-1].endKey
+}
+
 // GetOffset - get start and end offset (in byte) of data block that contains value for key in the sstable file
 func (idx *BasicSSTableIndex) GetOffset(key string) (offset, size uint64, exist bool) {
-	entry, exist := idx.meta[key]
-	if !exist {
-		for _, entry := range idx.entries {
-			if key >= entry.startKey && key <= entry.endKey {
-				return entry.offset, entry.size, true
-			}
-			// it falls in the middle of two data blocks (bigger than prev's end key, less than cur's start key)
-			if key <= entry.startKey {
-				return 0, 0, false
-			}
-		}
+	i := idx.seekBlock(key)
+	// it falls in the middle of two data blocks (bigger than prev's end key, less than cur's start key)
+	if i == len(idx.entries) || key < idx.entries[i].startKey {
 		return 0, 0, false
 	}
-	return entry.offset, entry.size, exist
+	return idx.entries[i].offset, idx.entries[i].size, true
 }
 
-// GetOffsetRange - get start, end offsets (in byte) of data blocks in the sstable file for the
-// key range specified
-// TODO: (p2) implement GetOffSetRange
+// seekBlock - returns the index of the first entry whose block may contain keys greater than or equal to
+// key, len(entries) if there is none
+func (idx *BasicSSTableIndex) seekBlock(key string) int {
+	return sort.Search(len(idx.entries), func(i int) bool { return idx.entries[i].endKey >= key })
+}
+
+// lastBlockBefore - returns the index of the last entry whose block may contain keys smaller than end, -1 if
+// there is none
+func (idx *BasicSSTableIndex) lastBlockBefore(end string) int {
+	return sort.Search(len(idx.entries), func(i int) bool { return idx.entries[i].startKey >= end }) - 1
+}
+
+// GetOffsetRange - get start, end (non-inclusive) offsets (in byte) of data blocks in the sstable file for the
+// key range specified [start, end)
 func (idx *BasicSSTableIndex) GetOffsetRange(start, end string) (startOffset, endOffset uint64, exist bool) {
-	return 0, 0, false
+	first, last := idx.seekBlock(start), idx.lastBlockBefore(end)
+	if first > last {
+		return 0, 0, false
+	}
+	return idx.entries[first].offset, idx.entries[last].offset + idx.entries[last].size, true
 }
 
 // Serialize - turn the index data structure into bytes that can be stored on disk
@@ -508,7 +1271,10 @@ func (idx *BasicSSTableIndex) Serialize() ([]byte, error) {
 	}
 
 	pbIdx := &pb.SSTableIndex{
-		Data: idxData,
+		Data:         idxData,
+		FilterOffset: idx.filterOffset,
+		FilterSize:   idx.filterSize,
+		Partitioned:  idx.partitioned,
 	}
 
 	data, err := proto.Marshal(pbIdx)
@@ -517,3 +1283,48 @@ func (idx *BasicSSTableIndex) Serialize() ([]byte, error) {
 	}
 	return data, nil
 }
+
+// partitionedSSTableIndex - `SSTableIndex` of an sstable file whose index is partitioned, lookups go through the
+// top-level index first and then the index partition it points to
+type partitionedSSTableIndex struct {
+	s   *BasicSSTable
+	top *BasicSSTableIndex
+}
+
+// GetOffset - get starting offset (in byte) of the block that contians the value for key
+func (idx *partitionedSSTableIndex) GetOffset(key string) (offset, size uint64, exist bool) {
+	offset, size, exist, err := idx.s.findBlock(key)
+	if err != nil {
+		return 0, 0, false
+	}
+	return offset, size, exist
+}
+
+// GetOffsetRange - get start, end (non-inclusive) offsets (in byte) of data blocks in the sstable file for the
+// key range specified [start, end)
+func (idx *partitionedSSTableIndex) GetOffsetRange(start, end string) (startOffset, endOffset uint64, exist bool) {
+	first, last := idx.top.seekBlock(start), idx.top.lastBlockBefore(end)
+	if first > last {
+		return 0, 0, false
+	}
+	firstPartition, err := idx.s.indexPartition(idx.top.entries[first])
+	if err != nil {
+		return 0, 0, false
+	}
+	lastPartition, err := idx.s.indexPartition(idx.top.entries[last])
+	if err != nil {
+		return 0, 0, false
+	}
+
+	firstIdx, lastIdx := firstPartition.seekBlock(start), lastPartition.lastBlockBefore(end)
+	if first == last && firstIdx > lastIdx {
+		return 0, 0, false
+	}
+	firstEntry, lastEntry := firstPartition.entries[firstIdx], lastPartition.entries[lastIdx]
+	return firstEntry.offset, lastEntry.offset + lastEntry.size, true
+}
+
+// Serialize - turn the top-level index into bytes that can be stored on disk
+func (idx *partitionedSSTableIndex) Serialize() ([]byte, error) {
+	return idx.top.Serialize()
+}
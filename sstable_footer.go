@@ -0,0 +1,194 @@
+package dbengine
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/DrakeW/go-db-engine/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// sstableMagic - marks the end of every sstable file that has a footer ("dbengine" in ASCII)
+	sstableMagic uint64 = 0x6462656e67696e65
+	// sstableLegacyFormatVersion - files with a data size header instead of a footer
+	sstableLegacyFormatVersion uint32 = 0
+	// sstableCompressorIDFormatVersion - files with the id of the compressor stored in each data block, data
+	// blocks of earlier versions are always compressed by snappy
+	sstableCompressorIDFormatVersion uint32 = 2
+	// sstableRestartBlockFormatVersion - files with data blocks of prefix compressed keys and restarts (see
+	// `block`), data blocks of earlier versions are serialized protocol buffers
+	sstableRestartBlockFormatVersion uint32 = 3
+	// sstablePartitionedIndexFormatVersion - files whose index may be partitioned
+	sstablePartitionedIndexFormatVersion uint32 = 4
+	// sstableFormatVersion - version of the format written by `BasicSSTable`, readers support every version up
+	// to it
+	sstableFormatVersion uint32 = sstablePartitionedIndexFormatVersion
+	// sstableFooterSize - footer layout (all fixed-size, little endian):
+	// - <index handle><filter handle><properties handle><format version (32-bit)><checksum (32-bit)><magic (64-bit)>
+	// where a block handle is <offset (64-bit)><size (64-bit)>, and the checksum covers everything before it
+	sstableFooterSize = 3*16 + 4 + 4 + 8
+)
+
+// blockHandle - location of a block in the sstable file, size is 0 if there is no such block
+type blockHandle struct {
+	offset uint64
+	size   uint64
+}
+
+// sstableFooter - the fixed-size footer at the end of an sstable file, it locates the blocks that aren't data
+// blocks so a reader can open the file without scanning it
+type sstableFooter struct {
+	index      blockHandle
+	filter     blockHandle
+	properties blockHandle
+	version    uint32
+}
+
+// encode - serializes the footer into its fixed-size layout
+func (f *sstableFooter) encode() []byte {
+	buf := make([]byte, sstableFooterSize)
+	for i, handle := range []blockHandle{f.index, f.filter, f.properties} {
+		binary.LittleEndian.PutUint64(buf[i*16:], handle.offset)
+		binary.LittleEndian.PutUint64(buf[i*16+8:], handle.size)
+	}
+	binary.LittleEndian.PutUint32(buf[48:], f.version)
+	binary.LittleEndian.PutUint32(buf[52:], checksum(buf[:52]))
+	binary.LittleEndian.PutUint64(buf[56:], sstableMagic)
+	return buf
+}
+
+// readSSTableFooter - reads the footer at the end of the sstable file. Returns a nil footer if the file has no
+// magic number at the end, which is the case for files written in the legacy format.
+func readSSTableFooter(f *os.File) (*sstableFooter, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < sstableFooterSize {
+		return nil, nil
+	}
+
+	offset := info.Size() - sstableFooterSize
+	buf := make([]byte, sstableFooterSize)
+	if _, err := f.ReadAt(buf, offset); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint64(buf[56:]) != sstableMagic {
+		return nil, nil
+	}
+
+	corrupted := func(reason string) error {
+		return &CorruptionError{File: f.Name(), Offset: offset, Reason: "footer - " + reason}
+	}
+	if binary.LittleEndian.Uint32(buf[52:]) != checksum(buf[:52]) {
+		return nil, corrupted("checksum mismatch")
+	}
+
+	footer := &sstableFooter{version: binary.LittleEndian.Uint32(buf[48:])}
+	for i, handle := range []*blockHandle{&footer.index, &footer.filter, &footer.properties} {
+		handle.offset = binary.LittleEndian.Uint64(buf[i*16:])
+		handle.size = binary.LittleEndian.Uint64(buf[i*16+8:])
+		if handle.offset+handle.size > uint64(offset) {
+			return nil, corrupted("block handle out of range")
+		}
+	}
+	if footer.version > sstableFormatVersion {
+		return nil, corrupted(fmt.Sprintf("unsupported format version %d", footer.version))
+	}
+	return footer, nil
+}
+
+// readLegacyIndexOffset - returns where the index starts in a file written in the legacy format, which begins
+// with a data size header of `binary.MaxVarintLen64` bytes. Anything else, e.g. a truncated or foreign file,
+// can't have a valid header and is reported as corrupted.
+func readLegacyIndexOffset(f *os.File) (uint64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	corrupted := &CorruptionError{File: f.Name(), Offset: 0, Reason: "neither a footer nor a data size header found"}
+	header := make([]byte, binary.MaxVarintLen64)
+	if _, err := f.ReadAt(header, 0); err == io.EOF {
+		return 0, corrupted
+	} else if err != nil {
+		return 0, err
+	}
+
+	// the header is zero-padded after the size
+	dataSize, n := binary.Uvarint(header)
+	if n <= 0 {
+		return 0, corrupted
+	}
+	for _, b := range header[n:] {
+		if b != 0 {
+			return 0, corrupted
+		}
+	}
+	idxOffset := dataSize + binary.MaxVarintLen64
+	if idxOffset >= uint64(info.Size()) {
+		return 0, corrupted
+	}
+	return idxOffset, nil
+}
+
+// SSTableProperties - properties of an sstable file recorded when it's written. Files written in the legacy
+// format have no properties other than their format version.
+type SSTableProperties struct {
+	FormatVersion uint32
+	NumRecords    uint64
+	NumDeletions  uint64 // NumDeletions - number of tombstone records
+	NumDataBlocks uint64
+	DataSize      uint64 // DataSize - total size of data blocks (in bytes)
+	SmallestKey   string
+	LargestKey    string
+	// Compression - name of the compressor the file is written with, blocks that don't shrink enough are stored
+	// uncompressed regardless
+	Compression string
+}
+
+// serialize - turns the properties into bytes stored in the properties block
+func (p *SSTableProperties) serialize() ([]byte, error) {
+	return proto.Marshal(&pb.SSTableProperties{
+		NumRecords:    p.NumRecords,
+		NumDeletions:  p.NumDeletions,
+		NumDataBlocks: p.NumDataBlocks,
+		DataSize:      p.DataSize,
+		SmallestKey:   p.SmallestKey,
+		LargestKey:    p.LargestKey,
+		Compression:   p.Compression,
+	})
+}
+
+// loadPropertiesFromFile - loads the properties block of the handle from the sstable file
+func loadPropertiesFromFile(f *os.File, handle blockHandle, version uint32) (*SSTableProperties, error) {
+	buf := make([]byte, handle.size)
+	if _, err := f.ReadAt(buf, int64(handle.offset)); err != nil {
+		return nil, err
+	}
+	corrupted := func(reason string) error {
+		return &CorruptionError{File: f.Name(), Offset: int64(handle.offset), Reason: "properties - " + reason}
+	}
+
+	data, reason := readChecksummedData(buf, true, true)
+	if reason != "" {
+		return nil, corrupted(reason)
+	}
+	props := &pb.SSTableProperties{}
+	if err := proto.Unmarshal(data, props); err != nil {
+		return nil, corrupted(err.Error())
+	}
+	return &SSTableProperties{
+		FormatVersion: version,
+		NumRecords:    props.NumRecords,
+		NumDeletions:  props.NumDeletions,
+		NumDataBlocks: props.NumDataBlocks,
+		DataSize:      props.DataSize,
+		SmallestKey:   props.SmallestKey,
+		LargestKey:    props.LargestKey,
+		Compression:   props.Compression,
+	}, nil
+}
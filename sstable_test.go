@@ -1,14 +1,22 @@
 package dbengine
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
+
+	"github.com/DrakeW/go-db-engine/pb"
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/proto"
 )
 
 func Test_NewSSTableShouldCreateNewFileWithUniqueTimestamp(t *testing.T) {
-	s, _ := NewBasicSSTableWriter(os.TempDir(), 10)
+	s, _ := NewBasicSSTableWriter(os.TempDir(), 10, 0, SnappyCompression, 0)
 
 	if _, err := os.Stat(s.File()); os.IsNotExist(err) {
 		t.Errorf("file at path %s does not exist", s.File())
@@ -16,7 +24,7 @@ func Test_NewSSTableShouldCreateNewFileWithUniqueTimestamp(t *testing.T) {
 }
 
 func Test_DumpShouldWriteBothDataAndIndex(t *testing.T) {
-	s, _ := NewBasicSSTableWriter(os.TempDir(), 50)
+	s, _ := NewBasicSSTableWriter(os.TempDir(), 50, 0, SnappyCompression, 0)
 	fmt.Println(s.File())
 
 	memtable := getTestMemtable(t, 100)
@@ -25,23 +33,23 @@ func Test_DumpShouldWriteBothDataAndIndex(t *testing.T) {
 	// verify content
 	sr, _ := NewBasicSSTableReader(s.File())
 
-	value, err := sr.Get("key-055")
+	record, err := sr.Get("key-055", maxSequence)
 	if err != nil {
 		t.Error(err.Error())
 	}
-	if string(value) != "value-055" {
-		t.Errorf("Got %s instead", string(value))
+	if record == nil || string(record.Value) != "value-055" {
+		t.Errorf("Got %v instead", record)
 	}
 
 	idx := sr.Index()
 	offset, size, exist := idx.GetOffset("key-055")
-	if !exist || offset != 721 || size != 54 {
+	if !exist || offset != 1016 || size != 56 {
 		t.Error("index didn't get written correctly")
 	}
 }
 
 func Test_DumpShouldWriteDataAndIndexEvenIfTotalDataToWriteIsLessThanConfiguredBlockSize(t *testing.T) {
-	s, _ := NewBasicSSTableWriter(os.TempDir(), 1024*400)
+	s, _ := NewBasicSSTableWriter(os.TempDir(), 1024*400, 0, SnappyCompression, 0)
 	fmt.Println(s.File())
 
 	memtable := getTestMemtable(t, 100)
@@ -50,24 +58,89 @@ func Test_DumpShouldWriteDataAndIndexEvenIfTotalDataToWriteIsLessThanConfiguredB
 	// verify content
 	sr, _ := NewBasicSSTableReader(s.File())
 
-	value, err := sr.Get("key-055")
+	record, err := sr.Get("key-055", maxSequence)
 	if err != nil {
 		t.Error(err.Error())
 	}
-	if string(value) != "value-055" {
-		t.Errorf("Got %s instead", string(value))
+	if record == nil || string(record.Value) != "value-055" {
+		t.Errorf("Got %v instead", record)
 	}
 
 	idx := sr.Index()
 	offset, size, exist := idx.GetOffset("key-055")
-	if !exist || offset != binary.MaxVarintLen64 || size != 825 {
+	if !exist || offset != 0 || size != 946 {
 		t.Error("index didn't get written correctly")
 	}
 }
 
+func Test_DumpShouldKeepTombstoneRecords(t *testing.T) {
+	s, _ := NewBasicSSTableWriter(os.TempDir(), 50, 0, SnappyCompression, 0)
+
+	memtable := getTestMemtable(t, 100)
+	memtable.Delete("key-055", 101, nil)
+	s.Dump(memtable)
+
+	sr, _ := NewBasicSSTableReader(s.File())
+
+	record, err := sr.Get("key-055", maxSequence)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if record == nil || !record.Tombstone || len(record.Value) != 0 {
+		t.Errorf("Expected a tombstone record, got %v instead", record)
+	}
+}
+
+func Test_GetRangeShouldReturnValuesAcrossDataBlocks(t *testing.T) {
+	s, _ := NewBasicSSTableWriter(os.TempDir(), 50, 0, SnappyCompression, 0)
+	memtable := getTestMemtable(t, 100)
+	memtable.Delete("key-050", 101, nil)
+	s.Dump(memtable)
+
+	sr, _ := NewBasicSSTableReader(s.File())
+	defer sr.Close()
+
+	values, err := sr.GetRange("key-045", "key-055")
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(values) != 9 || string(values[0]) != "value-045" || string(values[8]) != "value-054" {
+		t.Errorf("Unexpected values returned - %q", values)
+	}
+
+	if values, _ := sr.GetRange("key-100", "key-200"); len(values) != 0 {
+		t.Errorf("Range out of the sstable should return no values, got %q", values)
+	}
+}
+
+func Test_IteratorShouldMoveInBothDirectionsAcrossDataBlocks(t *testing.T) {
+	s, _ := NewBasicSSTableWriter(os.TempDir(), 50, 0, SnappyCompression, 0)
+	s.Dump(getTestMemtable(t, 100))
+
+	sr, _ := NewBasicSSTableReader(s.File())
+	defer sr.Close()
+
+	it := sr.NewIterator()
+	count := 0
+	for it.Last(); it.Valid(); it.Prev() {
+		if expected := fmt.Sprintf("key-%03d", 99-count); it.Record().Key != expected {
+			t.Fatalf("Expected %s, got %s", expected, it.Record().Key)
+		}
+		count++
+	}
+	if count != 100 || it.Error() != nil {
+		t.Errorf("Expected 100 records, got %d - Error: %v", count, it.Error())
+	}
+
+	it.Seek("key-0555")
+	if !it.Valid() || it.Record().Key != "key-056" {
+		t.Errorf("Seek should move to the next key")
+	}
+}
+
 func Benchmark_DumpWith4KBDataBlock(b *testing.B) {
 	m := getTestMemtable(b, b.N)
-	s, _ := NewBasicSSTableWriter(os.TempDir(), 1024*4)
+	s, _ := NewBasicSSTableWriter(os.TempDir(), 1024*4, 0, SnappyCompression, 0)
 
 	s.Dump(m)
 
@@ -81,7 +154,7 @@ func Benchmark_Get(b *testing.B) {
 	r, _ := NewBasicSSTableReader(s)
 
 	for i := 0; i < b.N; i++ {
-		val, err := r.Get(fmt.Sprintf("key-%03d", i))
+		val, err := r.Get(fmt.Sprintf("key-%03d", i), maxSequence)
 		if val == nil || err != nil {
 			b.Error("value couldn't be retrieved")
 		}
@@ -96,7 +169,7 @@ func getBenchmarkSSTableFile(b *testing.B, numberOfEntries int) string {
 	b.Helper()
 
 	m := getTestMemtable(b, numberOfEntries)
-	s, _ := NewBasicSSTableWriter(os.TempDir(), 1024*4)
+	s, _ := NewBasicSSTableWriter(os.TempDir(), 1024*4, 0, SnappyCompression, 0)
 	s.Dump(m)
 
 	return s.File()
@@ -110,6 +183,8 @@ func getTestMemtable(tb testing.TB, numberOfItems int) MemTable {
 		m.Write(
 			fmt.Sprintf("key-%03d", i),
 			[]byte(fmt.Sprintf("value-%03d", i)),
+			uint64(i+1),
+			nil,
 		)
 	}
 	return m
@@ -130,9 +205,10 @@ func Test_IndexUpdateShouldChangeExistingEntryIfExist(t *testing.T) {
 func Test_IndexUpdateShouldAddNewEntryIfNotExist(t *testing.T) {
 	idx := getTestIndex(t)
 
-	idx.update("key-150", "key-200", 1500, 2000)
+	// new keys have to be greater than all the existing ones ("key-95" is the greatest start key)
+	idx.update("key-960", "key-980", 1500, 2000)
 
-	offset, size, exist := idx.GetOffset("key-150")
+	offset, size, exist := idx.GetOffset("key-960")
 
 	if !exist || offset != 1500 || size != 2000 {
 		t.Error("entry didn't get added")
@@ -191,3 +267,378 @@ func getTestIndex(t *testing.T) *BasicSSTableIndex {
 	}
 	return idx
 }
+
+func Test_IndexGetOffsetRangeShouldCoverAllOverlappingDataBlocks(t *testing.T) {
+	idx := getTestIndex(t)
+
+	startOffset, endOffset, exist := idx.GetOffsetRange("key-22", "key-36")
+	if !exist || startOffset != 20 || endOffset != 130 {
+		t.Errorf("Wrong offsets returned - %d, %d", startOffset, endOffset)
+	}
+}
+
+func Test_IndexGetOffsetRangeShouldReturnNotExistIfRangeIsInBetweenTwoDataBlocks(t *testing.T) {
+	idx := getTestIndex(t)
+
+	if _, _, exist := idx.GetOffsetRange("key-21", "key-25"); exist {
+		t.Error("Range shouldn't exist")
+	}
+}
+
+func Test_sstablePartitionedIndexShouldLocateSameBlocksAsSingleIndex(t *testing.T) {
+	single, _ := NewBasicSSTableWriter(os.TempDir(), 50, 0, SnappyCompression, 0)
+	single.Dump(getTestMemtable(t, 100))
+	partitioned, _ := NewBasicSSTableWriter(os.TempDir(), 50, 0, SnappyCompression, 100)
+	partitioned.Dump(getTestMemtable(t, 100))
+
+	sr, _ := NewBasicSSTableReader(single.File())
+	defer sr.Close()
+	pr, err := newCachedSSTableReader(partitioned.File(), 1, newBlockCache(1024*1024), true, true)
+	if err != nil {
+		t.Fatalf("Failed to open sstable - Error: %s", err.Error())
+	}
+	defer pr.Close()
+	if !pr.idx.partitioned || len(pr.idx.entries) < 2 {
+		t.Fatalf("Expected index to be split into partitions, got %d entries", len(pr.idx.entries))
+	}
+
+	// data blocks are written the same way, only the index differs
+	for _, key := range []string{"key-000", "key-042", "key-0425", "key-099", "key-100", "a"} {
+		offset, size, exist := sr.Index().GetOffset(key)
+		pOffset, pSize, pExist := pr.Index().GetOffset(key)
+		if offset != pOffset || size != pSize || exist != pExist {
+			t.Errorf("Expected block (%d, %d, %v) for key %s, got (%d, %d, %v)",
+				offset, size, exist, key, pOffset, pSize, pExist)
+		}
+	}
+	for _, r := range [][2]string{{"key-000", "key-100"}, {"key-010", "key-060"}, {"key-042", "key-043"}, {"key-100", "key-200"}} {
+		start, end, exist := sr.Index().GetOffsetRange(r[0], r[1])
+		pStart, pEnd, pExist := pr.Index().GetOffsetRange(r[0], r[1])
+		if start != pStart || end != pEnd || exist != pExist {
+			t.Errorf("Expected range (%d, %d, %v) for [%s, %s), got (%d, %d, %v)",
+				start, end, exist, r[0], r[1], pStart, pEnd, pExist)
+		}
+	}
+}
+
+func Test_sstablePartitionedIndexShouldServeReadsAndIteration(t *testing.T) {
+	s, _ := NewBasicSSTableWriter(os.TempDir(), 50, 10, SnappyCompression, 100)
+	s.Dump(getTestMemtable(t, 100))
+
+	cache := newBlockCache(1024 * 1024)
+	sr, err := newCachedSSTableReader(s.File(), 1, cache, true, true)
+	if err != nil {
+		t.Fatalf("Failed to open sstable - Error: %s", err.Error())
+	}
+	defer sr.Close()
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		record, err := sr.Get(key, maxSequence)
+		if err != nil || record == nil || string(record.Value) != fmt.Sprintf("value-%03d", i) {
+			t.Errorf("Failed to read key %s, got %v - Error: %v", key, record, err)
+		}
+	}
+	// partitions are read through the block cache
+	if partition, ok := cache.get(sr.blockCacheKey(sr.idx.entries[0].offset)); !ok {
+		t.Error("Expected index partition to be cached")
+	} else if _, ok := partition.(*BasicSSTableIndex); !ok {
+		t.Errorf("Expected index partition in the cache, got %T", partition)
+	}
+
+	it := sr.NewIterator()
+	i := 0
+	for it.First(); it.Valid(); it.Next() {
+		if key := fmt.Sprintf("key-%03d", i); it.Record().Key != key {
+			t.Errorf("Expected key %s, got %s", key, it.Record().Key)
+		}
+		i++
+	}
+	if it.Error() != nil || i != 100 {
+		t.Errorf("Expected 100 records, got %d - Error: %v", i, it.Error())
+	}
+	for it.Last(); it.Valid(); it.Prev() {
+		i--
+		if key := fmt.Sprintf("key-%03d", i); it.Record().Key != key {
+			t.Errorf("Expected key %s, got %s", key, it.Record().Key)
+		}
+	}
+	if it.Error() != nil || i != 0 {
+		t.Errorf("Expected to iterate back to the first record, stopped at %d - Error: %v", i, it.Error())
+	}
+
+	for _, seek := range [][2]string{{"key-055", "key-055"}, {"key-0555", "key-056"}, {"a", "key-000"}} {
+		if it.Seek(seek[0]); !it.Valid() || it.Record().Key != seek[1] {
+			t.Errorf("Expected seeking %s to land on %s", seek[0], seek[1])
+		}
+	}
+	if it.Seek("key-100"); it.Valid() {
+		t.Errorf("Expected seeking past the last key to be invalid, got %s", it.Record().Key)
+	}
+
+	values, err := sr.GetRange("key-010", "key-020")
+	if err != nil || len(values) != 10 {
+		t.Errorf("Expected 10 values, got %d - Error: %v", len(values), err)
+	}
+	if err := sr.verifyChecksums(); err != nil {
+		t.Errorf("Expected checksums to match - Error: %s", err.Error())
+	}
+}
+
+func Test_sstableShouldSkipDataBlocksForKeysNotInBloomFilter(t *testing.T) {
+	s, _ := NewBasicSSTableWriter(os.TempDir(), 50, 10, SnappyCompression, 0)
+	s.Dump(getTestMemtable(t, 100))
+
+	cache := newBlockCache(1024 * 1024)
+	reader, err := newCachedSSTableReader(s.File(), 1, cache, true, true)
+	if err != nil {
+		t.Fatalf("Failed to open sstable - Error: %s", err.Error())
+	}
+	defer reader.Close()
+	sr := reader
+	if sr.filter == nil {
+		t.Fatal("Bloom filter should be loaded by reader")
+	}
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		record, _ := sr.Get(key, maxSequence)
+		if record == nil || string(record.Value) != fmt.Sprintf("value-%03d", i) {
+			t.Errorf("Key %s should be found with bloom filter, got %v", key, record)
+		}
+	}
+
+	before := cache.stats()
+	for i := 0; i < 100; i++ {
+		// keys within the key range of the data blocks
+		key := fmt.Sprintf("key-%03d-missing", i)
+		if record, _ := sr.Get(key, maxSequence); record != nil {
+			t.Errorf("Missing key %s should not be found, got %v", key, record)
+		}
+	}
+	// allow a few false positives
+	after := cache.stats()
+	if lookups := after.Hits + after.Misses - before.Hits - before.Misses; lookups > 5 {
+		t.Errorf("Expected bloom filter to skip reading data blocks for missing keys, read %d blocks", lookups)
+	}
+
+	it := sr.NewIterator()
+	count := 0
+	for it.First(); it.Valid(); it.Next() {
+		count++
+	}
+	if count != 100 {
+		t.Errorf("Bloom filter should not be read as data blocks, expected 100 records, got %d", count)
+	}
+}
+
+func Test_sstableShouldReturnCorruptionErrorForCorruptedDataBlock(t *testing.T) {
+	s, _ := NewBasicSSTableWriter(os.TempDir(), 50, 0, SnappyCompression, 0)
+	s.Dump(getTestMemtable(t, 100))
+
+	sr, _ := NewBasicSSTableReader(s.File())
+	offset, size, _ := sr.Index().GetOffset("key-055")
+	sr.Close()
+	corruptFile(t, s.File(), int64(offset+size/2))
+
+	sr, err := NewBasicSSTableReader(s.File())
+	if err != nil {
+		t.Fatalf("Corrupted data block shouldn't fail opening the file - Error: %s", err.Error())
+	}
+	defer sr.Close()
+
+	_, err = sr.Get("key-055", maxSequence)
+	if !errors.Is(err, ErrCorruption) || !strings.Contains(err.Error(), s.File()) {
+		t.Errorf("Expected corruption error naming the file, got %v", err)
+	}
+	if record, err := sr.Get("key-005", maxSequence); err != nil || record == nil {
+		t.Errorf("Other data blocks should still be readable, got %v - Error: %v", record, err)
+	}
+
+	it := sr.NewIterator()
+	for it.First(); it.Valid(); it.Next() {
+	}
+	if !errors.Is(it.Error(), ErrCorruption) {
+		t.Errorf("Expected iterator to stop with corruption error, got %v", it.Error())
+	}
+}
+
+func Test_sstableShouldReturnCorruptionErrorForDataBlockMissingChecksum(t *testing.T) {
+	s, _ := NewBasicSSTableWriter(os.TempDir(), 50, 0, SnappyCompression, 0)
+	s.Dump(getTestMemtable(t, 100))
+
+	sr, err := NewBasicSSTableReader(s.File())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sr.Close()
+	offset, size, _ := sr.Index().GetOffset("key-055")
+
+	// the block without the checksum at its end is a valid block of a file written before checksums existed
+	_, err = sr.(*BasicSSTable).readBlock(offset, size-checksumSize, true)
+	if !errors.Is(err, ErrCorruption) || !strings.Contains(err.Error(), "missing checksum") {
+		t.Errorf("Expected corruption error for a data block missing its checksum, got %v", err)
+	}
+	if _, err := sr.(*BasicSSTable).readBlock(offset, size, true); err != nil {
+		t.Errorf("Expected the block with its checksum to be read - Error: %s", err.Error())
+	}
+}
+
+func Test_sstableShouldVerifyCachedBlockReadWithoutVerification(t *testing.T) {
+	s, _ := NewBasicSSTableWriter(os.TempDir(), 50, 0, SnappyCompression, 0)
+	s.Dump(getTestMemtable(t, 100))
+
+	sr, _ := NewBasicSSTableReader(s.File())
+	offset, size, _ := sr.Index().GetOffset("key-055")
+	sr.Close()
+	// only the checksum is damaged, the block itself can still be read
+	corruptFile(t, s.File(), int64(offset+size-1))
+
+	cached, err := newCachedSSTableReader(s.File(), 1, newBlockCache(1024*1024), true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cached.Close()
+
+	if record, err := cached.get("key-055", maxSequence, false); err != nil || record == nil {
+		t.Fatalf("Expected block to be read without verification, got %v - Error: %v", record, err)
+	}
+	if _, err := cached.get("key-055", maxSequence, true); !errors.Is(err, ErrCorruption) {
+		t.Errorf("Expected cached block to be verified when asked for, got %v", err)
+	}
+}
+
+func Test_sstableReaderShouldFailOnCorruptedIndex(t *testing.T) {
+	s, _ := NewBasicSSTableWriter(os.TempDir(), 50, 0, SnappyCompression, 0)
+	s.Dump(getTestMemtable(t, 100))
+
+	// the index is at the end of the file, followed by its checksum
+	info, _ := os.Stat(s.File())
+	corruptFile(t, s.File(), info.Size()-checksumSize-1)
+
+	if _, err := NewBasicSSTableReader(s.File()); !errors.Is(err, ErrCorruption) {
+		t.Errorf("Expected corruption error, got %v", err)
+	}
+}
+
+func Test_sstableShouldEndWithFooterAndRecordProperties(t *testing.T) {
+	s, _ := NewBasicSSTableWriter(os.TempDir(), 50, 10, SnappyCompression, 0)
+	memtable := getTestMemtable(t, 100)
+	memtable.Delete("key-055", 101, nil)
+	s.Dump(memtable)
+
+	raw, _ := ioutil.ReadFile(s.File())
+	if magic := binary.LittleEndian.Uint64(raw[len(raw)-8:]); magic != sstableMagic {
+		t.Fatalf("File should end with magic number, got %x", magic)
+	}
+
+	sr, err := NewBasicSSTableReader(s.File())
+	if err != nil {
+		t.Fatalf("Failed to open sstable - Error: %s", err.Error())
+	}
+	defer sr.Close()
+
+	props := sr.Properties()
+	if props.FormatVersion != sstableFormatVersion || props.NumRecords != 101 || props.NumDeletions != 1 ||
+		props.SmallestKey != "key-000" || props.LargestKey != "key-099" {
+		t.Errorf("Unexpected properties - %+v", props)
+	}
+	if props.NumDataBlocks != 34 || props.DataSize == 0 || props.DataSize >= uint64(len(raw)) {
+		t.Errorf("Unexpected data block properties - %+v", props)
+	}
+}
+
+func Test_sstableReaderShouldReadLegacyFormat(t *testing.T) {
+	file := writeLegacySSTable(t, getTestMemtable(t, 100))
+
+	sr, err := NewBasicSSTableReader(file)
+	if err != nil {
+		t.Fatalf("Failed to open legacy sstable - Error: %s", err.Error())
+	}
+	defer sr.Close()
+
+	if version := sr.Properties().FormatVersion; version != sstableLegacyFormatVersion {
+		t.Errorf("Expected legacy format version, got %d", version)
+	}
+	if sr.(*BasicSSTable).filter == nil {
+		t.Error("Bloom filter recorded in the legacy index should be loaded")
+	}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		record, err := sr.Get(key, maxSequence)
+		if err != nil || record == nil || string(record.Value) != fmt.Sprintf("value-%03d", i) {
+			t.Errorf("Key %s should be found in legacy sstable, got %v - Error: %v", key, record, err)
+		}
+	}
+
+	it := sr.NewIterator()
+	count := 0
+	for it.First(); it.Valid(); it.Next() {
+		count++
+	}
+	if count != 100 || it.Error() != nil {
+		t.Errorf("Expected 100 records, got %d - Error: %v", count, it.Error())
+	}
+}
+
+func Test_sstableReaderShouldRejectTruncatedOrForeignFile(t *testing.T) {
+	s, _ := NewBasicSSTableWriter(os.TempDir(), 50, 10, SnappyCompression, 0)
+	s.Dump(getTestMemtable(t, 100))
+
+	info, _ := os.Stat(s.File())
+	os.Truncate(s.File(), info.Size()-10)
+	if _, err := NewBasicSSTableReader(s.File()); !errors.Is(err, ErrCorruption) {
+		t.Errorf("Expected corruption error for truncated file, got %v", err)
+	}
+
+	foreign, _ := ioutil.TempFile(os.TempDir(), "foreign")
+	foreign.WriteString(strings.Repeat("not an sstable file", 10))
+	foreign.Close()
+	if _, err := NewBasicSSTableReader(foreign.Name()); !errors.Is(err, ErrCorruption) {
+		t.Errorf("Expected corruption error for foreign file, got %v", err)
+	}
+}
+
+// writeLegacySSTable - writes the memtable into an sstable file in the legacy format, which has a data size
+// header instead of a footer and no checksums, with one data block per key
+func writeLegacySSTable(t *testing.T, m MemTable) string {
+	t.Helper()
+
+	data := &bytes.Buffer{}
+	idx := &pb.SSTableIndex{}
+	filter := newBloomFilterBuilder(10)
+	for _, record := range m.GetAll() {
+		block, _ := proto.Marshal(&pb.SSTableBlock{Data: []*pb.SSTableKeyValue{{
+			Key:   record.Key,
+			Value: record.Value,
+			Type:  recordTypeOf(record),
+			Seq:   record.Seq,
+		}}})
+		offset := binary.MaxVarintLen64 + data.Len()
+		written, _ := WriteDataWithVarintSizePrefix(data, snappy.Encode(nil, block))
+		idx.Data = append(idx.Data, &pb.SSTableIndexEntry{
+			StartKey: record.Key,
+			EndKey:   record.Key,
+			Offset:   uint64(offset),
+			Size:     uint64(written),
+		})
+		filter.add(record.Key)
+	}
+	idx.FilterOffset = uint64(binary.MaxVarintLen64 + data.Len())
+	written, _ := WriteDataWithVarintSizePrefix(data, filter.build())
+	idx.FilterSize = uint64(written)
+
+	header := make([]byte, binary.MaxVarintLen64)
+	binary.PutUvarint(header, uint64(data.Len()))
+	idxData, _ := proto.Marshal(idx)
+	WriteDataWithVarintSizePrefix(data, idxData)
+
+	f, err := newSSTableFile(os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.Write(header)
+	f.Write(data.Bytes())
+	return f.Name()
+}
@@ -0,0 +1,143 @@
+package dbengine
+
+import (
+	"container/list"
+	"path/filepath"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// tableCache - keeps readers of recently used sstable files open, so that reading a file doesn't have to open
+// it and load its index (and bloom filter) every time. The number of open files is bounded by capacity, the
+// least recently used reader is closed when a new file has to be opened.
+//
+// A reader is shared by everyone reading the file at the same time, so it's only closed once it's evicted and
+// no one is using it anymore.
+type tableCache struct {
+	lock       sync.Mutex
+	sstableDir string
+	capacity   int
+	// blocks, pinIndexAndFilter - block cache the readers read through, and if they pin index and filter in it
+	blocks            *blockCache
+	pinIndexAndFilter bool
+	// verifyChecksums - if the readers verify data blocks against their checksum by default
+	verifyChecksums bool
+	// lru - tables that are open, from the most recently used to the least
+	lru    *list.List
+	tables map[uint64]*cachedTable
+}
+
+// cachedTable - an open sstable reader handed out by the table cache, it has to be released after use
+type cachedTable struct {
+	number  uint64
+	reader  *BasicSSTable
+	refs    int
+	elem    *list.Element
+	evicted bool
+}
+
+func newTableCache(
+	sstableDir string, capacity int, blocks *blockCache, pinIndexAndFilter, verifyChecksums bool,
+) *tableCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &tableCache{
+		sstableDir:        sstableDir,
+		capacity:          capacity,
+		blocks:            blocks,
+		pinIndexAndFilter: pinIndexAndFilter,
+		verifyChecksums:   verifyChecksums,
+		lru:               list.New(),
+		tables:            make(map[uint64]*cachedTable),
+	}
+}
+
+// acquire - returns the reader of the sstable file, opens the file if it isn't open yet
+func (tc *tableCache) acquire(meta *SSTableFileMetadata) (*cachedTable, error) {
+	tc.lock.Lock()
+	if t, ok := tc.tables[meta.number]; ok {
+		t.refs++
+		tc.lru.MoveToFront(t.elem)
+		tc.lock.Unlock()
+		return t, nil
+	}
+	tc.lock.Unlock()
+
+	// the file is opened without holding the lock so that reads of other files aren't blocked
+	reader, err := newCachedSSTableReader(
+		filepath.Join(tc.sstableDir, meta.filename), meta.number, tc.blocks, tc.pinIndexAndFilter, tc.verifyChecksums,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	// someone else might have opened the same file in the meantime
+	if t, ok := tc.tables[meta.number]; ok {
+		reader.Close()
+		t.refs++
+		tc.lru.MoveToFront(t.elem)
+		return t, nil
+	}
+
+	t := &cachedTable{number: meta.number, reader: reader, refs: 1}
+	t.elem = tc.lru.PushFront(t)
+	tc.tables[meta.number] = t
+	for tc.lru.Len() > tc.capacity {
+		tc.removeLocked(tc.lru.Back().Value.(*cachedTable))
+	}
+	return t, nil
+}
+
+// release - releases the table acquired, the reader is closed if it has been evicted in the meantime
+func (tc *tableCache) release(t *cachedTable) {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	t.refs--
+	if t.refs == 0 && t.evicted {
+		tc.closeReader(t)
+	}
+}
+
+// evict - closes the reader of the sstable file with number if it's open, it has to be called before the file
+// is deleted
+func (tc *tableCache) evict(number uint64) {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	if t, ok := tc.tables[number]; ok {
+		tc.removeLocked(t)
+	}
+}
+
+// removeLocked - removes the table from the cache and closes its reader once it's no longer in use. lock
+// must be held
+func (tc *tableCache) removeLocked(t *cachedTable) {
+	tc.lru.Remove(t.elem)
+	delete(tc.tables, t.number)
+	t.evicted = true
+	if t.refs == 0 {
+		tc.closeReader(t)
+	}
+}
+
+func (tc *tableCache) closeReader(t *cachedTable) {
+	if err := t.reader.Close(); err != nil {
+		log.Warnf("Failed to close sstable file %s - Error: %s", t.reader.File(), err.Error())
+	}
+}
+
+// close - closes the readers of all the open files, the ones still in use are closed once released
+func (tc *tableCache) close() {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	for _, t := range tc.tables {
+		tc.removeLocked(t)
+	}
+}
@@ -0,0 +1,114 @@
+package dbengine
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// getTestTableFiles - writes n sstable files into dir and returns their metadata
+func getTestTableFiles(t *testing.T, dir string, n int) []*SSTableFileMetadata {
+	t.Helper()
+
+	files := make([]*SSTableFileMetadata, n)
+	for i := 0; i < n; i++ {
+		writer, err := NewBasicSSTableWriter(dir, 50, 10, SnappyCompression, 0)
+		if err != nil {
+			t.Fatalf("Failed to create sstable - Error: %s", err.Error())
+		}
+		if err := writer.Dump(getTestMemtable(t, 10)); err != nil {
+			t.Fatalf("Failed to write sstable - Error: %s", err.Error())
+		}
+		files[i] = &SSTableFileMetadata{number: uint64(i + 1), filename: filepath.Base(writer.File())}
+	}
+	return files
+}
+
+func Test_tableCacheShouldShareReadersAndEvictLeastRecentlyUsed(t *testing.T) {
+	dir := setupTestDBDir(t)
+	files := getTestTableFiles(t, dir, 3)
+	tc := newTableCache(dir, 2, nil, true, true)
+
+	t1, _ := tc.acquire(files[0])
+	t1Again, _ := tc.acquire(files[0])
+	if t1 != t1Again {
+		t.Error("Readers of the same file should be shared")
+	}
+	tc.release(t1)
+	tc.release(t1Again)
+
+	t2, _ := tc.acquire(files[1])
+	tc.release(t2)
+	// file 0 is the least recently used once file 2 is opened
+	t3, _ := tc.acquire(files[2])
+	tc.release(t3)
+
+	if len(tc.tables) != 2 || tc.lru.Len() != 2 {
+		t.Errorf("Expected 2 open files, got %d", len(tc.tables))
+	}
+	if _, ok := tc.tables[files[0].number]; ok {
+		t.Error("Least recently used file should be evicted")
+	}
+	if !t1.evicted {
+		t.Error("Evicted table should be marked as evicted")
+	}
+	if _, err := t1.reader.file.Stat(); err == nil {
+		t.Error("Reader of evicted file should be closed")
+	}
+}
+
+func Test_tableCacheShouldCloseEvictedReaderOnlyAfterRelease(t *testing.T) {
+	dir := setupTestDBDir(t)
+	files := getTestTableFiles(t, dir, 1)
+	tc := newTableCache(dir, 2, nil, true, true)
+
+	table, err := tc.acquire(files[0])
+	if err != nil {
+		t.Fatalf("Failed to acquire table - Error: %s", err.Error())
+	}
+	tc.evict(files[0].number)
+
+	record, err := table.reader.Get("key-001", maxSequence)
+	if err != nil || record == nil {
+		t.Errorf("Reader in use should stay open after eviction, got %v - %v", record, err)
+	}
+
+	tc.release(table)
+	if _, err := table.reader.file.Stat(); err == nil {
+		t.Error("Reader should be closed once released after eviction")
+	}
+}
+
+func Test_tableCacheShouldEvictFilesDeletedByCompaction(t *testing.T) {
+	db := getTestCompactionDB(t)
+
+	for i := 0; i < 500; i++ {
+		db.Write(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%03d", i)))
+	}
+	waitForMemtableSerialization(t, db)
+	for i := 0; i < 500; i++ {
+		db.Get(fmt.Sprintf("key-%03d", i))
+	}
+	if err := db.compactSvc.compact(); err != nil {
+		t.Fatalf("Failed to compact - Error: %s", err.Error())
+	}
+
+	live := make(map[uint64]bool)
+	for _, meta := range db.manifest.currentVersion().files() {
+		live[meta.number] = true
+	}
+	db.tables.lock.Lock()
+	for number := range db.tables.tables {
+		if !live[number] {
+			t.Errorf("Deleted file %d should be evicted from table cache", number)
+		}
+	}
+	db.tables.lock.Unlock()
+
+	for i := 0; i < 500; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		if value, _ := db.Get(key); string(value) != fmt.Sprintf("value-%03d", i) {
+			t.Errorf("Expected value for key %s after compaction, got %s", key, string(value))
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package dbengine
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	OP_TXN_GET      = "OP_TXN_GET"
+	OP_TXN_LOCK     = "OP_TXN_LOCK"
+	OP_TXN_WRITE    = "OP_TXN_WRITE"
+	OP_TXN_COMMIT   = "OP_TXN_COMMIT"
+	OP_TXN_ROLLBACK = "OP_TXN_ROLLBACK"
+)
+
+var (
+	// ErrTxnConflict - the transaction read or wrote a key that has been written by someone else since the
+	// transaction began
+	ErrTxnConflict = errors.New("transaction conflicts with a newer write")
+	// ErrTxnDone - the transaction has already been committed or rolled back
+	ErrTxnDone = errors.New("transaction has already been committed or rolled back")
+)
+
+// TxnError - wraps errors of a transaction operation
+type TxnError struct {
+	Op  string
+	Key string // Key - the key that caused the error, if any
+	Err error
+}
+
+func (txnErr *TxnError) Error() string {
+	if txnErr.Key != "" {
+		return fmt.Sprintf("Transaction operation (code %s) failed on key %s - Error: %s", txnErr.Op, txnErr.Key, txnErr.Err.Error())
+	}
+	return fmt.Sprintf("Transaction operation (code %s) failed - Error: %s", txnErr.Op, txnErr.Err.Error())
+}
+
+func (txnErr *TxnError) Unwrap() error {
+	return txnErr.Err
+}
+
+// Txn - a transaction, writes are buffered privately until commit and are then applied atomically. Reads
+// see the transaction's own writes first.
+//
+// An optimistic transaction reads the database as of the moment it began, and fails on commit with
+// `ErrTxnConflict` if any key it read or wrote has been written since it began.
+//
+// A pessimistic transaction locks every key it writes or reads with `GetForUpdate` until it's committed or
+// rolled back, so nobody else can write those keys in the meantime. Its reads see the latest committed values.
+// Waiting for a key locked by someone else fails with `ErrLockTimeout` after the configured lock timeout, and
+// with `ErrDeadlock` if the wait would never end, in which case the transaction is rolled back.
+//
+// Unlike `Database`, a transaction must not be used by multiple goroutines at the same time.
+type Txn struct {
+	db          *Database
+	id          uint64 // id - lock owner id of the transaction
+	pessimistic bool
+	snapshot    *Snapshot // snapshot - nil for pessimistic transactions
+	batch       *WriteBatch
+	// writes - the latest buffered operation of each key written by the transaction
+	writes map[string]*MemtableRecord
+	reads  map[string]bool
+	done   bool
+}
+
+// Begin - begins an optimistic transaction, the transaction has to be either committed or rolled back
+func (db *Database) Begin() *Txn {
+	txn := db.newTxn()
+	txn.snapshot = db.NewSnapshot()
+	return txn
+}
+
+// BeginPessimistic - begins a pessimistic transaction, the transaction has to be either committed or rolled
+// back to release its key locks
+func (db *Database) BeginPessimistic() *Txn {
+	txn := db.newTxn()
+	txn.pessimistic = true
+	return txn
+}
+
+func (db *Database) newTxn() *Txn {
+	return &Txn{
+		db:     db,
+		id:     db.locks.newOwner(),
+		batch:  NewWriteBatch(),
+		writes: make(map[string]*MemtableRecord),
+		reads:  make(map[string]bool),
+	}
+}
+
+// Get - reads the value of key, nil if the key doesn't exist or has been deleted
+func (txn *Txn) Get(key string) ([]byte, error) {
+	if txn.done {
+		return nil, &TxnError{Op: OP_TXN_GET, Err: ErrTxnDone}
+	}
+
+	txn.reads[key] = true
+	return txn.read(key)
+}
+
+// GetForUpdate - reads the value of key like `Get`. A pessimistic transaction locks the key first and holds
+// the lock until it's committed or rolled back.
+func (txn *Txn) GetForUpdate(key string) ([]byte, error) {
+	if txn.done {
+		return nil, &TxnError{Op: OP_TXN_GET, Err: ErrTxnDone}
+	}
+
+	if err := txn.lockKey(key); err != nil {
+		return nil, err
+	}
+	txn.reads[key] = true
+	return txn.read(key)
+}
+
+// read - reads key from the writes of the transaction first, then from the database
+func (txn *Txn) read(key string) ([]byte, error) {
+	if record, ok := txn.writes[key]; ok {
+		if record.Tombstone {
+			return nil, nil
+		}
+		return record.Value, nil
+	}
+	return txn.db.GetWithOptions(key, &ReadOptions{Snapshot: txn.snapshot})
+}
+
+// lockKey - locks key for a pessimistic transaction, rolls the transaction back if it's picked as the victim
+// of a deadlock. No-op for optimistic transactions.
+func (txn *Txn) lockKey(key string) error {
+	if !txn.pessimistic {
+		return nil
+	}
+
+	err := txn.db.locks.acquire(txn.id, key, txn.db.setting.LockTimeout)
+	if errors.Is(err, ErrDeadlock) {
+		txn.done = true
+		txn.db.locks.releaseAll(txn.id)
+	}
+	if err != nil {
+		return &TxnError{Op: OP_TXN_LOCK, Key: key, Err: err}
+	}
+	return nil
+}
+
+// Put - writes value of key in the transaction
+func (txn *Txn) Put(key string, value []byte) error {
+	if txn.done {
+		return &TxnError{Op: OP_TXN_WRITE, Err: ErrTxnDone}
+	}
+
+	if err := txn.lockKey(key); err != nil {
+		return err
+	}
+	txn.batch.Put(key, value)
+	txn.writes[key] = &MemtableRecord{Key: key, Value: value}
+	return nil
+}
+
+// Delete - deletes key in the transaction
+func (txn *Txn) Delete(key string) error {
+	if txn.done {
+		return &TxnError{Op: OP_TXN_WRITE, Err: ErrTxnDone}
+	}
+
+	if err := txn.lockKey(key); err != nil {
+		return err
+	}
+	txn.batch.Delete(key)
+	txn.writes[key] = &MemtableRecord{Key: key, Tombstone: true}
+	return nil
+}
+
+// Commit - applies the writes of the transaction. An optimistic transaction returns `ErrTxnConflict` instead
+// if any of the keys it read or wrote has been written since it began. The transaction is done either way.
+func (txn *Txn) Commit() error {
+	return txn.CommitWithOptions(nil)
+}
+
+// CommitWithOptions - same as `Commit`, with the options the writes of the transaction are applied with. Like
+// other writes, the commit goes through the write queue, an optimistic transaction is committed in a group of
+// its own since it's checked for conflicts right before its writes are applied.
+func (txn *Txn) CommitWithOptions(opts *WriteOptions) error {
+	if txn.done {
+		return &TxnError{Op: OP_TXN_COMMIT, Err: ErrTxnDone}
+	}
+	txn.done = true
+
+	db := txn.db
+	defer db.locks.releaseAll(txn.id)
+	if txn.pessimistic {
+		// the keys written are locked already, so the writes can be grouped with any other write
+		if err := db.writes.commit(txn.batch, opts, db.applyGroup); err != nil {
+			return &TxnError{Op: OP_TXN_COMMIT, Err: err}
+		}
+		return nil
+	}
+
+	// the snapshot keeps the versions written since the transaction began from being compacted away
+	// until the conflict check is done
+	defer txn.snapshot.Release()
+
+	// keys locked by pessimistic transactions can't be written until they're released
+	keys := make([]string, 0, len(txn.writes))
+	for key := range txn.writes {
+		keys = append(keys, key)
+	}
+	if err := db.locks.acquireAll(txn.id, keys, db.setting.LockTimeout); err != nil {
+		return &TxnError{Op: OP_TXN_COMMIT, Err: err}
+	}
+
+	return db.writes.commitAlone(txn.batch, opts, func(batch *WriteBatch, opts *WriteOptions) error {
+		db.writeLock.Lock()
+		defer db.writeLock.Unlock()
+
+		for key := range txn.reads {
+			if err := txn.checkConflict(key); err != nil {
+				return err
+			}
+		}
+		for key := range txn.writes {
+			if err := txn.checkConflict(key); err != nil {
+				return err
+			}
+		}
+
+		if err := db.applyBatchLocked(batch, opts); err != nil {
+			return &TxnError{Op: OP_TXN_COMMIT, Err: err}
+		}
+		return nil
+	})
+}
+
+// checkConflict - returns `ErrTxnConflict` if key has been written since the transaction began
+func (txn *Txn) checkConflict(key string) error {
+	record, err := txn.db.getRecord(key, maxSequence, txn.db.setting.VerifyChecksums)
+	if err != nil {
+		return &TxnError{Op: OP_TXN_COMMIT, Key: key, Err: err}
+	}
+	if record != nil && record.Seq > txn.snapshot.seq {
+		return &TxnError{Op: OP_TXN_COMMIT, Key: key, Err: ErrTxnConflict}
+	}
+	return nil
+}
+
+// Rollback - discards the writes of the transaction
+func (txn *Txn) Rollback() error {
+	if txn.done {
+		return &TxnError{Op: OP_TXN_ROLLBACK, Err: ErrTxnDone}
+	}
+	txn.done = true
+	txn.db.locks.releaseAll(txn.id)
+	if txn.snapshot != nil {
+		txn.snapshot.Release()
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package dbengine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func getTestTxnDB(t *testing.T, configs ...DBConfig) *Database {
+	t.Helper()
+
+	configs = append([]DBConfig{
+		ConfigDBDir(setupTestDBDir(t)),
+		ConfigLogLevel(log.InfoLevel),
+	}, configs...)
+	db, err := NewDatabase(configs...)
+	if err != nil {
+		t.Fatalf("Failed to initialize database - Error: %s", err.Error())
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func Test_txnShouldReadItsOwnWritesAndHideThemUntilCommit(t *testing.T) {
+	db := getTestTxnDB(t)
+	db.Write("deleted", []byte("value"))
+
+	txn := db.Begin()
+	txn.Put("key", []byte("value"))
+	txn.Delete("deleted")
+
+	if value, _ := txn.Get("key"); string(value) != "value" {
+		t.Errorf("Transaction should read its own write, got %s", string(value))
+	}
+	if value, _ := txn.Get("deleted"); value != nil {
+		t.Errorf("Transaction should read its own delete, got %s", string(value))
+	}
+	if value, _ := db.Get("key"); value != nil {
+		t.Errorf("Writes should not be visible before commit, got %s", string(value))
+	}
+
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("Failed to commit - Error: %s", err.Error())
+	}
+	if value, _ := db.Get("key"); string(value) != "value" {
+		t.Errorf("Writes should be visible after commit, got %s", string(value))
+	}
+	if value, _ := db.Get("deleted"); value != nil {
+		t.Errorf("Deletes should be visible after commit, got %s", string(value))
+	}
+}
+
+func Test_txnShouldFailToCommitIfKeyReadIsWrittenByOthers(t *testing.T) {
+	db := getTestTxnDB(t)
+	db.Write("counter", []byte("1"))
+
+	txn := db.Begin()
+	txn.Get("counter")
+	txn.Put("other", []byte("value"))
+
+	db.Write("counter", []byte("2"))
+
+	err := txn.Commit()
+	if !errors.Is(err, ErrTxnConflict) {
+		t.Errorf("Expected a conflict, got %v", err)
+	}
+	if value, _ := db.Get("other"); value != nil {
+		t.Errorf("Writes of a conflicting transaction should not be applied, got %s", string(value))
+	}
+}
+
+func Test_txnShouldFailToCommitIfKeyWrittenIsWrittenByAnotherTxn(t *testing.T) {
+	db := getTestTxnDB(t)
+
+	first := db.Begin()
+	second := db.Begin()
+	first.Put("key", []byte("first"))
+	second.Put("key", []byte("second"))
+
+	if err := first.Commit(); err != nil {
+		t.Fatalf("Failed to commit - Error: %s", err.Error())
+	}
+	if err := second.Commit(); !errors.Is(err, ErrTxnConflict) {
+		t.Errorf("Expected a conflict, got %v", err)
+	}
+	if value, _ := db.Get("key"); string(value) != "first" {
+		t.Errorf("Expected the value of the first transaction, got %s", string(value))
+	}
+}
+
+func Test_txnShouldReadFromTheMomentItBegan(t *testing.T) {
+	db := getTestTxnDB(t)
+	db.Write("key", []byte("old"))
+
+	txn := db.Begin()
+	db.Write("key", []byte("new"))
+
+	if value, _ := txn.Get("key"); string(value) != "old" {
+		t.Errorf("Transaction should not see writes made after it began, got %s", string(value))
+	}
+	txn.Rollback()
+
+	if err := txn.Commit(); !errors.Is(err, ErrTxnDone) {
+		t.Errorf("Expected the transaction to be done after rollback, got %v", err)
+	}
+	if len(db.snapshots.sequences()) != 0 {
+		t.Error("Snapshot of the transaction should be released once it's done")
+	}
+}
+
+func Test_pessimisticTxnShouldBlockWritesToLockedKeysUntilCommit(t *testing.T) {
+	db := getTestTxnDB(t, ConfigLockTimeout(50*time.Millisecond))
+	db.Write("key", []byte("value"))
+
+	txn := db.BeginPessimistic()
+	if _, err := txn.GetForUpdate("key"); err != nil {
+		t.Fatalf("Failed to lock key - Error: %s", err.Error())
+	}
+	txn.Put("key", []byte("txn-value"))
+	if value, _ := txn.Get("key"); string(value) != "txn-value" {
+		t.Errorf("Transaction should read its own uncommitted write, got %s", string(value))
+	}
+
+	if err := db.Write("key", []byte("other-value")); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("Write to a locked key should time out, got %v", err)
+	}
+	if err := db.Delete("key"); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("Delete of a locked key should time out, got %v", err)
+	}
+	other := db.BeginPessimistic()
+	if _, err := other.GetForUpdate("key"); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("Locking a locked key should time out, got %v", err)
+	}
+	other.Rollback()
+
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("Failed to commit - Error: %s", err.Error())
+	}
+	if err := db.Write("key", []byte("other-value")); err != nil {
+		t.Errorf("Write should succeed once the lock is released, got %s", err.Error())
+	}
+}
+
+func Test_pessimisticTxnShouldAbortOneVictimOnDeadlock(t *testing.T) {
+	db := getTestTxnDB(t, ConfigLockTimeout(5*time.Second))
+
+	txn1 := db.BeginPessimistic()
+	txn2 := db.BeginPessimistic()
+	txn1.GetForUpdate("key-1")
+	txn2.GetForUpdate("key-2")
+
+	result := make(chan error)
+	go func() {
+		_, err := txn1.GetForUpdate("key-2")
+		result <- err
+	}()
+	// wait until txn1 is blocked on txn2
+	for {
+		db.locks.lock.Lock()
+		_, waiting := db.locks.waitsFor[txn1.id]
+		db.locks.lock.Unlock()
+		if waiting {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := txn2.GetForUpdate("key-1"); !errors.Is(err, ErrDeadlock) {
+		t.Fatalf("Expected deadlock, got %v", err)
+	}
+	if err := txn2.Commit(); !errors.Is(err, ErrTxnDone) {
+		t.Errorf("Deadlock victim should be rolled back, got %v", err)
+	}
+	if err := <-result; err != nil {
+		t.Fatalf("Lock should be granted once the victim is aborted - Error: %s", err.Error())
+	}
+	if err := txn1.Commit(); err != nil {
+		t.Errorf("Failed to commit - Error: %s", err.Error())
+	}
+}
+
+func Test_pessimisticTxnShouldSerializeReadModifyWrite(t *testing.T) {
+	db := getTestTxnDB(t, ConfigLockTimeout(10*time.Second))
+	db.Write("counter", []byte("0"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				txn := db.BeginPessimistic()
+				value, err := txn.GetForUpdate("counter")
+				if err != nil {
+					t.Errorf("Failed to lock counter - Error: %s", err.Error())
+					txn.Rollback()
+					return
+				}
+				var counter int
+				fmt.Sscanf(string(value), "%d", &counter)
+				txn.Put("counter", []byte(fmt.Sprintf("%d", counter+1)))
+				if err := txn.Commit(); err != nil {
+					t.Errorf("Failed to commit - Error: %s", err.Error())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if value, _ := db.Get("counter"); string(value) != "100" {
+		t.Errorf("Expected counter 100, got %s", string(value))
+	}
+}
+
+func Test_txnCommitShouldHonourWriteOptions(t *testing.T) {
+	db := getTestTxnDB(t, ConfigMemtableSizeByte(64))
+
+	// pretend a memtable is being serialized, so that filling up the current one would stall
+	busy := NewBasicMemTable(db.walDir, false)
+	db.memSvc.lock.Lock()
+	db.memSvc.queue = append(db.memSvc.queue, busy)
+	db.memSvc.lock.Unlock()
+
+	for _, txn := range []*Txn{db.Begin(), db.BeginPessimistic()} {
+		txn.Put("full", make([]byte, 64))
+		if err := txn.CommitWithOptions(&WriteOptions{NoSlowdown: true}); !errors.Is(err, ErrWriteStall) {
+			t.Errorf("Expected commit filling up the memtable to stall (pessimistic %t), got %v", txn.pessimistic, err)
+		}
+	}
+	if value, _ := db.Get("full"); value != nil {
+		t.Error("Expected stalled commits not to be applied")
+	}
+
+	db.memSvc.lock.Lock()
+	db.memSvc.queue = db.memSvc.queue[:0]
+	db.memSvc.lock.Unlock()
+	busy.Wal().Delete()
+
+	for i, txn := range []*Txn{db.Begin(), db.BeginPessimistic()} {
+		key := fmt.Sprintf("unlogged-%d", i)
+		txn.Put(key, []byte("value"))
+		if err := txn.CommitWithOptions(&WriteOptions{DisableWAL: true}); err != nil {
+			t.Fatalf("Failed to commit (pessimistic %t) - Error: %s", txn.pessimistic, err.Error())
+		}
+		if value, _ := db.Get(key); string(value) != "value" {
+			t.Errorf("Expected committed value of key %s, got %s", key, value)
+		}
+	}
+	if !db.curMem.WalRange().Unlogged {
+		t.Error("Expected commits to skip the WAL")
+	}
+}
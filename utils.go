@@ -3,6 +3,7 @@ package dbengine
 import (
 	"encoding/binary"
 	"io"
+	"os"
 )
 
 // WriteDataWithVarintSizePrefix - writes data to w with a varint size prefix
@@ -42,10 +43,26 @@ func ReadDataWithVarintPrefix(r VarintSizePrefixDataReader, buf []byte) ([]byte,
 	if buf == nil || uint64(len(buf)) < l {
 		buf = make([]byte, l, l)
 	}
-	n, err := r.Read(buf)
+	// a single `Read` may return less than `l` bytes (e.g. when `r` is buffered), so keep reading until
+	// the whole data block is filled. A partially written block surfaces as `io.ErrUnexpectedEOF`
+	n, err := io.ReadFull(r, buf[:l])
 	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
 		return nil, err
 	}
 
 	return buf[:n], nil
 }
+
+// syncDir - flushes the entries of directory dir to the storage device, so that files created, renamed or
+// deleted in it stay so after a crash
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
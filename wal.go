@@ -1,10 +1,17 @@
 package dbengine
 
 import (
+	"bufio"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,12 +24,23 @@ type Wal interface {
 	// Append - append an operation log to the WAL file
 	Append([]byte) error
 
-	// TODO: (p1) Replay - returns a channel of operations logs, EOF indicates we've reached the end of the WAL
-	// Replay() <-chan []byte
+	// Sync - flushes the logs appended so far to the storage device
+	Sync() error
 
-	// Delete - delete the WAL file
+	// Replay - reads every operation log in the WAL file from the beginning and calls fn with its data in
+	// the order they were appended. Replay stops at the first error returned by fn.
+	Replay(fn func([]byte) error) error
+
+	// Recover - same as `Replay`, except that damaged logs are handled according to mode. Returns a report of
+	// the logs dropped.
+	Recover(mode WalRecoveryMode, fn func([]byte) error) (*WalRecoveryReport, error)
+
+	// Delete - closes and deletes the WAL file
 	Delete() error
 
+	// Close - closes the WAL file, the WAL can't be used afterwards
+	Close() error
+
 	// File -- returns the underlying WAL file
 	File() WalFile
 }
@@ -32,8 +50,10 @@ type Wal interface {
 type WalFile interface {
 	io.Writer
 	io.Reader
+	io.Closer
 
 	Truncate(int64) error
+	Sync() error
 	Stat() (os.FileInfo, error)
 	Name() string
 }
@@ -42,8 +62,12 @@ const (
 	OP_WAL_CREATE_FILE = "OP_WAL_CREATE_FILE"
 	OP_WAL_READ_FILE   = "OP_WAL_READ_FILE"
 	OP_WAL_APPEND      = "OP_WAL_APPEND"
+	OP_WAL_SYNC        = "OP_WAL_SYNC"
 	OP_WAL_ROLLBACK    = "OP_WAL_ROLLBACK"
 	OP_WAL_DELETE      = "OP_WAL_DELETE"
+	OP_WAL_CLOSE       = "OP_WAL_CLOSE"
+	OP_WAL_OPEN_FILE   = "OP_WAL_OPEN_FILE"
+	OP_WAL_REPLAY      = "OP_WAL_REPLAY"
 )
 
 // WalError - wraps errors with WAL operation and basic information before the error happens
@@ -64,6 +88,35 @@ func (walErr *WalError) Unwrap() error {
 	return walErr.Err
 }
 
+// WalRecoveryMode - decides what happens to damaged logs when a WAL file is recovered. A log is damaged if it
+// doesn't match its checksum or can't be decoded (corrupted), or if the file ends in the middle of it (torn),
+// which is expected after a crash or power loss since the write was never acknowledged.
+type WalRecoveryMode int
+
+const (
+	// WalRecoveryTolerateCorruptedTail - drops a torn log at the end of the file, fails on any other damage
+	WalRecoveryTolerateCorruptedTail WalRecoveryMode = iota
+	// WalRecoveryAbsoluteConsistency - fails on any damage, even a torn log at the end of the file
+	WalRecoveryAbsoluteConsistency
+	// WalRecoveryPointInTime - stops at the first damaged log and discards every log after it, including the
+	// ones in later WAL files, so the database is recovered to a consistent point in time. The WAL file is
+	// truncated at the damaged log, so that the logs discarded never come back
+	WalRecoveryPointInTime
+	// WalRecoverySkipAnyCorruptedRecord - drops damaged logs and goes on with the logs after them, recovering as
+	// much as possible. WAL files written before logs were framed into blocks can't be read past a damaged log.
+	WalRecoverySkipAnyCorruptedRecord
+)
+
+// WalRecoveryReport - what happened while recovering a WAL file
+type WalRecoveryReport struct {
+	File     string
+	Replayed int     // Replayed - number of logs replayed
+	Dropped  []error // Dropped - a `WalError` for each damaged log dropped, naming where it starts
+	// Stopped - true if recovery stopped at a damaged log before the end of the file, the logs after it are
+	// discarded
+	Stopped bool
+}
+
 // BasicWal - implements the `Wal` interface
 type BasicWal struct {
 	lock sync.Mutex
@@ -71,6 +124,8 @@ type BasicWal struct {
 	file WalFile
 	// seq is the sequence number of the latest written log
 	seq uint32
+	// closed is set once the file is closed
+	closed bool
 }
 
 // BasicWalLog - represents a WAL log record
@@ -82,8 +137,9 @@ type BasicWalLog struct {
 // Serialize - turn the WAL log into bytes
 func (l *BasicWalLog) Serialize() ([]byte, error) {
 	log := &pb.WalLog{
-		Seq:  l.seq,
-		Data: l.data,
+		Seq:      l.seq,
+		Data:     l.data,
+		Checksum: walLogChecksum(l.seq, l.data),
 	}
 	logData, err := proto.Marshal(log)
 	if err != nil {
@@ -92,6 +148,13 @@ func (l *BasicWalLog) Serialize() ([]byte, error) {
 	return logData, nil
 }
 
+// walLogChecksum - returns the CRC32C checksum of a WAL log
+func walLogChecksum(seq uint32, data []byte) uint32 {
+	buf := make([]byte, 4, 4+len(data))
+	binary.LittleEndian.PutUint32(buf, seq)
+	return checksum(append(buf, data...))
+}
+
 // NewBasicWal - creates a new WAL instance and an underlying WAL file
 // if `syncOnWrite` is set to true, each write operation will always be flushed to the storage device.
 // errors out if file with same name already exists (no WAL file reuse between `BasicWal` instances)
@@ -106,7 +169,56 @@ func NewBasicWal(walDir string, syncOnWrite bool) (*BasicWal, error) {
 	}, nil
 }
 
-// NewWalFile - creates a new WAL file with name "wal_<unix timestamp>" under `walDir`
+// OpenBasicWal - opens an existing WAL file (e.g. one left behind by a previous process) so it can be
+// replayed and deleted once its content has been persisted elsewhere.
+// if `syncOnWrite` is set to true, each write operation will always be flushed to the storage device.
+func OpenBasicWal(walFile string, syncOnWrite bool) (*BasicWal, error) {
+	fileFlag := os.O_APPEND | os.O_RDWR
+	if syncOnWrite {
+		fileFlag = fileFlag | os.O_SYNC
+	}
+
+	f, err := os.OpenFile(walFile, fileFlag, 0644)
+	if err != nil {
+		return nil, &WalError{
+			Op:            OP_WAL_OPEN_FILE,
+			BeforeLastSeq: 0,
+			Err:           err,
+		}
+	}
+
+	return &BasicWal{
+		file: f,
+	}, nil
+}
+
+// ListWalFiles - returns the path of all WAL files under `walDir` ordered from the oldest to the latest
+func ListWalFiles(walDir string) ([]string, error) {
+	files, err := ioutil.ReadDir(walDir)
+	if err != nil {
+		return nil, err
+	}
+
+	walFiles := make([]string, 0, len(files))
+	timestamps := make(map[string]int64)
+	for _, file := range files {
+		if file.IsDir() || !strings.HasPrefix(file.Name(), "wal_") {
+			continue
+		}
+		ts, err := strconv.ParseInt(strings.TrimPrefix(file.Name(), "wal_"), 10, 64)
+		if err != nil {
+			continue
+		}
+		walFile := filepath.Join(walDir, file.Name())
+		walFiles = append(walFiles, walFile)
+		timestamps[walFile] = ts
+	}
+	sort.Slice(walFiles, func(i, j int) bool { return timestamps[walFiles[i]] < timestamps[walFiles[j]] })
+
+	return walFiles, nil
+}
+
+// NewWalFile - creates a new WAL file with name "wal_<unix timestamp>" under `walDir` and writes its header
 // if `syncOnWrite` is set to true, each write operation will always be flushed to the storage device.
 //
 // Note that `syncOnWrite` will introduce a performance penalty (4x worse tested with 100k inserts, 4s vs. 15s).
@@ -114,7 +226,11 @@ func NewBasicWal(walDir string, syncOnWrite bool) (*BasicWal, error) {
 // the file system cache can still be flushed to the underlying hardware
 func NewWalFile(walDir string, syncOnWrite bool) (*os.File, error) {
 	ts := time.Now().UnixNano()
-	filename := filepath.Join(walDir, fmt.Sprintf("wal_%d", ts))
+	return createWalFile(filepath.Join(walDir, fmt.Sprintf("wal_%d", ts)), syncOnWrite)
+}
+
+// createWalFile - creates a new WAL file with filename and writes its header, errors out if it already exists
+func createWalFile(filename string, syncOnWrite bool) (*os.File, error) {
 	// os.O_CREATE|os.O_EXCL - create file only when it doesn't exist, error out otherwise
 	// os.O_RDWR - open for read & write
 	// os.O_SYNC - enable synchronous IO (write always flush to underlying hardware, like "write" + "fsync")
@@ -132,10 +248,19 @@ func NewWalFile(walDir string, syncOnWrite bool) (*os.File, error) {
 			Err:           err,
 		}
 	}
+	if _, err := f.Write(walFileHeader()); err != nil {
+		f.Close()
+		return nil, &WalError{
+			Op:            OP_WAL_CREATE_FILE,
+			BeforeLastSeq: 0,
+			Err:           err,
+		}
+	}
 	return f, nil
 }
 
-// Append - append an operation log to the WAL file
+// Append - append an operation log to the WAL file, the log is written with a single write so that it's synced
+// once in strict mode
 func (wal *BasicWal) Append(log []byte) error {
 	wal.lock.Lock()
 	defer wal.lock.Unlock()
@@ -163,7 +288,7 @@ func (wal *BasicWal) Append(log []byte) error {
 		}
 	}
 
-	if _, err := WriteDataWithVarintSizePrefix(wal.file, logBytes); err != nil {
+	if _, err := wal.file.Write(frameWalLog(logBytes, int(oldSize%walBlockSize))); err != nil {
 		if rollbackErr := wal.rollback(oldSize); rollbackErr != nil {
 			return rollbackErr
 		}
@@ -178,6 +303,22 @@ func (wal *BasicWal) Append(log []byte) error {
 	return nil
 }
 
+// Sync - flushes the logs appended so far to the storage device, which is already done by every append in
+// strict mode
+func (wal *BasicWal) Sync() error {
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+
+	if err := wal.file.Sync(); err != nil {
+		return &WalError{
+			Op:            OP_WAL_SYNC,
+			BeforeLastSeq: wal.seq,
+			Err:           err,
+		}
+	}
+	return nil
+}
+
 func (wal *BasicWal) rollback(size int64) error {
 	if err := wal.file.Truncate(size); err != nil {
 		return &WalError{
@@ -189,16 +330,161 @@ func (wal *BasicWal) rollback(size int64) error {
 	return nil
 }
 
+// Replay - reads every operation log in the WAL file from the beginning and calls fn with its data in
+// the order they were appended. Replay stops at the first error returned by fn.
+//
+// A log that was only partially written (e.g. the process crashed in the middle of an append) results in
+// a `WalError` wrapping `io.ErrUnexpectedEOF`, all logs before it have been passed to fn already. A log that
+// doesn't match its checksum results in a `WalError` wrapping a `CorruptionError`.
+//
+// WAL files written before logs were framed into blocks (see wal_record.go) are replayed as well.
+func (wal *BasicWal) Replay(fn func([]byte) error) error {
+	_, err := wal.Recover(WalRecoveryAbsoluteConsistency, fn)
+	return err
+}
+
+// Recover - same as `Replay`, except that damaged logs are handled according to mode. Returns a report of the
+// logs dropped, a `WalError` is only returned for the damage mode doesn't tolerate. See `WalRecoveryPointInTime`
+// for how the file is truncated.
+func (wal *BasicWal) Recover(mode WalRecoveryMode, fn func([]byte) error) (*WalRecoveryReport, error) {
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+
+	report := &WalRecoveryReport{File: wal.file.Name()}
+	// open a separate read-only handle so replay always starts from the beginning of the file regardless
+	// of where the append handle is at
+	f, err := os.Open(wal.file.Name())
+	if err != nil {
+		return report, &WalError{
+			Op:            OP_WAL_READ_FILE,
+			BeforeLastSeq: wal.seq,
+			Err:           err,
+		}
+	}
+	defer f.Close()
+
+	next, canSkip, err := wal.logReader(f)
+	if err != nil {
+		return report, &WalError{
+			Op:            OP_WAL_READ_FILE,
+			BeforeLastSeq: wal.seq,
+			Err:           err,
+		}
+	}
+	for {
+		data, offset, err := next()
+		if err == io.EOF {
+			return report, nil
+		}
+		if err == nil {
+			var walLog *pb.WalLog
+			if walLog, err = decodeWalLog(f.Name(), offset, data); err == nil {
+				if err := fn(walLog.Data); err != nil {
+					return report, err
+				}
+				wal.seq = walLog.Seq
+				report.Replayed++
+				continue
+			}
+		}
+
+		walErr := &WalError{
+			Op:            OP_WAL_REPLAY,
+			BeforeLastSeq: wal.seq,
+			Err:           err,
+		}
+		// a torn log is always the last one of the file
+		torn := errors.Is(err, io.ErrUnexpectedEOF)
+		switch {
+		case !torn && !errors.Is(err, ErrCorruption):
+			// failed to read the file, not a damaged log
+			walErr.Op = OP_WAL_READ_FILE
+			return report, walErr
+		case mode == WalRecoveryAbsoluteConsistency, mode == WalRecoveryTolerateCorruptedTail && !torn:
+			return report, walErr
+		case mode == WalRecoverySkipAnyCorruptedRecord && !torn && canSkip:
+			report.Dropped = append(report.Dropped, walErr)
+		default:
+			report.Dropped = append(report.Dropped, walErr)
+			report.Stopped = !torn
+			var cErr *CorruptionError
+			if report.Stopped && mode == WalRecoveryPointInTime && errors.As(err, &cErr) {
+				if err := wal.rollback(cErr.Offset); err != nil {
+					return report, err
+				}
+			}
+			return report, nil
+		}
+	}
+}
+
+// logReader - returns a function that reads the logs of the WAL file one at a time along with where they start,
+// `io.EOF` once they're all read. Reading can go on after a damaged log only if canSkip is true, which is the
+// case for files framed into blocks.
+func (wal *BasicWal) logReader(f *os.File) (next func() ([]byte, int64, error), canSkip bool, err error) {
+	header := make([]byte, walFileHeaderSize)
+	n, err := f.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return nil, false, err
+	}
+	if n == 0 || header[0] != 0 {
+		// a plain stream of varint size prefixed logs
+		reader := bufio.NewReader(f)
+		offset := int64(0) // offset - where the next log starts in the file
+		sizeBuf := make([]byte, binary.MaxVarintLen64)
+		return func() ([]byte, int64, error) {
+			data, err := ReadDataWithVarintPrefix(reader, nil)
+			var pathErr *os.PathError
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF && !errors.As(err, &pathErr) {
+				// e.g. a size prefix that overflows
+				err = &CorruptionError{File: f.Name(), Offset: offset, Reason: err.Error()}
+			}
+			if err != nil {
+				return nil, offset, err
+			}
+			logOffset := offset
+			offset += int64(binary.PutUvarint(sizeBuf, uint64(len(data))) + len(data))
+			return data, logOffset, nil
+		}, false, nil
+	}
+
+	headerErr := error(io.ErrUnexpectedEOF)
+	if n == walFileHeaderSize {
+		if binary.LittleEndian.Uint32(header) == walMagic && binary.LittleEndian.Uint32(header[4:]) <= walFormatVersion {
+			reader := newWalReader(f, f.Name())
+			return reader.next, true, nil
+		}
+		headerErr = &CorruptionError{File: f.Name(), Offset: 0, Reason: "invalid file header"}
+	}
+	return func() ([]byte, int64, error) { return nil, 0, headerErr }, false, nil
+}
+
+// decodeWalLog - decodes a log that starts at offset in the WAL file
+func decodeWalLog(file string, offset int64, data []byte) (*pb.WalLog, error) {
+	walLog := &pb.WalLog{}
+	if err := proto.Unmarshal(data, walLog); err != nil {
+		return nil, &CorruptionError{File: file, Offset: offset, Reason: err.Error()}
+	}
+	// logs written before checksums existed have none
+	if walLog.Checksum != 0 && walLog.Checksum != walLogChecksum(walLog.Seq, walLog.Data) {
+		return nil, &CorruptionError{File: file, Offset: offset, Reason: "checksum mismatch"}
+	}
+	return walLog, nil
+}
+
 // File -- returns the underlying WAL file
 func (wal *BasicWal) File() WalFile {
 	return wal.file
 }
 
-// Delete - delete the WAL file
+// Delete - closes and deletes the WAL file
 func (wal *BasicWal) Delete() error {
 	wal.lock.Lock()
 	defer wal.lock.Unlock()
 
+	if err := wal.closeLocked(); err != nil {
+		return err
+	}
 	if err := os.Remove(wal.file.Name()); err != nil {
 		return &WalError{
 			Op:            OP_WAL_DELETE,
@@ -208,3 +494,26 @@ func (wal *BasicWal) Delete() error {
 	}
 	return nil
 }
+
+// Close - closes the WAL file, it's fine to close a WAL more than once
+func (wal *BasicWal) Close() error {
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+
+	return wal.closeLocked()
+}
+
+func (wal *BasicWal) closeLocked() error {
+	if wal.closed {
+		return nil
+	}
+	wal.closed = true
+	if err := wal.file.Close(); err != nil {
+		return &WalError{
+			Op:            OP_WAL_CLOSE,
+			BeforeLastSeq: wal.seq,
+			Err:           err,
+		}
+	}
+	return nil
+}
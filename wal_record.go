@@ -0,0 +1,243 @@
+package dbengine
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// WAL file layout:
+// - <file header><block_1>...<block_N>
+// - The file is divided into blocks of `walBlockSize` bytes (the file header takes up the beginning of the first
+// block), the last block may be shorter.
+//
+// file header:
+// - <magic (fixed 32-bit)><format version (fixed 32-bit)>
+// - The first byte of the magic is 0, which tells files in this format apart from files written before it: those
+// are a plain stream of varint size prefixed logs, and a log is never empty.
+//
+// block:
+// - <fragment_1>...<fragment_M><trailer>
+// - A log is split into fragments so that no fragment crosses a block boundary. A log that fits in the rest of
+// the block is a single FULL fragment, otherwise it's a FIRST fragment, any number of MIDDLE fragments and a
+// LAST fragment. If the rest of a block is too short for a fragment header, it's filled with zeros (trailer).
+//
+// fragment:
+// - <checksum (fixed 32-bit)><data size (fixed 16-bit)><type (1 byte)><data>
+// - The checksum covers the type and the data.
+//
+// All little endian. Since fragments never cross blocks, a reader can skip the rest of a damaged block and pick
+// up at the next one, and a log cut short at the end of the file (a torn write) can be told apart from damage
+// in the middle of the file.
+
+const (
+	// walBlockSize - size (in bytes) of a WAL block
+	walBlockSize = 32 * 1024
+	// walFragmentHeaderSize - checksum (4 bytes), data size (2 bytes) and type (1 byte)
+	walFragmentHeaderSize = 4 + 2 + 1
+	// walFileHeaderSize - magic (4 bytes) and format version (4 bytes)
+	walFileHeaderSize = 4 + 4
+	// walMagic - marks the beginning of a block framed WAL file ("\x00WAL" in ASCII)
+	walMagic uint32 = 0x4c415700
+	// walFormatVersion - version of the format written by `BasicWal`
+	walFormatVersion uint32 = 1
+)
+
+// types of WAL fragments
+const (
+	// walZeroFragment - zeros left behind by a file system that preallocates space, the rest of the block is
+	// skipped
+	walZeroFragment byte = iota
+	walFullFragment
+	walFirstFragment
+	walMiddleFragment
+	walLastFragment
+)
+
+// walFileHeader - returns the header every WAL file starts with
+func walFileHeader() []byte {
+	header := make([]byte, walFileHeaderSize)
+	binary.LittleEndian.PutUint32(header, walMagic)
+	binary.LittleEndian.PutUint32(header[4:], walFormatVersion)
+	return header
+}
+
+// frameWalLog - splits a log into fragments, returns the bytes to append to a WAL file whose last block is
+// blockOffset bytes long
+func frameWalLog(log []byte, blockOffset int) []byte {
+	buf := make([]byte, 0, len(log)+walFragmentHeaderSize)
+	for first := true; first || len(log) > 0; first = false {
+		left := walBlockSize - blockOffset
+		if left < walFragmentHeaderSize {
+			// trailer
+			buf = append(buf, make([]byte, left)...)
+			blockOffset, left = 0, walBlockSize
+		}
+
+		size := left - walFragmentHeaderSize
+		if size > len(log) {
+			size = len(log)
+		}
+		last := size == len(log)
+		fragmentType := walMiddleFragment
+		switch {
+		case first && last:
+			fragmentType = walFullFragment
+		case first:
+			fragmentType = walFirstFragment
+		case last:
+			fragmentType = walLastFragment
+		}
+
+		header := make([]byte, walFragmentHeaderSize)
+		binary.LittleEndian.PutUint32(header, walFragmentChecksum(fragmentType, log[:size]))
+		binary.LittleEndian.PutUint16(header[4:], uint16(size))
+		header[6] = fragmentType
+		buf = append(append(buf, header...), log[:size]...)
+
+		log = log[size:]
+		blockOffset += walFragmentHeaderSize + size
+	}
+	return buf
+}
+
+// walFragmentChecksum - returns the CRC32C checksum of a WAL fragment
+func walFragmentChecksum(fragmentType byte, data []byte) uint32 {
+	return checksum(append([]byte{fragmentType}, data...))
+}
+
+// walReader - reads the logs of a block framed WAL file. After a `CorruptionError` the reader can go on reading,
+// it resumes at the first log it can find intact after the damage.
+type walReader struct {
+	r     io.Reader
+	file  string
+	block []byte
+	// blockStart - where the current block starts in the file
+	blockStart int64
+	// pos - where the next fragment starts in the current block
+	pos int
+	// lastBlock - true if the current block is shorter than a full block, so the file ends with it
+	lastBlock bool
+	buf       []byte
+}
+
+// newWalReader - returns a reader of the WAL file read from its beginning by r, the file header is skipped
+func newWalReader(r io.Reader, file string) *walReader {
+	return &walReader{
+		r:          r,
+		file:       file,
+		block:      make([]byte, 0, walBlockSize),
+		blockStart: -walBlockSize,
+	}
+}
+
+// next - returns the next log and where it starts in the file. Returns `io.EOF` once every log is read,
+// `io.ErrUnexpectedEOF` if the file ends in the middle of a log and a `CorruptionError` if a log is damaged.
+func (r *walReader) next() (log []byte, offset int64, err error) {
+	inLog := false
+	for {
+		fragmentType, data, fragmentOffset, err := r.readFragment()
+		if err == io.EOF && inLog {
+			return nil, offset, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, fragmentOffset, err
+		}
+
+		switch fragmentType {
+		case walFullFragment, walFirstFragment:
+			if inLog {
+				// read the fragment again as the beginning of the next log
+				r.pos -= walFragmentHeaderSize + len(data)
+				return nil, offset, r.corrupted(offset, "log missing its last fragment")
+			}
+			if fragmentType == walFullFragment {
+				return data, fragmentOffset, nil
+			}
+			r.buf = append(r.buf[:0], data...)
+			inLog, offset = true, fragmentOffset
+		case walMiddleFragment, walLastFragment:
+			if !inLog {
+				return nil, fragmentOffset, r.corrupted(fragmentOffset, "fragment missing the beginning of its log")
+			}
+			r.buf = append(r.buf, data...)
+			if fragmentType == walLastFragment {
+				return r.buf, offset, nil
+			}
+		default:
+			return nil, fragmentOffset, r.corrupted(fragmentOffset, fmt.Sprintf("unknown fragment type %d", fragmentType))
+		}
+	}
+}
+
+// readFragment - returns the next fragment and where it starts in the file
+func (r *walReader) readFragment() (fragmentType byte, data []byte, offset int64, err error) {
+	for {
+		if r.pos+walFragmentHeaderSize > len(r.block) {
+			if r.lastBlock {
+				if r.pos < len(r.block) {
+					offset = r.blockStart + int64(r.pos)
+					r.pos = len(r.block)
+					return 0, nil, offset, io.ErrUnexpectedEOF
+				}
+				return 0, nil, 0, io.EOF
+			}
+			if err := r.readBlock(); err != nil {
+				return 0, nil, 0, err
+			}
+			continue
+		}
+
+		header := r.block[r.pos : r.pos+walFragmentHeaderSize]
+		size := int(binary.LittleEndian.Uint16(header[4:]))
+		fragmentType = header[6]
+		offset = r.blockStart + int64(r.pos)
+		if fragmentType == walZeroFragment && size == 0 {
+			r.pos = len(r.block)
+			continue
+		}
+
+		end := r.pos + walFragmentHeaderSize + size
+		if end > len(r.block) {
+			r.pos = len(r.block)
+			if r.lastBlock {
+				return 0, nil, offset, io.ErrUnexpectedEOF
+			}
+			return 0, nil, offset, r.corrupted(offset, "fragment size out of block")
+		}
+		data = r.block[r.pos+walFragmentHeaderSize : end]
+		if binary.LittleEndian.Uint32(header) != walFragmentChecksum(fragmentType, data) {
+			// the size can't be trusted either, skip to the next block
+			r.pos = len(r.block)
+			return 0, nil, offset, r.corrupted(offset, "checksum mismatch")
+		}
+		r.pos = end
+		return fragmentType, data, offset, nil
+	}
+}
+
+// readBlock - reads the next block of the file, returns `io.EOF` if there is none
+func (r *walReader) readBlock() error {
+	n, err := io.ReadFull(r.r, r.block[:walBlockSize])
+	if err == io.EOF {
+		r.lastBlock = true
+		return io.EOF
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	first := r.blockStart < 0
+	r.block = r.block[:n]
+	r.blockStart += walBlockSize
+	r.lastBlock = n < walBlockSize
+	r.pos = 0
+	if first {
+		r.pos = walFileHeaderSize
+	}
+	return nil
+}
+
+func (r *walReader) corrupted(offset int64, reason string) error {
+	return &CorruptionError{File: r.file, Offset: offset, Reason: reason}
+}
@@ -0,0 +1,252 @@
+package dbengine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SegmentedWal - the write-ahead-log shared by all the memtables of a database. Logs are appended to a single
+// stream split into numbered segment files, a new segment is started once the current one grows over the
+// segment size. Each memtable records the first segment holding its logs (see `WalRange`), so a segment can be
+// removed once every memtable with logs in it has been serialized. Segments aren't recycled, preallocated or
+// archived yet: each one is created when it's started and deleted once it's no longer needed.
+type SegmentedWal struct {
+	lock        sync.Mutex
+	dir         string
+	segmentSize uint
+	syncOnWrite bool
+	current     *BasicWal
+	number      uint64 // number - number of the current segment
+	size        uint64 // size - size of the logs appended to the current segment
+	closed      bool
+}
+
+// OpenSegmentedWal - opens the WAL whose segments are under walDir and starts a new segment after the existing
+// ones, which are kept to be recovered. A new segment is started once the current one grows over segmentSize
+// bytes. If `syncOnWrite` is set to true, each write operation will always be flushed to the storage device.
+func OpenSegmentedWal(walDir string, segmentSize uint, syncOnWrite bool) (*SegmentedWal, error) {
+	numbers, err := listWalSegments(walDir)
+	if err != nil {
+		return nil, &WalError{Op: OP_WAL_OPEN_FILE, Err: err}
+	}
+
+	w := &SegmentedWal{dir: walDir, segmentSize: segmentSize, syncOnWrite: syncOnWrite}
+	if len(numbers) > 0 {
+		w.number = numbers[len(numbers)-1]
+	}
+	if err := w.rotateLocked(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// walSegmentFile - returns the path of the segment with number under walDir
+func walSegmentFile(walDir string, number uint64) string {
+	return filepath.Join(walDir, fmt.Sprintf("segment_%08d.wal", number))
+}
+
+// listWalSegments - returns the numbers of the segments under walDir in increasing order
+func listWalSegments(walDir string) ([]uint64, error) {
+	files, err := ioutil.ReadDir(walDir)
+	if err != nil {
+		return nil, err
+	}
+
+	numbers := make([]uint64, 0, len(files))
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasPrefix(name, "segment_") || !strings.HasSuffix(name, ".wal") {
+			continue
+		}
+		number, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, "segment_"), ".wal"), 10, 64)
+		if err != nil {
+			continue
+		}
+		numbers = append(numbers, number)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	return numbers, nil
+}
+
+// rotateLocked - syncs and closes the current segment and starts the next one, lock must be held
+func (w *SegmentedWal) rotateLocked() error {
+	f, err := createWalFile(walSegmentFile(w.dir, w.number+1), w.syncOnWrite)
+	if err != nil {
+		return err
+	}
+	if w.current != nil {
+		// a sync only covers the current segment, so the logs left in the previous one are synced beforehand
+		err := w.current.Sync()
+		if err == nil {
+			err = w.current.Close()
+		}
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return err
+		}
+	}
+	w.current = &BasicWal{file: f}
+	w.number++
+	w.size = 0
+	return nil
+}
+
+// Append - append an operation log to the current segment, a new segment is started afterwards if the current
+// one has grown over the segment size
+func (w *SegmentedWal) Append(data []byte) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if err := w.current.Append(data); err != nil {
+		return err
+	}
+	w.size += uint64(len(data))
+	if w.size >= uint64(w.segmentSize) {
+		// the log is in the WAL already, so it's kept growing the current segment until a new one can be started
+		if err := w.rotateLocked(); err != nil {
+			log.Warnf("Failed to start a new WAL segment after segment %d - Error: %s", w.number, err.Error())
+		}
+	}
+	return nil
+}
+
+// Sync - flushes the logs appended to the current segment to the storage device, the segments before it are
+// synced as they're rotated
+func (w *SegmentedWal) Sync() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.current.Sync()
+}
+
+// Segment - returns the number of the current segment, the next log appended goes into it or a later one
+func (w *SegmentedWal) Segment() uint64 {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.number
+}
+
+// Replay - reads every operation log in all the segments from the oldest to the latest and calls fn with its
+// data in the order they were appended. Replay stops at the first error returned by fn.
+func (w *SegmentedWal) Replay(fn func([]byte) error) error {
+	_, err := w.Recover(WalRecoveryAbsoluteConsistency, fn)
+	return err
+}
+
+// Recover - same as `Replay`, except that damaged logs are handled according to mode. Returns a report of the
+// logs dropped in all the segments.
+func (w *SegmentedWal) Recover(mode WalRecoveryMode, fn func([]byte) error) (*WalRecoveryReport, error) {
+	report := &WalRecoveryReport{File: w.dir}
+	segmentReports, err := w.recoverSegments(mode, func(_ uint64, data []byte) error { return fn(data) })
+	for _, segmentReport := range segmentReports {
+		report.Replayed += segmentReport.Replayed
+		report.Dropped = append(report.Dropped, segmentReport.Dropped...)
+		report.Stopped = report.Stopped || segmentReport.Stopped
+	}
+	return report, err
+}
+
+// recoverSegments - recovers the segments from the oldest to the latest and calls fn with the number of the
+// segment and the data of each log. Returns a report for each segment recovered. Once point-in-time recovery
+// stops at a damaged log, the later segments (but the current one) are deleted, since their logs are
+// discarded.
+func (w *SegmentedWal) recoverSegments(
+	mode WalRecoveryMode, fn func(segment uint64, data []byte) error,
+) ([]*WalRecoveryReport, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	numbers, err := listWalSegments(w.dir)
+	if err != nil {
+		return nil, &WalError{Op: OP_WAL_READ_FILE, Err: err}
+	}
+
+	reports := make([]*WalRecoveryReport, 0, len(numbers))
+	stopped := false
+	for _, number := range numbers {
+		if stopped {
+			if number == w.number {
+				continue
+			}
+			if err := os.Remove(walSegmentFile(w.dir, number)); err != nil {
+				return reports, &WalError{Op: OP_WAL_DELETE, Err: err}
+			}
+			continue
+		}
+
+		segment := w.current
+		if number != w.number {
+			if segment, err = OpenBasicWal(walSegmentFile(w.dir, number), false); err != nil {
+				return reports, err
+			}
+		}
+		report, err := segment.Recover(mode, func(data []byte) error { return fn(number, data) })
+		if segment != w.current {
+			segment.Close()
+		}
+		if report != nil {
+			reports = append(reports, report)
+			stopped = report.Stopped && mode == WalRecoveryPointInTime
+		}
+		if err != nil {
+			return reports, err
+		}
+	}
+	return reports, nil
+}
+
+// RemoveSegmentsBefore - deletes the segments older than the segment with number. The current segment is
+// only deleted once the WAL is closed.
+func (w *SegmentedWal) RemoveSegmentsBefore(number uint64) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	numbers, err := listWalSegments(w.dir)
+	if err != nil {
+		return &WalError{Op: OP_WAL_DELETE, Err: err}
+	}
+	for _, n := range numbers {
+		if n >= number || (n == w.number && !w.closed) {
+			break
+		}
+		if err := os.Remove(walSegmentFile(w.dir, n)); err != nil {
+			return &WalError{Op: OP_WAL_DELETE, Err: err}
+		}
+	}
+	return nil
+}
+
+// File -- returns the file of the current segment
+func (w *SegmentedWal) File() WalFile {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.current.File()
+}
+
+// Delete - closes the WAL and deletes all its segments
+func (w *SegmentedWal) Delete() error {
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return w.RemoveSegmentsBefore(^uint64(0))
+}
+
+// Close - closes the current segment, it's fine to close a WAL more than once
+func (w *SegmentedWal) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.closed = true
+	return w.current.Close()
+}
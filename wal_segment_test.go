@@ -0,0 +1,123 @@
+package dbengine
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func getTestSegmentedWal(t *testing.T, segmentSize uint) *SegmentedWal {
+	t.Helper()
+
+	wal, err := OpenSegmentedWal(setupTestDBDir(t), segmentSize, false)
+	if err != nil {
+		t.Fatalf("Failed to open segmented WAL - Error: %s", err.Error())
+	}
+	return wal
+}
+
+func Test_SegmentedWalShouldRotateSegmentsBySize(t *testing.T) {
+	wal := getTestSegmentedWal(t, 10)
+	defer wal.Delete()
+
+	for i := 0; i < 5; i++ {
+		if err := wal.Append([]byte(fmt.Sprintf("log-%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a new segment is started after every two logs
+	if wal.Segment() != 3 {
+		t.Errorf("Expected current segment to be 3, got %d", wal.Segment())
+	}
+	segments, _ := listWalSegments(wal.dir)
+	if fmt.Sprint(segments) != "[1 2 3]" {
+		t.Errorf("Expected segments 1 to 3, got %v", segments)
+	}
+
+	logs := ""
+	if err := wal.Replay(func(data []byte) error { logs += string(data) + " "; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if logs != "log-0 log-1 log-2 log-3 log-4 " {
+		t.Errorf("Expected logs replayed across segments in order, got %q", logs)
+	}
+}
+
+func Test_SegmentedWalShouldContinueAfterExistingSegments(t *testing.T) {
+	wal := getTestSegmentedWal(t, 10)
+	wal.Append([]byte("log-0"))
+	wal.Append([]byte("log-1"))
+	wal.Close()
+
+	reopened, err := OpenSegmentedWal(wal.dir, 10, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Delete()
+	if reopened.Segment() != 3 {
+		t.Errorf("Expected a new segment after the existing ones, got %d", reopened.Segment())
+	}
+
+	segments := make([]uint64, 0)
+	reopened.recoverSegments(WalRecoveryAbsoluteConsistency, func(segment uint64, data []byte) error {
+		segments = append(segments, segment)
+		return nil
+	})
+	if fmt.Sprint(segments) != "[1 1]" {
+		t.Errorf("Expected logs to be recovered from segment 1, got %v", segments)
+	}
+}
+
+func Test_SegmentedWalShouldKeepCurrentSegmentUntilClosed(t *testing.T) {
+	wal := getTestSegmentedWal(t, 10)
+	defer os.RemoveAll(wal.dir)
+	for i := 0; i < 4; i++ {
+		wal.Append([]byte(fmt.Sprintf("log-%d", i)))
+	}
+
+	if err := wal.RemoveSegmentsBefore(2); err != nil {
+		t.Fatal(err)
+	}
+	if segments, _ := listWalSegments(wal.dir); fmt.Sprint(segments) != "[2 3]" {
+		t.Errorf("Expected segments before 2 to be removed, got %v", segments)
+	}
+
+	wal.RemoveSegmentsBefore(^uint64(0))
+	if segments, _ := listWalSegments(wal.dir); fmt.Sprint(segments) != "[3]" {
+		t.Errorf("Expected current segment to be kept, got %v", segments)
+	}
+	if err := wal.Append([]byte("log-4")); err != nil {
+		t.Errorf("Expected appending to the current segment to succeed - Error: %s", err.Error())
+	}
+
+	wal.Delete()
+	if segments, _ := listWalSegments(wal.dir); len(segments) != 0 {
+		t.Errorf("Expected all segments to be deleted, got %v", segments)
+	}
+}
+
+func Test_SegmentedWalPointInTimeRecoveryShouldDiscardLaterSegments(t *testing.T) {
+	wal := getTestSegmentedWal(t, 10)
+	defer os.RemoveAll(wal.dir)
+	wal.Append([]byte("log-0"))
+	wal.Append([]byte("log-1"))
+	wal.Append([]byte("log-2"))
+	wal.Close()
+	// damage the second log of segment 1
+	corruptFile(t, walSegmentFile(wal.dir, 1), walFileHeaderSize+walFragmentHeaderSize+5+walFragmentHeaderSize+2)
+
+	reopened, err := OpenSegmentedWal(wal.dir, 10, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+
+	logs := ""
+	report, err := reopened.Recover(WalRecoveryPointInTime, func(data []byte) error { logs += string(data); return nil })
+	if err != nil || !report.Stopped || logs != "log-0" {
+		t.Errorf("Expected recovery to stop after log-0, got %q %+v - Error: %v", logs, report, err)
+	}
+	if segments, _ := listWalSegments(wal.dir); fmt.Sprint(segments) != "[1 3]" {
+		t.Errorf("Expected segments after the damaged log to be discarded, got %v", segments)
+	}
+}
@@ -37,6 +37,7 @@ func (tf *TestFile) Write(p []byte) (int, error) { return tf.Writer.Write(p) }
 func (tf *TestFile) Stat() (os.FileInfo, error)  { return tf.File.Stat() }
 func (tf *TestFile) Truncate(size int64) error   { return tf.File.Truncate(size) }
 func (tf *TestFile) Name() string                { return tf.File.Name() }
+func (tf *TestFile) Close() error                { return tf.File.Close() }
 
 // BadTruncateWriter - extends TruncateWriter behavior, it writes bytes up to `size` and fail
 // with `errDeviceFull`
@@ -96,10 +97,12 @@ func Test_AppendShouldAppendNewLog(t *testing.T) {
 	log := BasicWalLog{seq: 1, data: data}
 
 	fileContent, _ := ioutil.ReadFile(f.Name())
-	fileData, _ := ReadDataWithVarintPrefix(bytes.NewReader(fileContent), nil)
 	expected, _ := log.Serialize()
-	if string(fileData) != string(expected) {
-		t.Errorf("Incorrect file content - %s", string(fileData))
+	// the file header is followed by the log as a single fragment
+	if !bytes.Equal(fileContent[:walFileHeaderSize], walFileHeader()) ||
+		!bytes.Equal(fileContent[walFileHeaderSize:], frameWalLog(expected, walFileHeaderSize)) ||
+		fileContent[walFileHeaderSize+walFragmentHeaderSize-1] != walFullFragment {
+		t.Errorf("Incorrect file content - %v", fileContent)
 	}
 
 	if wal.seq != 1 {
@@ -145,6 +148,291 @@ func Test_AppendShouldRollbackIfLogNotFullyWritten(t *testing.T) {
 
 func Test_AppendShouldFailIfLogWriteFailed(t *testing.T) {}
 
+func Test_ReplayShouldReturnAllAppendedLogsInOrder(t *testing.T) {
+	wal, err := NewBasicWal(os.TempDir(), false)
+	if err != nil {
+		t.Error(err)
+	}
+	for i := 0; i < 10; i++ {
+		wal.Append([]byte(fmt.Sprintf("log-%d", i)))
+	}
+
+	reopened, err := OpenBasicWal(wal.File().Name(), false)
+	if err != nil {
+		t.Error(err)
+	}
+	replayed := make([]string, 0)
+	if err = reopened.Replay(func(data []byte) error {
+		replayed = append(replayed, string(data))
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+
+	if len(replayed) != 10 {
+		t.Errorf("Expected 10 logs to be replayed, got %d", len(replayed))
+	}
+	for i, data := range replayed {
+		if data != fmt.Sprintf("log-%d", i) {
+			t.Errorf("Log replayed out of order - got %s at position %d", data, i)
+		}
+	}
+	if reopened.seq != 10 {
+		t.Errorf("Sequence number should be restored to 10, got %d", reopened.seq)
+	}
+}
+
+func Test_ReplayShouldStopAtPartiallyWrittenLog(t *testing.T) {
+	wal, err := NewBasicWal(os.TempDir(), false)
+	if err != nil {
+		t.Error(err)
+	}
+	wal.Append([]byte("complete"))
+	wal.Append([]byte("incomplete"))
+
+	// chop off the last few bytes to simulate a crash in the middle of an append
+	info, _ := os.Stat(wal.File().Name())
+	os.Truncate(wal.File().Name(), info.Size()-3)
+
+	replayed := make([]string, 0)
+	err = wal.Replay(func(data []byte) error {
+		replayed = append(replayed, string(data))
+		return nil
+	})
+
+	var walErr *WalError
+	if !errors.As(err, &walErr) || walErr.Op != OP_WAL_REPLAY || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Unexpected error returned - Error: %v", err)
+	}
+	if len(replayed) != 1 || replayed[0] != "complete" {
+		t.Errorf("Only the complete log should be replayed, got %v", replayed)
+	}
+}
+
+func Test_ReplayShouldReturnCorruptionErrorForCorruptedLog(t *testing.T) {
+	wal, err := NewBasicWal(os.TempDir(), false)
+	if err != nil {
+		t.Error(err)
+	}
+	wal.Append([]byte("intact"))
+	info, _ := os.Stat(wal.File().Name())
+	wal.Append([]byte("corrupted"))
+
+	// the last log ends with its data followed by the checksum field
+	end, _ := os.Stat(wal.File().Name())
+	corruptFile(t, wal.File().Name(), end.Size()-checksumSize-2)
+
+	replayed := make([]string, 0)
+	err = wal.Replay(func(data []byte) error {
+		replayed = append(replayed, string(data))
+		return nil
+	})
+
+	var cErr *CorruptionError
+	if !errors.As(err, &cErr) || cErr.File != wal.File().Name() || cErr.Offset != info.Size() {
+		t.Errorf("Expected corruption error at offset %d, got %v", info.Size(), err)
+	}
+	if len(replayed) != 1 || replayed[0] != "intact" {
+		t.Errorf("Only the intact log should be replayed, got %v", replayed)
+	}
+}
+
 func Test_AppendShouldSupportConcurrentWrite(t *testing.T) {}
 
 func Test_DeleteShouldLockTheFileFromBeingWritten(t *testing.T) {}
+
+func Test_ReplayShouldReassembleLogsSpanningBlocks(t *testing.T) {
+	wal, err := NewBasicWal(os.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizes := []int{10, walBlockSize, 3 * walBlockSize, 100, walBlockSize - 2*walFragmentHeaderSize, 0, 5}
+	for i, size := range sizes {
+		if err := wal.Append(bytes.Repeat([]byte{byte('a' + i)}, size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	replayed := make([][]byte, 0)
+	if err := wal.Replay(func(data []byte) error {
+		replayed = append(replayed, append([]byte(nil), data...))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed) != len(sizes) {
+		t.Fatalf("Expected %d logs to be replayed, got %d", len(sizes), len(replayed))
+	}
+	for i, size := range sizes {
+		if !bytes.Equal(replayed[i], bytes.Repeat([]byte{byte('a' + i)}, size)) {
+			t.Errorf("Log %d of size %d replayed incorrectly, got %d bytes", i, size, len(replayed[i]))
+		}
+	}
+}
+
+func Test_walReaderShouldResumeAfterCorruptedBlock(t *testing.T) {
+	wal, err := NewBasicWal(os.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first log fills most of the first block, the second one starts in it and ends in the second block
+	wal.Append(bytes.Repeat([]byte("a"), walBlockSize-100))
+	wal.Append(bytes.Repeat([]byte("b"), 200))
+	wal.Append([]byte("intact"))
+
+	corruptFile(t, wal.File().Name(), walFileHeaderSize+walFragmentHeaderSize+10)
+
+	f, _ := os.Open(wal.File().Name())
+	defer f.Close()
+	reader := newWalReader(f, f.Name())
+
+	var cErr *CorruptionError
+	if _, _, err := reader.next(); !errors.As(err, &cErr) || cErr.Offset != walFileHeaderSize {
+		t.Errorf("Expected corruption error at offset %d, got %v", walFileHeaderSize, err)
+	}
+	// the rest of the first block is skipped, so the second log is missing its beginning
+	if _, _, err := reader.next(); !errors.As(err, &cErr) || cErr.Offset != walBlockSize {
+		t.Errorf("Expected corruption error at offset %d, got %v", walBlockSize, err)
+	}
+	if _, _, err := reader.next(); err != nil {
+		t.Errorf("Expected the log after the damage to be read, got %v", err)
+	}
+	if _, _, err := reader.next(); err != io.EOF {
+		t.Errorf("Expected end of file, got %v", err)
+	}
+}
+
+func Test_ReplayShouldTellTornLogFromCorruptedLog(t *testing.T) {
+	wal, err := NewBasicWal(os.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wal.Append([]byte("complete"))
+	wal.Append(bytes.Repeat([]byte("torn"), walBlockSize))
+
+	// cut the file at a block boundary, in the middle of the last log
+	os.Truncate(wal.File().Name(), 2*walBlockSize)
+
+	replayed := 0
+	err = wal.Replay(func([]byte) error {
+		replayed++
+		return nil
+	})
+	if !errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, ErrCorruption) {
+		t.Errorf("Expected torn log to be reported as unexpected EOF, got %v", err)
+	}
+	if replayed != 1 {
+		t.Errorf("Only the complete log should be replayed, got %d", replayed)
+	}
+}
+
+func Test_ReplayShouldReadLegacyWalFile(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "wal_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// logs written as a plain stream of varint size prefixed logs
+	for i := 0; i < 3; i++ {
+		log := &BasicWalLog{seq: uint32(i + 1), data: []byte(fmt.Sprintf("log-%d", i))}
+		data, _ := log.Serialize()
+		WriteDataWithVarintSizePrefix(f, data)
+	}
+	f.Close()
+
+	wal, err := OpenBasicWal(f.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wal.Delete()
+	replayed := make([]string, 0)
+	if err := wal.Replay(func(data []byte) error {
+		replayed = append(replayed, string(data))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed) != 3 || replayed[2] != "log-2" || wal.seq != 3 {
+		t.Errorf("Expected 3 logs to be replayed, got %v", replayed)
+	}
+}
+
+// getDamagedWal - returns a WAL with 8 logs of a third of a block each, whose second log is corrupted and whose
+// last log is torn
+func getDamagedWal(t *testing.T) *BasicWal {
+	t.Helper()
+
+	wal, err := NewBasicWal(os.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offsets := make([]int64, 0)
+	for i := 0; i < 8; i++ {
+		info, _ := os.Stat(wal.File().Name())
+		offsets = append(offsets, info.Size())
+		wal.Append(bytes.Repeat([]byte{byte('0' + i)}, walBlockSize/3))
+	}
+	corruptFile(t, wal.File().Name(), offsets[1]+walFragmentHeaderSize+10)
+	info, _ := os.Stat(wal.File().Name())
+	os.Truncate(wal.File().Name(), info.Size()-10)
+	return wal
+}
+
+func Test_RecoverShouldHandleDamagedLogsAccordingToMode(t *testing.T) {
+	tests := []struct {
+		mode     WalRecoveryMode
+		replayed string
+		dropped  int
+		stopped  bool
+		failed   bool
+	}{
+		{mode: WalRecoveryAbsoluteConsistency, replayed: "0", failed: true},
+		{mode: WalRecoveryTolerateCorruptedTail, replayed: "0", failed: true},
+		{mode: WalRecoveryPointInTime, replayed: "0", dropped: 1, stopped: true},
+		// the rest of the block of the corrupted log is skipped, which holds the log after it as well
+		{mode: WalRecoverySkipAnyCorruptedRecord, replayed: "03456", dropped: 3},
+	}
+
+	for _, test := range tests {
+		wal := getDamagedWal(t)
+		replayed := ""
+		report, err := wal.Recover(test.mode, func(data []byte) error {
+			replayed += string(data[:1])
+			return nil
+		})
+
+		if failed := err != nil; failed != test.failed || (failed && !errors.Is(err, ErrCorruption)) {
+			t.Errorf("Mode %d - unexpected error returned - Error: %v", test.mode, err)
+		}
+		if replayed != test.replayed || report.Replayed != len(test.replayed) {
+			t.Errorf("Mode %d - expected logs %s to be replayed, got %s", test.mode, test.replayed, replayed)
+		}
+		if len(report.Dropped) != test.dropped || report.Stopped != test.stopped {
+			t.Errorf("Mode %d - unexpected report %+v", test.mode, report)
+		}
+		wal.Delete()
+	}
+}
+
+func Test_RecoverShouldTolerateTornTailUnlessAbsoluteConsistency(t *testing.T) {
+	wal, err := NewBasicWal(os.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wal.Delete()
+	wal.Append([]byte("complete"))
+	wal.Append([]byte("torn"))
+	info, _ := os.Stat(wal.File().Name())
+	os.Truncate(wal.File().Name(), info.Size()-3)
+
+	report, err := wal.Recover(WalRecoveryTolerateCorruptedTail, func([]byte) error { return nil })
+	if err != nil || report.Replayed != 1 || len(report.Dropped) != 1 || report.Stopped {
+		t.Errorf("Expected torn log to be dropped, got %+v - Error: %v", report, err)
+	}
+	if !errors.Is(report.Dropped[0], io.ErrUnexpectedEOF) {
+		t.Errorf("Expected torn log to be reported as unexpected EOF, got %v", report.Dropped[0])
+	}
+
+	if _, err := wal.Recover(WalRecoveryAbsoluteConsistency, func([]byte) error { return nil }); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected torn log to fail recovery, got %v", err)
+	}
+}